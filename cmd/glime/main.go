@@ -25,6 +25,22 @@ func main() {
 		}
 	}
 
+	// -p opens every file in a tab page of its own; -u reads the startup
+	// commands from another file, -u NONE skips them
+	tabs := false
+	initFile, explicitInit := editor.InitFile(), false
+	for len(args) > 0 {
+		if args[0] == "-p" {
+			tabs = true
+			args = args[1:]
+		} else if args[0] == "-u" && len(args) > 1 {
+			initFile, explicitInit = args[1], true
+			args = args[2:]
+		} else {
+			break
+		}
+	}
+
 	// Create editor
 	ed, err := editor.New()
 	if err != nil {
@@ -32,6 +48,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// run the startup file before opening files, so its options apply to
+	// them; its errors are shown once the editor is up
+	var initErr error
+	if initFile != "" && initFile != "NONE" {
+		if _, err := os.Stat(initFile); err == nil || explicitInit {
+			initErr = ed.Source(initFile)
+		}
+	}
+
 	// Load file or directory if specified
 	if len(args) > 0 {
 		path := args[0]
@@ -49,6 +74,18 @@ func main() {
 		}
 	}
 
+	// further files go into the buffer list, or into tabs with -p
+	if len(args) > 1 {
+		if err := ed.AddFiles(args[1:], tabs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if initErr != nil {
+		ed.ShowError(initErr)
+	}
+
 	// Run editor
 	if err := ed.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Editor error: %v\n", err)
@@ -61,10 +98,15 @@ func printHelp() {
 
 Usage:
   glime [options] [file|directory]
+  glime [-p] file...
+  glime -u init [file]
 
 Options:
   -h, --help      Show this help message
   -v, --version   Show version information
+  -p              Open each file in its own tab page
+  -u file         Run the Ex commands in file at startup instead of
+                  ~/.config/glime/init (-u NONE runs none)
 
 Examples:
   glime                 Open with empty buffer
@@ -72,6 +114,7 @@ Examples:
   glime /path/to/file   Open file at path
   glime .               Open file explorer in current directory
   glime /path/to/dir    Open file explorer at directory
+  glime -p a.go b.go    Open a.go and b.go in two tab pages
 
 Key Bindings:
   Normal Mode:
@@ -83,7 +126,16 @@ Key Bindings:
     $          Move to line end
     g          Move to first line
     G          Move to last line
+    w, b, e    Move by word (W, B, E by WORD)
+    f, t       Move to / before a character on the line
     x          Delete character
+    d, c, y    Delete / change / yank over a motion (dw, c$, 2d3w)
+               or a text object (diw, ci", da(, yit)
+    .          Repeat the last change
+    qa, @a     Record / play a macro in register a (@@ plays it again)
+    "a         Use register a for the next yank, delete or paste ("ayy, "ap)
+    "+y, "+p   Yank to / paste from the system clipboard
+    v, V       Select characters / lines (Ctrl-v for a block)
 
   Insert Mode:
     ESC        Return to normal mode
@@ -95,6 +147,16 @@ Key Bindings:
     :q         Quit
     :wq        Write and quit
     :q!        Force quit (discard changes)
+    :e file    Open file in a new buffer
+    :ls        List buffers
+    :reg       List registers
+    :nmap a b  Map keys (also :imap, :vmap, :noremap; :unmap removes)
+    :set mouse=a  Click, drag and scroll with the mouse
+    :set nu ts=4  Set options (:setlocal for this buffer or window only)
+    :bn, :bp   Next / previous buffer
+    :sp, :vs   Split the window horizontally / vertically
+    :close     Close the current window
+    :tabnew    Open a new tab page
     :{number}  Go to line number
 
 Report bugs at: https://github.com/AdityaKrSingh26/glime/issues
@@ -2,8 +2,8 @@ package buffer
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
-	"slices"
 	"strings"
 	"unicode/utf8"
 )
@@ -12,135 +12,182 @@ import (
 // represent in memory content of file being edited
 
 // Buffer represents the text content being edited.
-// It stores text as a slice of lines and tracks the modification state.
+// Text is kept in a piece table (see piecetable.go) so that edits and line
+// lookups stay logarithmic even on very large files. Lines are separated by
+// '\n' internally; line numbers and columns are exposed exactly as before.
 type Buffer struct {
-	lines    []string
+	text     *pieceTable
 	modified bool   // Whether buffer has unsaved changes
 	filePath string // Associated file path (empty for new buffers)
+
+	changeTick uint64 // bumped on every change, see ChangeTick
+
+	// on-disk representation, detected by Load and reproduced by WriteTo
+	format    FileFormat // line ending written between lines
+	endOfLine bool       // whether the last line is terminated
+	bom       bool       // whether the file starts with a UTF-8 BOM
+	stamp     FileStamp  // version of the file last read or written
+
+	marks map[*Mark]struct{} // marks kept up to date by edits, see marks.go
+	named map[rune]*Mark     // the named marks 'a to 'z
 }
 
 func New() *Buffer {
 	return &Buffer{
-		lines:    []string{""},
-		modified: false,
-		filePath: "",
+		text:      newPieceTable(nil),
+		modified:  false,
+		filePath:  "",
+		format:    FormatUnix,
+		endOfLine: true,
 	}
 }
 
 // creates buffer from existing lines
 func NewFromLines(lines []string, filePath string) *Buffer {
-	if len(lines) == 0 {
-		lines = []string{""}
-	}
-
 	return &Buffer{
-		lines:    lines,
-		modified: false,
-		filePath: filePath,
+		text:      newPieceTable([]byte(strings.Join(lines, "\n"))),
+		modified:  false,
+		filePath:  filePath,
+		format:    FormatUnix,
+		endOfLine: true,
 	}
 }
 
 // return the number of lines
 func (b *Buffer) NumLines() int {
-	return len(b.lines)
+	return b.text.Newlines() + 1
+}
+
+// returns the byte offset where row starts. row must be in bounds.
+func (b *Buffer) lineStart(row int) int {
+	if row == 0 {
+		return 0
+	}
+	return b.text.newlineOffset(row) + 1
+}
+
+// returns the byte offset of the '\n' ending row (or the end of text for the last row).
+func (b *Buffer) lineEnd(row int) int {
+	if row == b.NumLines()-1 {
+		return b.text.Len()
+	}
+	return b.text.newlineOffset(row + 1)
 }
 
 // returns the content of a specific line that is inbound
 func (b *Buffer) GetLine(row int) (string, error) {
-	if row < 0 || row >= len(b.lines) {
-		return "", fmt.Errorf("line %d out of bounds (0-%d)", row, len(b.lines)-1)
+	if row < 0 || row >= b.NumLines() {
+		return "", fmt.Errorf("line %d out of bounds (0-%d)", row, b.NumLines()-1)
 	}
-	return b.lines[row], nil
+	return string(b.text.Read(b.lineStart(row), b.lineEnd(row))), nil
 }
 
 // returns all lines in the buffer.
-// Returns internal slice — do not modify.
+// Builds a fresh slice on every call (O(size of buffer)) — prefer GetLine
+// when only a few lines are needed.
 func (b *Buffer) GetLines() []string {
-	return b.lines
+	return strings.Split(b.String(), "\n")
 }
 
 // returns the length of the specified line in runes (not bytes).
 func (b *Buffer) LineLength(row int) (int, error) {
-	if row < 0 || row >= len(b.lines) {
+	line, err := b.GetLine(row)
+	if err != nil {
 		return 0, fmt.Errorf("line %d out of bounds", row)
 	}
-	return utf8.RuneCountInString(b.lines[row]), nil
+	return utf8.RuneCountInString(line), nil
+}
+
+// returns the byte offset of rune column col on row, along with the line itself.
+func (b *Buffer) runeOffset(row, col int) (int, string, error) {
+	if row < 0 || row >= b.NumLines() {
+		return 0, "", fmt.Errorf("row %d out of bounds", row)
+	}
+
+	line, _ := b.GetLine(row)
+	n := utf8.RuneCountInString(line)
+	if col < 0 || col > n {
+		return 0, line, fmt.Errorf("col %d out of bounds (0-%d)", col, n)
+	}
+
+	return b.lineStart(row) + byteIndex(line, col), line, nil
+}
+
+// converts a rune index into a byte index within s.
+func byteIndex(s string, col int) int {
+	for i := range s {
+		if col == 0 {
+			return i
+		}
+		col--
+	}
+	return len(s)
 }
 
 // insert a new character at a given row, col (rune index).
 // return error if row/col is out of bounds
 func (b *Buffer) InsertChar(row, col int, ch rune) error {
-	if row < 0 || row >= len(b.lines) {
-		return fmt.Errorf("row %d out of bounds", row)
-	}
-
-	runes := []rune(b.lines[row])
-	if col < 0 || col > len(runes) {
-		return fmt.Errorf("col %d out of bounds (0-%d)", col, len(runes))
+	off, _, err := b.runeOffset(row, col)
+	if err != nil {
+		return err
 	}
 
-	// Insert character at rune position
-	newRunes := make([]rune, 0, len(runes)+1)
-	newRunes = append(newRunes, runes[:col]...)
-	newRunes = append(newRunes, ch)
-	newRunes = append(newRunes, runes[col:]...)
-	b.lines[row] = string(newRunes)
-	b.modified = true
+	b.text.Insert(off, string(ch))
+	b.touch()
 	return nil
 }
 
 // delete a char at a given row, col (rune index).
 // return nil if end of line || error if row/char out of bound
 func (b *Buffer) DeleteChar(row, col int) error {
-	if row < 0 || row >= len(b.lines) {
-		return fmt.Errorf("row %d out of bounds", row)
-	}
-
-	runes := []rune(b.lines[row])
-	if col < 0 || col > len(runes) {
-		return fmt.Errorf("col %d out of bounds", col)
+	off, line, err := b.runeOffset(row, col)
+	if err != nil {
+		return err
 	}
 
 	// end of line, nothing to delete
-	if col == len(runes) {
+	if off == b.lineStart(row)+len(line) {
 		return nil
 	}
 
-	// Delete character at rune position
-	b.lines[row] = string(append(runes[:col], runes[col+1:]...))
-	b.modified = true
+	_, size := utf8.DecodeRuneInString(line[off-b.lineStart(row):])
+	b.text.Delete(off, size)
+	b.touch()
 	return nil
 }
 
 // inserts a new empty line at the specified row.
 // existing lines are shifted down.
 func (b *Buffer) InsertLine(row int) error {
-	if row < 0 || row > len(b.lines) {
-		return fmt.Errorf("row %d out of bounds (0-%d)", row, len(b.lines))
-	}
-
-	// Insert empty line
-	b.lines = slices.Insert(b.lines, row, "")
-	b.modified = true
-	return nil
+	return b.InsertLineWithContent(row, "")
 }
 
 // deletes the specified line
 func (b *Buffer) DeleteLine(row int) error {
-	if row < 0 || row >= len(b.lines) {
+	n := b.NumLines()
+	if row < 0 || row >= n {
 		return fmt.Errorf("row %d out of bounds", row)
 	}
 
 	// If only one line, make it empty instead of deleting
-	if len(b.lines) == 1 {
-		b.lines[0] = ""
-		b.modified = true
+	if n == 1 {
+		b.text.Delete(0, b.text.Len())
+		b.marksDeleted(0)
+		b.touch()
 		return nil
 	}
 
-	// Delete line
-	b.lines = append(b.lines[:row], b.lines[row+1:]...)
-	b.modified = true
+	// Delete the line with its trailing newline, or with the preceding
+	// newline when it is the last line
+	if row < n-1 {
+		start := b.lineStart(row)
+		b.text.Delete(start, b.lineStart(row+1)-start)
+	} else {
+		start := b.lineEnd(row - 1)
+		b.text.Delete(start, b.text.Len()-start)
+	}
+	b.marksDeleted(row)
+	b.touch()
 	return nil
 }
 
@@ -148,42 +195,35 @@ func (b *Buffer) DeleteLine(row int) error {
 // text after col becomes a new line inserted below.
 // used when pressing Enter in the middle of a line.
 func (b *Buffer) SplitLine(row, col int) error {
-	if row < 0 || row >= len(b.lines) {
-		return fmt.Errorf("row %d out of bounds", row)
-	}
-
-	runes := []rune(b.lines[row])
-	if col < 0 || col > len(runes) {
-		return fmt.Errorf("col %d out of bounds", col)
+	off, _, err := b.runeOffset(row, col)
+	if err != nil {
+		return err
 	}
 
-	// Split line at rune column
-	before := string(runes[:col])
-	after := string(runes[col:])
-
-	b.lines[row] = before
-	b.lines = slices.Insert(b.lines, row+1, after)
-	b.modified = true
+	b.text.Insert(off, "\n")
+	b.marksSplit(row, col)
+	b.touch()
 	return nil
 }
 
 // joins the specified line with the next line
 func (b *Buffer) JoinLines(row int) error {
-	if row < 0 || row >= len(b.lines)-1 {
+	if row < 0 || row >= b.NumLines()-1 {
 		return fmt.Errorf("cannot join line %d", row)
 	}
 
-	// Join with next line
-	b.lines[row] = b.lines[row] + b.lines[row+1]
-	b.lines = append(b.lines[:row+1], b.lines[row+2:]...)
-	b.modified = true
+	// Join with next line by removing the newline between them
+	col, _ := b.LineLength(row)
+	b.text.Delete(b.lineEnd(row), 1)
+	b.marksJoined(row, col)
+	b.touch()
 	return nil
 }
 
 // deletes the character before the cursor (rune-indexed col).
 // start of a line (col=0), it joins with the previous line.
 func (b *Buffer) Backspace(row, col int) (newRow, newCol int, err error) {
-	if row < 0 || row >= len(b.lines) {
+	if row < 0 || row >= b.NumLines() {
 		return row, col, fmt.Errorf("row %d out of bounds", row)
 	}
 
@@ -194,7 +234,7 @@ func (b *Buffer) Backspace(row, col int) (newRow, newCol int, err error) {
 
 	// If at start of line, join with previous line
 	if col == 0 {
-		prevLineLen := utf8.RuneCountInString(b.lines[row-1])
+		prevLineLen, _ := b.LineLength(row - 1)
 		if err := b.JoinLines(row - 1); err != nil {
 			return row, col, err
 		}
@@ -202,37 +242,60 @@ func (b *Buffer) Backspace(row, col int) (newRow, newCol int, err error) {
 	}
 
 	// Delete previous character at rune position
-	runes := []rune(b.lines[row])
-	if col > len(runes) {
-		col = len(runes)
+	lineLen, _ := b.LineLength(row)
+	if col > lineLen {
+		col = lineLen
 	}
 
-	b.lines[row] = string(append(runes[:col-1], runes[col:]...))
-	b.modified = true
+	if err := b.DeleteChar(row, col-1); err != nil {
+		return row, col, err
+	}
 	return row, col - 1, nil
 }
 
 // replaces the content of the specified line.
 func (b *Buffer) SetLine(row int, text string) error {
-	if row < 0 || row >= len(b.lines) {
+	if row < 0 || row >= b.NumLines() {
 		return fmt.Errorf("row %d out of bounds", row)
 	}
-	b.lines[row] = text
-	b.modified = true
+
+	start, end := b.lineStart(row), b.lineEnd(row)
+	b.text.Delete(start, end-start)
+	b.text.Insert(start, text)
+	b.touch()
 	return nil
 }
 
 // inserts a line with the given text at the specified row.
 // existing lines are shifted down.
 func (b *Buffer) InsertLineWithContent(row int, text string) error {
-	if row < 0 || row > len(b.lines) {
-		return fmt.Errorf("row %d out of bounds (0-%d)", row, len(b.lines))
+	n := b.NumLines()
+	if row < 0 || row > n {
+		return fmt.Errorf("row %d out of bounds (0-%d)", row, n)
 	}
-	b.lines = slices.Insert(b.lines, row, text)
-	b.modified = true
+
+	if row == n {
+		b.text.Insert(b.text.Len(), "\n"+text)
+	} else {
+		b.text.Insert(b.lineStart(row), text+"\n")
+	}
+	b.marksInserted(row, 1)
+	b.touch()
 	return nil
 }
 
+// marks the buffer modified and bumps the change tick.
+func (b *Buffer) touch() {
+	b.modified = true
+	b.changeTick++
+}
+
+// returns a counter that increases with every change to the buffer,
+// so callers can cheaply tell whether anything changed since they last looked.
+func (b *Buffer) ChangeTick() uint64 {
+	return b.changeTick
+}
+
 func (b *Buffer) IsModified() bool {
 	return b.modified
 }
@@ -245,10 +308,50 @@ func (b *Buffer) FilePath() string {
 	return b.filePath
 }
 
+// sets the file path; the disk stamp belonged to the old file and is dropped.
 func (b *Buffer) SetFilePath(path string) {
+	if path != b.filePath {
+		b.stamp = FileStamp{}
+	}
 	b.filePath = path
 }
 
+func (b *Buffer) Format() FileFormat {
+	return b.format
+}
+
+// changes the line ending used when writing; the buffer becomes modified.
+func (b *Buffer) SetFormat(format FileFormat) {
+	if b.format != format {
+		b.format = format
+		b.touch()
+	}
+}
+
+func (b *Buffer) EndOfLine() bool {
+	return b.endOfLine
+}
+
+// sets whether the last line gets a line ending when written.
+func (b *Buffer) SetEndOfLine(eol bool) {
+	if b.endOfLine != eol {
+		b.endOfLine = eol
+		b.touch()
+	}
+}
+
+func (b *Buffer) BOM() bool {
+	return b.bom
+}
+
+// sets whether a UTF-8 BOM is written at the start of the file.
+func (b *Buffer) SetBOM(bom bool) {
+	if b.bom != bom {
+		b.bom = bom
+		b.touch()
+	}
+}
+
 // returns just the filename (without directory path).
 func (b *Buffer) FileName() string {
 	if b.filePath == "" {
@@ -270,10 +373,53 @@ func RuneSlice(s string, start, end int) string {
 	return string(runes[start:end])
 }
 
+// returns the size of the text in bytes (line separators counted as one byte).
+func (b *Buffer) Size() int {
+	return b.text.Len()
+}
+
 func (b *Buffer) IsEmpty() bool {
-	return len(b.lines) == 1 && b.lines[0] == ""
+	return b.text.Len() == 0
+}
+
+// replaces the whole text of the buffer ('\n'-separated lines).
+func (b *Buffer) SetText(text []byte) {
+	b.text.Delete(0, b.text.Len())
+	b.text.Insert(0, string(text))
+	b.marksTruncated()
+	b.touch()
+}
+
+// returns a copy of the whole text, lines separated by '\n'.
+func (b *Buffer) Bytes() []byte {
+	return b.text.Read(0, b.text.Len())
+}
+
+// Snapshot is the text of a buffer at one moment. Taking one copies the
+// list of pieces, not the text, so it is cheap; the text can be read later,
+// on another goroutine, while the buffer goes on being edited.
+type Snapshot struct {
+	pieces [][]byte
+}
+
+// returns a snapshot of the whole text, lines separated by '\n'.
+func (b *Buffer) Snapshot() *Snapshot {
+	return &Snapshot{pieces: b.text.Pieces()}
+}
+
+// writes the text of the snapshot to w.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, p := range s.pieces {
+		n, err := w.Write(p)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
 }
 
 func (b *Buffer) String() string {
-	return strings.Join(b.lines, "\n")
+	return string(b.text.Read(0, b.text.Len()))
 }
@@ -2,65 +2,419 @@ package buffer
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// FileFormat is the line ending a buffer is written with.
+type FileFormat int
+
+const (
+	FormatUnix FileFormat = iota // "\n"
+	FormatDos                    // "\r\n"
+)
+
+// returns the Vim-style name of the format ("unix" or "dos").
+func (f FileFormat) String() string {
+	if f == FormatDos {
+		return "dos"
+	}
+	return "unix"
+}
+
+// returns the bytes written at the end of each line.
+func (f FileFormat) LineEnding() string {
+	if f == FormatDos {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// parses a format name as accepted by :set fileformat.
+func ParseFileFormat(name string) (FileFormat, error) {
+	switch name {
+	case "unix":
+		return FormatUnix, nil
+	case "dos":
+		return FormatDos, nil
+	}
+	return FormatUnix, fmt.Errorf("invalid fileformat: %s", name)
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// how much Load reads per call; also the granularity of progress reports.
+const loadChunkSize = 1 << 20
+
+// ProgressFunc is called while loading with the bytes read so far and the file size.
+type ProgressFunc func(read, total int64)
+
 /**
-* Load func - reads a file and returns its lines
+* Load func - reads a file into a new Buffer
+* The file is read in chunks (progress, if non-nil, is called after each one),
+* so lines of any length are fine. The BOM, line ending and final newline are
+* detected and remembered so that WriteTo reproduces the file byte for byte.
+* A file is treated as "dos" only when every line ends in "\r\n"; otherwise
+* stray '\r' bytes are kept as part of the line content.
 * Example :
 * 	File content :
-* 		hello
+* 		hello\r\n
 * 		world
-* returns => []string{"hello", "world"}
+* returns => lines {"hello", "world"}, format dos, no final newline
  */
-func Load(filePath string) ([]string, error) {
-
+func Load(filePath string, progress ProgressFunc) (*Buffer, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file :%w", err)
 	}
 	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	if err := scanner.Err(); err != nil {
+	data, err := readChunks(file, info.Size(), progress)
+	if err != nil {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 
-	// ensure atlease one line
-	if len(lines) == 0 {
-		lines = []string{""}
+	b := New()
+	b.filePath = filePath
+	b.stamp = FileStamp{ModTime: info.ModTime(), Size: int64(len(data)), Hash: sha256.Sum256(data)}
+
+	if bytes.HasPrefix(data, utf8BOM) {
+		b.bom = true
+		data = data[len(utf8BOM):]
+	}
+
+	lf := bytes.Count(data, []byte("\n"))
+	if lf > 0 && bytes.Count(data, []byte("\r\n")) == lf {
+		b.format = FormatDos
+		data = stripCR(data)
+	}
+
+	b.endOfLine = len(data) > 0 && data[len(data)-1] == '\n'
+	if b.endOfLine {
+		data = data[:len(data)-1]
+	}
+
+	b.text = newPieceTable(data)
+	return b, nil
+}
+
+// reads r to EOF in loadChunkSize steps, reporting progress against total.
+func readChunks(r io.Reader, total int64, progress ProgressFunc) ([]byte, error) {
+	data := make([]byte, 0, total+1)
+	for {
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)] // file grew while reading
+		}
+		end := min(len(data)+loadChunkSize, cap(data))
+		n, err := r.Read(data[len(data):end])
+		data = data[:len(data)+n]
+		if progress != nil && n > 0 {
+			progress(int64(len(data)), max(total, int64(len(data))))
+		}
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// removes the '\r' of every "\r\n" in place.
+func stripCR(data []byte) []byte {
+	w := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		data[w] = data[i]
+		w++
+	}
+	return data[:w]
+}
+
+// writes the buffer as it should appear on disk: BOM, lines joined by the
+// buffer's line ending, and a final line ending when endOfLine is set.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	return b.writeFile(w, b.endOfLine)
+}
+
+// writes the buffer as WriteTo does, ending the last line when eol is set.
+func (b *Buffer) writeFile(w io.Writer, eol bool) (int64, error) {
+	var written int64
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		written += int64(n)
+		return err
+	}
+
+	if b.bom {
+		if err := write(utf8BOM); err != nil {
+			return written, err
+		}
+	}
+
+	ending := []byte(b.format.LineEnding())
+	err := b.text.Each(func(p []byte) error {
+		if b.format == FormatUnix {
+			return write(p)
+		}
+		for {
+			i := bytes.IndexByte(p, '\n')
+			if i < 0 {
+				return write(p)
+			}
+			if err := write(p[:i]); err != nil {
+				return err
+			}
+			if err := write(ending); err != nil {
+				return err
+			}
+			p = p[i+1:]
+		}
+	})
+	if err != nil {
+		return written, err
 	}
 
-	return lines, nil
+	if eol {
+		err = write(ending)
+	}
+	return written, err
 }
 
-// saves write lines to a file
-func Save(filePath string, lines []string) error {
-	file, err := os.Create(filePath)
+// WriteMode selects how Save replaces an existing file (:set backupcopy).
+type WriteMode int
+
+const (
+	WriteAuto    WriteMode = iota // atomic, but in place for hard-linked files
+	WriteAtomic                   // write a temp file, fsync it and rename it over the original
+	WriteInPlace                  // truncate and rewrite the original file
+)
+
+// returns the :set backupcopy value for the mode.
+func (m WriteMode) String() string {
+	switch m {
+	case WriteAtomic:
+		return "no"
+	case WriteInPlace:
+		return "yes"
+	default:
+		return "auto"
+	}
+}
+
+// parses a :set backupcopy value ("auto", "yes" = in place, "no" = atomic rename).
+func ParseWriteMode(name string) (WriteMode, error) {
+	switch name {
+	case "auto":
+		return WriteAuto, nil
+	case "no":
+		return WriteAtomic, nil
+	case "yes":
+		return WriteInPlace, nil
+	}
+	return WriteAuto, fmt.Errorf("invalid backupcopy: %s", name)
+}
+
+// SaveOptions controls how Save writes a file.
+type SaveOptions struct {
+	Mode   WriteMode // how an existing file is replaced
+	FixEOL bool      // end the last line even if the buffer has no final line ending (:set fixeol)
+}
+
+// errNoRename is returned by saveAtomic when the file can't be replaced by
+// a new one as it should be: the temp file can't be created next to it, or
+// the new file can't be given its owner. Nothing has changed then, and the
+// file is written in place instead.
+var errNoRename = errors.New("cannot replace file")
+
+// saves the buffer to a file.
+// Symlinks are followed so the link itself survives, and an existing
+// file keeps its mode bits and owner. Unless the mode says otherwise the
+// new content goes to a temporary file next to the target which is fsynced
+// and renamed over it, so a failed write never leaves a truncated file.
+// When that can't be done without changing the owner, or the directory
+// can't take the temporary file, the file is rewritten in place.
+// opts.FixEOL only changes what is written: the buffer keeps its own
+// endOfLine, so a failed write leaves it as it was.
+func Save(filePath string, b *Buffer, opts SaveOptions) error {
+	target := saveTarget(filePath)
+
+	info, err := os.Stat(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		info = nil // a new file
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	eol := b.endOfLine || opts.FixEOL
+	mode := opts.Mode
+	if info != nil && (mode == WriteInPlace || (mode == WriteAuto && hardLinkCount(info) > 1)) {
+		err = saveInPlace(target, b, eol)
+	} else if err = saveAtomic(target, b, eol, info); errors.Is(err, errNoRename) {
+		err = saveInPlace(target, b, eol)
+	}
+	if err != nil {
+		return err
+	}
+	return b.restamp(target)
+}
 
-	for _, line := range lines {
-		if _, err := writer.WriteString(line + "\n"); err != nil {
-			return fmt.Errorf("failed to write line: %w", err)
+// returns the file a save of path writes: the end of its chain of symlinks.
+// A dangling link is followed to the file it names, which the save creates.
+func saveTarget(path string) string {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		return target
+	}
+	for range 40 { // as many links as the kernel follows
+		link, err := os.Readlink(path)
+		if err != nil {
+			return path // not a link: a new file
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
 		}
+		path = link
 	}
+	return path
+}
 
+// re-stamps the buffer after it was written to target.
+func (b *Buffer) restamp(target string) error {
+	stamp, err := StampFile(target)
+	if err != nil {
+		return fmt.Errorf("failed to stat written file: %w", err)
+	}
+	b.stamp = stamp
 	return nil
 }
 
+// writes b to a temp file in target's directory and renames it over target.
+// When orig is non-nil its permissions and owner are copied to the new file;
+// a new file gets the permissions the umask allows. Returns errNoRename,
+// with target untouched, when the temp file can't be made or given the
+// owner of orig.
+func saveAtomic(target string, b *Buffer, eol bool, orig os.FileInfo) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".glime-*")
+	if err != nil {
+		return fmt.Errorf("%w: failed to create temp file: %w", errNoRename, err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	perm := 0666 &^ umask()
+	if orig != nil {
+		perm = orig.Mode().Perm()
+		if uid, gid, ok := fileOwner(orig); ok {
+			// only root can give a file away
+			if err := tmp.Chown(uid, gid); err != nil {
+				return fmt.Errorf("%w: failed to keep the owner: %w", errNoRename, err)
+			}
+		}
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := writeAndSync(tmp, b, eol); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	syncDir(filepath.Dir(target))
+	return nil
+}
+
+// truncates target and rewrites it, keeping its inode (and so its hard
+// links and owner). A new file is created.
+func saveInPlace(target string, b *Buffer, eol bool) error {
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if err := writeAndSync(file, b, eol); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writes the buffer through a bufio.Writer, ending the last line when eol
+// is set, and fsyncs the file.
+func writeAndSync(file *os.File, b *Buffer, eol bool) error {
+	writer := bufio.NewWriter(file)
+	if _, err := b.writeFile(writer, eol); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	return nil
+}
+
+// fsyncs a directory so a rename in it is durable; errors are ignored
+// because not every filesystem supports it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// returns the file mode creation mask of the process.
+func umask() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return os.FileMode(mask)
+}
+
+// returns the owner of the file described by info, if the platform exposes it.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// returns the number of hard links to the file described by info (1 if unknown).
+func hardLinkCount(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
+
 // Exists check if file exists
 func Exists(filePath string) bool {
 	_, err := os.Stat(filePath)
@@ -81,21 +435,95 @@ func IsReadable(filePath string) bool {
 	return true
 }
 
-// Backup creates a backup copy of a file so that original data is not lost
-func Backup(filePath string) error {
+// BackupOptions controls where Backup puts copies and how many it keeps.
+type BackupOptions struct {
+	Dir  string // directory for backups; empty means next to the file
+	Keep int    // backups retained per file; 1 keeps a single "file~"
+}
+
+// the timestamp of a backup with Keep > 1; it sorts in time order.
+const backupStamp = "20060102-150405.000000000"
+
+// Backup creates a backup copy of a file so that original data is not lost.
+// With Keep > 1 every backup gets a timestamp ("file.20060102-150405.000000000~")
+// and the oldest ones beyond Keep are removed. Backups in a shared Dir are
+// named after the full path ("%home%me%file~") so files never collide.
+// Returns the path of the backup, or "" if there was nothing to back up.
+func Backup(filePath string, opts BackupOptions) (string, error) {
 	if !Exists(filePath) {
-		return nil // nothing to backup
+		return "", nil // nothing to backup
 	}
 
-	backupPath := filePath + "~"
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file for backup: %w", err)
+	}
 	input, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read file for backup: %w", err)
+		return "", fmt.Errorf("failed to read file for backup: %w", err)
 	}
 
-	if err := os.WriteFile(backupPath, input, 0644); err != nil {
-		return fmt.Errorf("failed to write backup: %w", err)
+	dir, base := filepath.Split(filePath)
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create backup dir: %w", err)
+		}
+		abs, err := filepath.Abs(filePath)
+		if err != nil {
+			return "", fmt.Errorf("invalid path: %w", err)
+		}
+		dir, base = opts.Dir, strings.ReplaceAll(abs, string(filepath.Separator), "%")
 	}
 
-	return nil
+	backupPath := filepath.Join(dir, base+"~")
+	if opts.Keep > 1 {
+		stamp := time.Now().Format(backupStamp)
+		backupPath = filepath.Join(dir, base+"."+stamp+"~")
+	}
+
+	if err := os.WriteFile(backupPath, input, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if opts.Keep > 1 {
+		pruneBackups(filepath.Join(dir, base), opts.Keep)
+	}
+	return backupPath, nil
+}
+
+// removes all but the newest keep timestamped backups of prefix.
+// Timestamps sort lexically, so the oldest come first. Only names that are
+// prefix, a dot, a timestamp and ~ count: the backups of "x.go" also start
+// with "x.".
+func pruneBackups(prefix string, keep int) {
+	candidates, err := filepath.Glob(escapeGlob(prefix) + ".*~")
+	if err != nil {
+		return
+	}
+	var matches []string
+	for _, m := range candidates {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, prefix+"."), "~")
+		if _, err := time.Parse(backupStamp, stamp); err == nil {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) <= keep {
+		return
+	}
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-keep] {
+		os.Remove(old)
+	}
+}
+
+// escapes glob metacharacters so a literal path can be used as a pattern prefix.
+func escapeGlob(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
 }
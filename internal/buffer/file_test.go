@@ -0,0 +1,210 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// pruning the backups of "x" leaves those of "x.go" and other files
+// starting with "x." alone.
+func TestPruneBackupsOwnOnly(t *testing.T) {
+	dir := t.TempDir()
+	others := []string{
+		"x.go.20261016-101010.000000001~",
+		"x.go.20261016-101010.000000002~",
+		"x.old~",
+		"x.20261016~",
+	}
+	for _, name := range append(slices.Clone(others), "x", "x.go") {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	x := filepath.Join(dir, "x")
+	var made []string
+	for range 4 {
+		p, err := Backup(x, BackupOptions{Keep: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		made = append(made, filepath.Base(p))
+	}
+
+	for _, name := range others {
+		if !Exists(filepath.Join(dir, name)) {
+			t.Errorf("%s was pruned with the backups of x", name)
+		}
+	}
+	for i, name := range made {
+		if kept := Exists(filepath.Join(dir, name)); kept != (i >= len(made)-2) {
+			t.Errorf("backup %d (%s): kept %v", i, name, kept)
+		}
+	}
+}
+
+// a save through a symlink writes its target and leaves the link, also
+// when the target doesn't exist yet.
+func TestSaveSymlink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		links  [][2]string // link, what it points to
+		target string
+	}{
+		{"existing", [][2]string{{"a", "real/a.txt"}}, "real/a.txt"},
+		{"dangling", [][2]string{{"b", "real/b.txt"}}, "real/b.txt"},
+		{"dangling chain", [][2]string{{"c", "c2"}, {"c2", "real/c.txt"}}, "real/c.txt"},
+	}
+	if err := os.WriteFile(filepath.Join(dir, "real/a.txt"), []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		for _, l := range tt.links {
+			if err := os.Symlink(l[1], filepath.Join(dir, l[0])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		link := filepath.Join(dir, tt.links[0][0])
+		if err := Save(link, NewFromLines([]string{"new"}, link), SaveOptions{}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("%s: the link was replaced", tt.name)
+		}
+		if got, err := os.ReadFile(filepath.Join(dir, tt.target)); err != nil || string(got) != "new\n" {
+			t.Errorf("%s: target holds %q (%v), want %q", tt.name, got, err, "new\n")
+		}
+	}
+}
+
+// a new file gets the permissions the umask leaves, an existing one keeps
+// its own.
+func TestSavePermissions(t *testing.T) {
+	dir := t.TempDir()
+	old := syscall.Umask(0o077)
+	defer syscall.Umask(old)
+
+	path := filepath.Join(dir, "new")
+	if err := Save(path, NewFromLines([]string{"x"}, path), SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Errorf("new file mode %v with umask 077, want 0600", fi.Mode().Perm())
+	}
+
+	syscall.Umask(0o022)
+	path = filepath.Join(dir, "new2")
+	if err := Save(path, NewFromLines([]string{"x"}, path), SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o644 {
+		t.Errorf("new file mode %v with umask 022, want 0644", fi.Mode().Perm())
+	}
+
+	os.Chmod(path, 0o640)
+	if err := Save(path, NewFromLines([]string{"y"}, path), SaveOptions{Mode: WriteAtomic}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o640 {
+		t.Errorf("saved file mode %v, want it kept at 0640", fi.Mode().Perm())
+	}
+}
+
+// a writable file in a directory that takes no new files is written in
+// place.
+func TestSaveReadOnlyDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write any directory")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(dir, 0o555)
+	defer os.Chmod(dir, 0o755)
+
+	if err := Save(path, NewFromLines([]string{"new"}, path), SaveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new\n" {
+		t.Errorf("file holds %q, want %q", got, "new\n")
+	}
+}
+
+// a file is written back byte for byte whatever its line endings, final
+// newline and BOM.
+func TestLoadSaveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		lines  []string
+		format FileFormat
+		eol    bool
+		bom    bool
+	}{
+		{"unix", "one\ntwo\n", []string{"one", "two"}, FormatUnix, true, false},
+		{"dos", "one\r\ntwo\r\n", []string{"one", "two"}, FormatDos, true, false},
+		{"no final newline", "one\ntwo", []string{"one", "two"}, FormatUnix, false, false},
+		{"dos without final newline", "one\r\ntwo", []string{"one", "two"}, FormatDos, false, false},
+		{"mixed endings", "one\r\ntwo\n", []string{"one\r", "two"}, FormatUnix, true, false},
+		{"bom", "\xef\xbb\xbfone\n", []string{"one"}, FormatUnix, true, true},
+		{"bom dos without final newline", "\xef\xbb\xbfone\r\ntwo", []string{"one", "two"}, FormatDos, false, true},
+		{"empty", "", []string{""}, FormatUnix, false, false},
+		{"empty line", "\n", []string{""}, FormatUnix, true, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "f")
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		b, err := Load(path, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := b.GetLines(); !slices.Equal(got, tt.lines) {
+			t.Errorf("%s: lines %q, want %q", tt.name, got, tt.lines)
+		}
+		if b.Format() != tt.format || b.EndOfLine() != tt.eol || b.BOM() != tt.bom {
+			t.Errorf("%s: format %v, eol %v, bom %v, want %v, %v, %v",
+				tt.name, b.Format(), b.EndOfLine(), b.BOM(), tt.format, tt.eol, tt.bom)
+		}
+
+		if err := Save(path, b, SaveOptions{}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != tt.data {
+			t.Errorf("%s: written back as %q, want %q", tt.name, data, tt.data)
+		}
+	}
+}
+
+// the format, final newline and BOM can be changed before writing.
+func TestSaveConvert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(path, []byte("one\ntwo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetFormat(FormatDos)
+	b.SetBOM(true)
+	if err := Save(path, b, SaveOptions{FixEOL: true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "\xef\xbb\xbfone\r\ntwo\r\n" {
+		t.Errorf("written as %q", data)
+	}
+	if b.EndOfLine() {
+		t.Error("fixeol changed the buffer's final newline")
+	}
+}
@@ -0,0 +1,108 @@
+package buffer
+
+// marks are positions that stay with their text while the lines around
+// them change: inserting or deleting lines above a mark moves it up or
+// down, splitting or joining its line carries it along with the
+// characters after it. A mark whose line is deleted is gone.
+
+// a position in the buffer, kept up to date by the buffer's edits.
+type Mark struct {
+	Row, Col int
+	Deleted  bool // the line of the mark was deleted
+}
+
+// returns a new mark at (row, col), which follows the edits of the buffer
+// until it is dropped.
+func (b *Buffer) NewMark(row, col int) *Mark {
+	if b.marks == nil {
+		b.marks = make(map[*Mark]struct{})
+	}
+	m := &Mark{Row: row, Col: col}
+	b.marks[m] = struct{}{}
+	return m
+}
+
+// stops updating m.
+func (b *Buffer) DropMark(m *Mark) {
+	delete(b.marks, m)
+}
+
+// sets the named mark name ('a to 'z) to (row, col).
+func (b *Buffer) SetMark(name rune, row, col int) {
+	if old, ok := b.named[name]; ok {
+		b.DropMark(old)
+	}
+	if b.named == nil {
+		b.named = make(map[rune]*Mark)
+	}
+	b.named[name] = b.NewMark(row, col)
+}
+
+// returns the named mark name, or nil when it isn't set or its line was
+// deleted.
+func (b *Buffer) Mark(name rune) *Mark {
+	m := b.named[name]
+	if m == nil || m.Deleted {
+		return nil
+	}
+	return m
+}
+
+// moves the marks on lines at or after row down by n lines.
+func (b *Buffer) marksInserted(row, n int) {
+	for m := range b.marks {
+		if m.Row >= row {
+			m.Row += n
+		}
+	}
+}
+
+// removes the marks on the deleted line row and moves those below it up.
+func (b *Buffer) marksDeleted(row int) {
+	for m := range b.marks {
+		switch {
+		case m.Row == row:
+			m.Deleted = true
+			delete(b.marks, m)
+		case m.Row > row:
+			m.Row--
+		}
+	}
+}
+
+// moves the marks after column col of row, which was split there, onto
+// the new line below it, and the marks of later lines down.
+func (b *Buffer) marksSplit(row, col int) {
+	for m := range b.marks {
+		switch {
+		case m.Row == row && m.Col >= col:
+			m.Row, m.Col = row+1, m.Col-col
+		case m.Row > row:
+			m.Row++
+		}
+	}
+}
+
+// moves the marks of the line after row, which was joined to it at
+// column col, onto row, and the marks of later lines up.
+func (b *Buffer) marksJoined(row, col int) {
+	for m := range b.marks {
+		switch {
+		case m.Row == row+1:
+			m.Row, m.Col = row, m.Col+col
+		case m.Row > row+1:
+			m.Row--
+		}
+	}
+}
+
+// removes the marks past the last line after the whole text was replaced.
+func (b *Buffer) marksTruncated() {
+	n := b.NumLines()
+	for m := range b.marks {
+		if m.Row >= n {
+			m.Deleted = true
+			delete(b.marks, m)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package buffer
+
+import (
+	"math/rand/v2"
+	"sort"
+)
+
+// piece table storage used by Buffer.
+//
+// Text lives in two byte stores: the original store (file contents, never
+// modified) and the add store (append-only, receives every inserted byte).
+// The document is the in-order concatenation of pieces, each pointing at a
+// span of one store. Pieces are kept in a treap ordered by document position;
+// every node caches the byte length and newline count of its subtree, so
+// locating a byte offset or the start of a line costs O(log n).
+
+const (
+	srcOriginal = iota
+	srcAdd
+)
+
+// holds raw bytes plus the offsets of every '\n' in them.
+type store struct {
+	data     []byte
+	newlines []int // ascending byte offsets of '\n' in data
+}
+
+// appends text to the store, indexing its newlines.
+func (s *store) append(text string) {
+	base := len(s.data)
+	s.data = append(s.data, text...)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			s.newlines = append(s.newlines, base+i)
+		}
+	}
+}
+
+// counts the newlines in data[start:end].
+func (s *store) countNewlines(start, end int) int {
+	return sort.SearchInts(s.newlines, end) - sort.SearchInts(s.newlines, start)
+}
+
+// a span of one store.
+type piece struct {
+	src    int
+	start  int
+	length int
+	lines  int // newlines inside the span
+}
+
+type node struct {
+	p           piece
+	left, right *node
+	prio        uint32
+	size        int // bytes in subtree
+	lines       int // newlines in subtree
+}
+
+func newNode(p piece) *node {
+	n := &node{p: p, prio: rand.Uint32()}
+	n.update()
+	return n
+}
+
+func (n *node) sizeOf() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *node) linesOf() int {
+	if n == nil {
+		return 0
+	}
+	return n.lines
+}
+
+// recomputes the cached aggregates from the children.
+func (n *node) update() {
+	n.size = n.left.sizeOf() + n.p.length + n.right.sizeOf()
+	n.lines = n.left.linesOf() + n.p.lines + n.right.linesOf()
+}
+
+type pieceTable struct {
+	stores [2]store
+	root   *node
+}
+
+// creates a piece table whose original store holds data.
+func newPieceTable(data []byte) *pieceTable {
+	t := &pieceTable{}
+	orig := &t.stores[srcOriginal]
+	orig.data = data
+	for i, c := range data {
+		if c == '\n' {
+			orig.newlines = append(orig.newlines, i)
+		}
+	}
+	if len(data) > 0 {
+		t.root = newNode(piece{src: srcOriginal, length: len(data), lines: len(orig.newlines)})
+	}
+	return t
+}
+
+// returns the document length in bytes.
+func (t *pieceTable) Len() int {
+	return t.root.sizeOf()
+}
+
+// returns the number of '\n' bytes in the document.
+func (t *pieceTable) Newlines() int {
+	return t.root.linesOf()
+}
+
+// joins two treaps where every offset in a precedes every offset in b.
+func merge(a, b *node) *node {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+// splits n into the first off bytes and the rest, cutting a piece if needed.
+func (t *pieceTable) split(n *node, off int) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+
+	ls := n.left.sizeOf()
+	if off <= ls {
+		l, r := t.split(n.left, off)
+		n.left = r
+		n.update()
+		return l, n
+	}
+	if off >= ls+n.p.length {
+		l, r := t.split(n.right, off-ls-n.p.length)
+		n.right = l
+		n.update()
+		return n, r
+	}
+
+	// the cut falls inside this node's piece
+	k := off - ls
+	st := &t.stores[n.p.src]
+	head := piece{src: n.p.src, start: n.p.start, length: k}
+	head.lines = st.countNewlines(head.start, head.start+k)
+	tail := piece{src: n.p.src, start: n.p.start + k, length: n.p.length - k, lines: n.p.lines - head.lines}
+
+	// both halves keep the priority of the piece, so they can't rise above
+	// the nodes it was under
+	h, tl := newNode(head), newNode(tail)
+	h.prio, tl.prio = n.prio, n.prio
+	return merge(n.left, h), merge(tl, n.right)
+}
+
+// inserts text at byte offset off.
+func (t *pieceTable) Insert(off int, text string) {
+	if text == "" {
+		return
+	}
+
+	add := &t.stores[srcAdd]
+	start := len(add.data)
+	add.append(text)
+	p := piece{src: srcAdd, start: start, length: len(text), lines: add.countNewlines(start, start+len(text))}
+
+	l, r := t.split(t.root, off)
+
+	// typing appends to the add store in order, so grow the previous
+	// piece instead of creating a new node per keystroke
+	if last := rightmost(l); last != nil && last.p.src == srcAdd && last.p.start+last.p.length == start {
+		extendRightmost(l, p.length, p.lines)
+		t.root = merge(l, r)
+		return
+	}
+
+	t.root = merge(merge(l, newNode(p)), r)
+}
+
+// deletes length bytes starting at byte offset off.
+func (t *pieceTable) Delete(off, length int) {
+	if length <= 0 {
+		return
+	}
+	l, rest := t.split(t.root, off)
+	_, r := t.split(rest, length)
+	t.root = merge(l, r)
+}
+
+func rightmost(n *node) *node {
+	if n == nil {
+		return nil
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+// grows the last piece of the subtree and refreshes aggregates on the way back up.
+func extendRightmost(n *node, length, lines int) {
+	if n.right != nil {
+		extendRightmost(n.right, length, lines)
+	} else {
+		n.p.length += length
+		n.p.lines += lines
+	}
+	n.update()
+}
+
+// returns the byte offset of the k-th newline (1-based), or -1 if there is none.
+func (t *pieceTable) newlineOffset(k int) int {
+	n := t.root
+	base := 0
+	for n != nil {
+		ll := n.left.linesOf()
+		if k <= ll {
+			n = n.left
+			continue
+		}
+		k -= ll
+		base += n.left.sizeOf()
+
+		if k <= n.p.lines {
+			st := &t.stores[n.p.src]
+			i := sort.SearchInts(st.newlines, n.p.start)
+			return base + st.newlines[i+k-1] - n.p.start
+		}
+		k -= n.p.lines
+		base += n.p.length
+		n = n.right
+	}
+	return -1
+}
+
+// returns the document bytes in [from, to).
+func (t *pieceTable) Read(from, to int) []byte {
+	if to <= from {
+		return nil
+	}
+	return t.appendRange(make([]byte, 0, to-from), t.root, from, to)
+}
+
+// appends the bytes of n's subtree that fall in [from, to), relative to the subtree.
+func (t *pieceTable) appendRange(dst []byte, n *node, from, to int) []byte {
+	if n == nil || to <= 0 || from >= n.size {
+		return dst
+	}
+
+	ls := n.left.sizeOf()
+	if from < ls {
+		dst = t.appendRange(dst, n.left, from, to)
+	}
+
+	// this node's piece occupies [ls, ls+length)
+	s := max(from-ls, 0)
+	e := min(to-ls, n.p.length)
+	if s < e {
+		data := t.stores[n.p.src].data
+		dst = append(dst, data[n.p.start+s:n.p.start+e]...)
+	}
+
+	end := ls + n.p.length
+	if to > end {
+		dst = t.appendRange(dst, n.right, from-end, to-end)
+	}
+	return dst
+}
+
+// returns the bytes of every piece, in document order. The slices stay as
+// they are through later edits: the stores are only ever appended to.
+func (t *pieceTable) Pieces() [][]byte {
+	var pieces [][]byte
+	t.Each(func(p []byte) error {
+		pieces = append(pieces, p)
+		return nil
+	})
+	return pieces
+}
+
+// calls fn with the bytes of every piece, in document order.
+func (t *pieceTable) Each(fn func([]byte) error) error {
+	return t.each(t.root, fn)
+}
+
+func (t *pieceTable) each(n *node, fn func([]byte) error) error {
+	if n == nil {
+		return nil
+	}
+	if err := t.each(n.left, fn); err != nil {
+		return err
+	}
+	data := t.stores[n.p.src].data
+	if err := fn(data[n.p.start : n.p.start+n.p.length]); err != nil {
+		return err
+	}
+	return t.each(n.right, fn)
+}
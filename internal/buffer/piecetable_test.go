@@ -0,0 +1,330 @@
+package buffer
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// the line slice Buffer kept its text in before the piece table, kept here
+// to check the piece table against and to measure it by.
+type lineSlice []string
+
+func (s lineSlice) InsertChar(row, col int, ch rune) {
+	runes := []rune(s[row])
+	s[row] = string(slices.Insert(runes, col, ch))
+}
+
+func (s lineSlice) DeleteChar(row, col int) {
+	runes := []rune(s[row])
+	if col < len(runes) {
+		s[row] = string(slices.Delete(runes, col, col+1))
+	}
+}
+
+func (s lineSlice) InsertLine(row int) lineSlice {
+	return slices.Insert(s, row, "")
+}
+
+func (s lineSlice) DeleteLine(row int) lineSlice {
+	if len(s) == 1 {
+		s[0] = ""
+		return s
+	}
+	return slices.Delete(s, row, row+1)
+}
+
+func (s lineSlice) SplitLine(row, col int) lineSlice {
+	runes := []rune(s[row])
+	s[row] = string(runes[:col])
+	return slices.Insert(s, row+1, string(runes[col:]))
+}
+
+func (s lineSlice) JoinLines(row int) lineSlice {
+	s[row] += s[row+1]
+	return slices.Delete(s, row+1, row+2)
+}
+
+// returns the text of a table, walking its pieces.
+func tableText(t *pieceTable) string {
+	var b strings.Builder
+	t.Each(func(p []byte) error {
+		b.Write(p)
+		return nil
+	})
+	return b.String()
+}
+
+// returns the text of the subtree n of t.
+func subtreeText(t *pieceTable, n *node) string {
+	return string(t.appendRange(nil, n, 0, n.sizeOf()))
+}
+
+// checks the heap order and the cached sizes and newline counts of n.
+func checkTree(t *testing.T, pt *pieceTable, n *node) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+	for _, c := range []*node{n.left, n.right} {
+		if c != nil && c.prio > n.prio {
+			t.Errorf("child priority %d above parent %d", c.prio, n.prio)
+		}
+	}
+	if want := n.left.sizeOf() + n.p.length + n.right.sizeOf(); n.size != want {
+		t.Errorf("size %d, want %d", n.size, want)
+	}
+	st := &pt.stores[n.p.src]
+	if want := st.countNewlines(n.p.start, n.p.start+n.p.length); n.p.lines != want {
+		t.Errorf("piece has %d newlines cached, %d in the store", n.p.lines, want)
+	}
+	if want := n.left.linesOf() + n.p.lines + n.right.linesOf(); n.lines != want {
+		t.Errorf("lines %d, want %d", n.lines, want)
+	}
+	checkTree(t, pt, n.left)
+	checkTree(t, pt, n.right)
+}
+
+// builds a table of several pieces from both stores.
+func mixedTable() (*pieceTable, string) {
+	pt := newPieceTable([]byte("one\ntwo\nthree\n"))
+	pt.Insert(4, "2a\n2b\n")
+	pt.Insert(0, "zero\n")
+	pt.Insert(pt.Len(), "four")
+	pt.Delete(12, 4) // "2b\nt" leaves "wo"
+	return pt, "zero\none\n2a\nwo\nthree\nfour"
+}
+
+func TestPieceTableSplitMerge(t *testing.T) {
+	pt, text := mixedTable()
+	if got := tableText(pt); got != text {
+		t.Fatalf("text %q, want %q", got, text)
+	}
+	for off := 0; off <= len(text); off++ {
+		l, r := pt.split(pt.root, off)
+		if got := subtreeText(pt, l); got != text[:off] {
+			t.Errorf("split at %d: left %q, want %q", off, got, text[:off])
+		}
+		if got := subtreeText(pt, r); got != text[off:] {
+			t.Errorf("split at %d: right %q, want %q", off, got, text[off:])
+		}
+		if got, want := l.linesOf(), strings.Count(text[:off], "\n"); got != want {
+			t.Errorf("split at %d: left has %d newlines, want %d", off, got, want)
+		}
+		checkTree(t, pt, l)
+		checkTree(t, pt, r)
+
+		pt.root = merge(l, r)
+		if got := tableText(pt); got != text {
+			t.Fatalf("merge after split at %d: %q, want %q", off, got, text)
+		}
+		checkTree(t, pt, pt.root)
+	}
+}
+
+func TestPieceTableNewlineOffset(t *testing.T) {
+	pt, text := mixedTable()
+	var want []int
+	for i := range len(text) {
+		if text[i] == '\n' {
+			want = append(want, i)
+		}
+	}
+	if pt.Newlines() != len(want) {
+		t.Fatalf("Newlines() = %d, want %d", pt.Newlines(), len(want))
+	}
+	for k, off := range want {
+		if got := pt.newlineOffset(k + 1); got != off {
+			t.Errorf("newlineOffset(%d) = %d, want %d", k+1, got, off)
+		}
+	}
+	if got := pt.newlineOffset(len(want) + 1); got != -1 {
+		t.Errorf("newlineOffset past the last newline = %d, want -1", got)
+	}
+	if got := newPieceTable(nil).newlineOffset(1); got != -1 {
+		t.Errorf("newlineOffset on an empty table = %d, want -1", got)
+	}
+}
+
+func TestPieceTableRead(t *testing.T) {
+	pt, text := mixedTable()
+	for from := 0; from <= len(text); from++ {
+		for to := from; to <= len(text); to++ {
+			if got := string(pt.Read(from, to)); got != text[from:to] {
+				t.Fatalf("Read(%d, %d) = %q, want %q", from, to, got, text[from:to])
+			}
+		}
+	}
+}
+
+// runs random edits on a Buffer and on a line slice, checking that both
+// hold the same lines after each.
+func TestBufferMixedEdits(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	start := []string{"package main", "", "func main() {", "\tprintln(\"héllo\")", "}"}
+	b := NewFromLines(slices.Clone(start), "")
+	ref := lineSlice(slices.Clone(start))
+	chars := []rune("ab é\t世x")
+
+	for i := range 2000 {
+		row := rng.IntN(len(ref))
+		n := utf8.RuneCountInString(ref[row])
+		var op string
+		var err error
+		switch rng.IntN(7) {
+		case 0, 1:
+			col, ch := rng.IntN(n+1), chars[rng.IntN(len(chars))]
+			op = fmt.Sprintf("InsertChar(%d, %d, %q)", row, col, ch)
+			err = b.InsertChar(row, col, ch)
+			ref.InsertChar(row, col, ch)
+		case 2:
+			col := rng.IntN(n + 1)
+			op = fmt.Sprintf("DeleteChar(%d, %d)", row, col)
+			err = b.DeleteChar(row, col)
+			ref.DeleteChar(row, col)
+		case 3:
+			row = rng.IntN(len(ref) + 1)
+			op = fmt.Sprintf("InsertLine(%d)", row)
+			err = b.InsertLine(row)
+			ref = ref.InsertLine(row)
+		case 4:
+			op = fmt.Sprintf("DeleteLine(%d)", row)
+			err = b.DeleteLine(row)
+			ref = ref.DeleteLine(row)
+		case 5:
+			col := rng.IntN(n + 1)
+			op = fmt.Sprintf("SplitLine(%d, %d)", row, col)
+			err = b.SplitLine(row, col)
+			ref = ref.SplitLine(row, col)
+		case 6:
+			if row == len(ref)-1 {
+				continue
+			}
+			op = fmt.Sprintf("JoinLines(%d)", row)
+			err = b.JoinLines(row)
+			ref = ref.JoinLines(row)
+		}
+		if err != nil {
+			t.Fatalf("edit %d, %s: %v", i, op, err)
+		}
+
+		if b.NumLines() != len(ref) {
+			t.Fatalf("edit %d, %s: NumLines() = %d, want %d", i, op, b.NumLines(), len(ref))
+		}
+		for r, want := range ref {
+			if got, _ := b.GetLine(r); got != want {
+				t.Fatalf("edit %d, %s: line %d is %q, want %q", i, op, r, got, want)
+			}
+			if got, _ := b.LineLength(r); got != utf8.RuneCountInString(want) {
+				t.Fatalf("edit %d, %s: LineLength(%d) = %d, want %d", i, op, r, got, utf8.RuneCountInString(want))
+			}
+		}
+	}
+	checkTree(t, b.text, b.text.root)
+	if _, err := b.GetLine(len(ref)); err == nil {
+		t.Errorf("GetLine past the last line gave no error")
+	}
+}
+
+// --- Benchmarks ---
+//
+// Each runs on a buffer of a million lines, once on the piece table and
+// once on the line slice it replaced, at rows spread over the file.
+
+const benchLines = 1_000_000
+
+func benchText() []string {
+	lines := make([]string, benchLines)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d: the quick brown fox jumps over the lazy dog", i)
+	}
+	return lines
+}
+
+// returns the i-th row of a sequence spread over n lines.
+func benchRow(i, n int) int {
+	return i * 7919 % n
+}
+
+func BenchmarkInsertChar(b *testing.B) {
+	lines := benchText()
+	b.Run("PieceTable", func(b *testing.B) {
+		buf := NewFromLines(lines, "")
+		for i := 0; b.Loop(); i++ {
+			buf.InsertChar(benchRow(i, benchLines), 10, 'x')
+		}
+	})
+	b.Run("LineSlice", func(b *testing.B) {
+		ref := lineSlice(slices.Clone(lines))
+		for i := 0; b.Loop(); i++ {
+			ref.InsertChar(benchRow(i, benchLines), 10, 'x')
+		}
+	})
+}
+
+func BenchmarkDeleteChar(b *testing.B) {
+	lines := benchText()
+	b.Run("PieceTable", func(b *testing.B) {
+		buf := NewFromLines(lines, "")
+		for i := 0; b.Loop(); i++ {
+			buf.DeleteChar(benchRow(i, benchLines), 0)
+		}
+	})
+	b.Run("LineSlice", func(b *testing.B) {
+		ref := lineSlice(slices.Clone(lines))
+		for i := 0; b.Loop(); i++ {
+			ref.DeleteChar(benchRow(i, benchLines), 0)
+		}
+	})
+}
+
+func BenchmarkGetLine(b *testing.B) {
+	lines := benchText()
+	b.Run("PieceTable", func(b *testing.B) {
+		buf := NewFromLines(lines, "")
+		for i := 0; b.Loop(); i++ {
+			buf.GetLine(benchRow(i, benchLines))
+		}
+	})
+	b.Run("LineSlice", func(b *testing.B) {
+		ref := lineSlice(slices.Clone(lines))
+		for i := 0; b.Loop(); i++ {
+			_ = ref[benchRow(i, benchLines)]
+		}
+	})
+}
+
+func BenchmarkInsertLine(b *testing.B) {
+	lines := benchText()
+	b.Run("PieceTable", func(b *testing.B) {
+		buf := NewFromLines(lines, "")
+		for i := 0; b.Loop(); i++ {
+			buf.InsertLine(benchRow(i, benchLines))
+		}
+	})
+	b.Run("LineSlice", func(b *testing.B) {
+		ref := lineSlice(slices.Clone(lines))
+		for i := 0; b.Loop(); i++ {
+			ref = ref.InsertLine(benchRow(i, benchLines))
+		}
+	})
+}
+
+func BenchmarkDeleteLine(b *testing.B) {
+	lines := benchText()
+	b.Run("PieceTable", func(b *testing.B) {
+		buf := NewFromLines(lines, "")
+		for i := 0; b.Loop(); i++ {
+			buf.DeleteLine(benchRow(i, buf.NumLines()))
+		}
+	})
+	b.Run("LineSlice", func(b *testing.B) {
+		ref := lineSlice(slices.Clone(lines))
+		for i := 0; b.Loop(); i++ {
+			ref = ref.DeleteLine(benchRow(i, len(ref)))
+		}
+	})
+}
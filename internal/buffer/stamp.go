@@ -0,0 +1,102 @@
+package buffer
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStamp identifies the version of a file on disk, so the editor can
+// tell when something else (gofmt, git checkout, ...) rewrote it.
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
+}
+
+// reports whether the stamp was never taken (new or unsaved buffers).
+func (s FileStamp) IsZero() bool {
+	return s.ModTime.IsZero() && s.Size == 0
+}
+
+// DiskStatus is the result of comparing a buffer with its file.
+type DiskStatus int
+
+const (
+	DiskUnchanged DiskStatus = iota
+	DiskChanged              // content differs from what was read or written
+	DiskDeleted              // the file is gone
+)
+
+// stamps the file at path by reading and hashing it.
+func StampFile(path string) (FileStamp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileStamp{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return FileStamp{}, err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return FileStamp{}, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	stamp := FileStamp{ModTime: info.ModTime(), Size: info.Size()}
+	h.Sum(stamp.Hash[:0])
+	return stamp, nil
+}
+
+func (b *Buffer) DiskStamp() FileStamp {
+	return b.stamp
+}
+
+func (b *Buffer) SetDiskStamp(stamp FileStamp) {
+	b.stamp = stamp
+}
+
+// compares the file on disk with the version this buffer was read from or
+// last written to, and returns the stamp of what is on disk now. mtime and
+// size are checked first; the file is only hashed when they differ, so a
+// touched but identical file is not reported.
+func (b *Buffer) CheckDisk() (DiskStatus, FileStamp, error) {
+	if b.filePath == "" || b.stamp.IsZero() {
+		return DiskUnchanged, b.stamp, nil
+	}
+
+	path, err := filepath.EvalSymlinks(b.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return DiskDeleted, FileStamp{}, nil
+	}
+	if err != nil {
+		return DiskUnchanged, b.stamp, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return DiskUnchanged, b.stamp, err
+	}
+	if info.ModTime().Equal(b.stamp.ModTime) && info.Size() == b.stamp.Size {
+		return DiskUnchanged, b.stamp, nil
+	}
+
+	stamp, err := StampFile(path)
+	if err != nil {
+		return DiskUnchanged, b.stamp, err
+	}
+	if stamp.Hash != b.stamp.Hash {
+		return DiskChanged, stamp, nil
+	}
+
+	// same content, new mtime: remember it so we don't hash again
+	b.stamp = stamp
+	return DiskUnchanged, stamp, nil
+}
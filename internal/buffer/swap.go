@@ -0,0 +1,208 @@
+package buffer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// swap files hold unsaved buffer contents so they survive a crash.
+//
+// A swap file lives next to the file it protects (".name.swp", then
+// ".name.swo", ".name.swn", ... when that name is taken) and starts with a
+// small text header followed by a blank line and the buffer text:
+//
+//	glime swap 1
+//	pid=4242
+//	host=devbox
+//	file=/home/me/main.go
+//	modified=1
+//
+//	<buffer text>
+//
+// A swap for an unmodified buffer carries no text; it only marks the file
+// as being edited so a second glime can warn about it.
+
+const swapMagic = "glime swap 1"
+
+// SwapInfo is the header of a swap file.
+type SwapInfo struct {
+	Path     string // location of the swap file itself
+	PID      int
+	Host     string
+	FilePath string // absolute path of the edited file
+	Modified bool   // whether the swap holds unsaved text
+	ModTime  time.Time
+}
+
+// returns the swap file name for filePath; n picks the alternative
+// (0 = ".swp", 1 = ".swo", 2 = ".swn", ...).
+func SwapPath(filePath string, n int) string {
+	dir, base := filepath.Split(filePath)
+	return filepath.Join(dir, "."+base+".sw"+string(rune('p'-n)))
+}
+
+// returns the swap files that exist for filePath, in name order.
+func FindSwaps(filePath string) []string {
+	var found []string
+	for n := 0; n < 8; n++ {
+		if p := SwapPath(filePath, n); Exists(p) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// returns the first swap name for filePath that is not in use.
+func FreeSwapPath(filePath string) string {
+	for n := 0; n < 8; n++ {
+		if p := SwapPath(filePath, n); !Exists(p) {
+			return p
+		}
+	}
+	return SwapPath(filePath, 0)
+}
+
+// writes a swap file for filePath. text is the buffer contents and is only
+// stored when modified is set. The swap is written to a temp name and
+// renamed, so a crash mid-write leaves the previous swap intact. Every
+// write has a temp name of its own, so two writes of one swap don't mix.
+func WriteSwap(swapPath, filePath string, modified bool, text *Snapshot) error {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+	host, _ := os.Hostname()
+
+	flag := 0
+	if modified {
+		flag = 1
+	}
+	header := fmt.Sprintf("%s\npid=%d\nhost=%s\nfile=%s\nmodified=%d\n\n",
+		swapMagic, os.Getpid(), host, abs, flag)
+
+	file, err := os.CreateTemp(filepath.Dir(swapPath), filepath.Base(swapPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create swap file: %w", err)
+	}
+	tmp := file.Name()
+
+	w := bufio.NewWriter(file)
+	w.WriteString(header)
+	if modified && text != nil {
+		text.WriteTo(w)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write swap file: %w", err)
+	}
+
+	if err := os.Rename(tmp, swapPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write swap file: %w", err)
+	}
+	return nil
+}
+
+// reads the header of a swap file.
+func ReadSwapInfo(swapPath string) (*SwapInfo, error) {
+	file, err := os.Open(swapPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readSwapHeader(swapPath, bufio.NewReader(file))
+}
+
+// reads a swap file and returns its header and the recovered text.
+func ReadSwap(swapPath string) (*SwapInfo, []byte, error) {
+	file, err := os.Open(swapPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	info, err := readSwapHeader(swapPath, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read swap file: %w", err)
+	}
+	return info, text, nil
+}
+
+// parses the header lines up to the blank separator line.
+func readSwapHeader(swapPath string, r *bufio.Reader) (*SwapInfo, error) {
+	magic, err := r.ReadString('\n')
+	if err != nil || strings.TrimSuffix(magic, "\n") != swapMagic {
+		return nil, fmt.Errorf("%s is not a glime swap file", swapPath)
+	}
+
+	info := &SwapInfo{Path: swapPath}
+	if st, err := os.Stat(swapPath); err == nil {
+		info.ModTime = st.ModTime()
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, errors.New("truncated swap file header")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return info, nil
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "pid":
+			info.PID, _ = strconv.Atoi(value)
+		case "host":
+			info.Host = value
+		case "file":
+			info.FilePath = value
+		case "modified":
+			info.Modified = value == "1"
+		}
+	}
+}
+
+// reports whether the process that wrote the swap is still alive.
+// Processes on other hosts are assumed to be running.
+func (s *SwapInfo) ProcessRunning() bool {
+	if host, _ := os.Hostname(); s.Host != host {
+		return true
+	}
+	if s.PID <= 0 || s.PID == os.Getpid() {
+		return false
+	}
+	err := syscall.Kill(s.PID, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// deletes a swap file; a missing file is not an error.
+func RemoveSwap(swapPath string) error {
+	if err := os.Remove(swapPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove swap file: %w", err)
+	}
+	return nil
+}
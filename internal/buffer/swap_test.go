@@ -0,0 +1,80 @@
+package buffer
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// a snapshot keeps the text it was taken with through later edits, also
+// those that grow the piece it ends with.
+func TestSnapshot(t *testing.T) {
+	b := NewFromLines([]string{"one", "two"}, "")
+	b.InsertChar(1, 3, '!')
+	snap := b.Snapshot()
+
+	// written while the buffer is being edited, as the swap writer does
+	path := filepath.Join(t.TempDir(), ".f.swp")
+	written := make(chan error)
+	go func() { written <- WriteSwap(path, "f", true, snap) }()
+	for i := range 100 {
+		b.InsertChar(1, 4+i, '?')
+	}
+	b.DeleteLine(0)
+	b.SetText([]byte("other"))
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	info, text, err := ReadSwap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Modified || string(text) != "one\ntwo!" {
+		t.Errorf("swap holds %q (modified %v), want %q", text, info.Modified, "one\ntwo!")
+	}
+}
+
+func TestWriteSwapUnmodified(t *testing.T) {
+	b := NewFromLines([]string{"one"}, "")
+	path := filepath.Join(t.TempDir(), ".f.swp")
+	if err := WriteSwap(path, "f", false, b.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	info, text, err := ReadSwap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Modified || len(text) != 0 {
+		t.Errorf("unmodified swap holds %q (modified %v), want no text", text, info.Modified)
+	}
+}
+
+// writes of one swap at the same time each leave a whole file.
+func TestWriteSwapConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".f.swp")
+	texts := []string{strings.Repeat("a\n", 10000), strings.Repeat("b\n", 20000)}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := NewFromLines(strings.Split(texts[i%2], "\n"), "")
+			if err := WriteSwap(path, "f", true, b.Snapshot()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, text, err := ReadSwap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != texts[0] && string(text) != texts[1] {
+		t.Errorf("swap holds %d bytes mixed from two writes", len(text))
+	}
+	if tmps, _ := filepath.Glob(path + ".*.tmp"); len(tmps) > 0 {
+		t.Errorf("temp files left behind: %v", tmps)
+	}
+}
@@ -68,6 +68,20 @@ func (c *Cursor) MoveTo(row, col int, buff *buffer.Buffer) {
 	c.desiredCol = col
 }
 
+// pulls the cursor back inside the buffer after it shrank, e.g. when the
+// buffer was edited through another window. The desired column is kept.
+func (c *Cursor) Clamp(buf *buffer.Buffer) {
+	if c.row >= buf.NumLines() {
+		c.row = buf.NumLines() - 1
+	}
+	if c.row < 0 {
+		c.row = 0
+	}
+	if lineLen, _ := buf.LineLength(c.row); c.col > lineLen {
+		c.col = lineLen
+	}
+}
+
 // adjust the column to be in lines bound
 // maintain desired column while moving up and down
 func (c *Cursor) clampColumn(buff *buffer.Buffer) {
@@ -185,23 +199,70 @@ func (c *Cursor) PageDown(buf *buffer.Buffer, pageSize int) {
 	c.clampColumn(buf)
 }
 
-// update the scroll offset to ensure the cursor is visible
-//   - screenRows: number of visible rows (terminal height minus status bars)
-//   - screenCols: number of visible columns (terminal width)
-func (c *Cursor) UpdateScroll(screenRows, screenCols int) {
-	// vertical scrolling
+// scrolls the view n rows down (up when negative) over the buffer. The
+// cursor stays where it is unless that would leave it out of view.
+func (c *Cursor) Scroll(n, screenRows int, buf *buffer.Buffer) {
+	c.rowOffset = max(min(c.rowOffset+n, buf.NumLines()-1), 0)
 	if c.row < c.rowOffset {
-		c.rowOffset = c.row
+		c.row = c.rowOffset
+		c.clampColumn(buf)
 	}
 	if c.row >= c.rowOffset+screenRows {
-		c.rowOffset = c.row - screenRows + 1
+		c.row = c.rowOffset + screenRows - 1
+		c.clampColumn(buf)
+	}
+}
+
+// describes the window a cursor moves in, for keeping the cursor in view.
+type View struct {
+	Rows       int // visible text rows
+	Cols       int // visible text columns
+	ScrollOff  int // lines kept in view above and below the cursor
+	Lines      int // lines in the buffer
+	DisplayCol int // screen column of the cursor in its line, tabs expanded
+
+	// screen rows each line takes when long lines wrap; nil when they
+	// scroll sideways instead
+	LineRows func(row int) int
+}
+
+// update the scroll offsets to ensure the cursor is visible, with
+// ScrollOff lines around it where the buffer has them
+func (c *Cursor) UpdateScroll(v View) {
+	so := min(v.ScrollOff, (v.Rows-1)/2)
+	above := min(so, c.row)
+	below := max(min(so, v.Lines-1-c.row), 0)
+
+	// vertical scrolling
+	if c.row-above < c.rowOffset {
+		c.rowOffset = c.row - above
+	}
+	if c.row+below >= c.rowOffset+v.Rows {
+		c.rowOffset = c.row + below - v.Rows + 1
+	}
+
+	if v.LineRows != nil {
+		// wrapped lines may take more than a row each: scroll on until
+		// the lines from the top down to below the cursor fit
+		c.colOffset = 0
+		for c.rowOffset < c.row {
+			used := 0
+			for row := c.rowOffset; row <= c.row+below; row++ {
+				used += v.LineRows(row)
+			}
+			if used <= v.Rows {
+				break
+			}
+			c.rowOffset++
+		}
+		return
 	}
 
 	// Horizontal scrolling
-	if c.col < c.colOffset {
-		c.colOffset = c.col
+	if v.DisplayCol < c.colOffset {
+		c.colOffset = v.DisplayCol
 	}
-	if c.col >= c.colOffset+screenCols {
-		c.colOffset = c.col - screenCols + 1
+	if v.DisplayCol >= c.colOffset+v.Cols {
+		c.colOffset = v.DisplayCol - v.Cols + 1
 	}
 }
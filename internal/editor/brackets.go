@@ -1,5 +1,7 @@
 package editor
 
+import "github.com/AdityaKrSingh26/Glime/internal/buffer"
+
 // defines an opening and closing bracket pair.
 type BracketPair struct {
 	Open  rune
@@ -21,11 +23,12 @@ var bracketPairs = []BracketPair{
 // finds the matching bracket for the character at (row, col).
 // col is a rune (code-point) index, not a byte index.
 // returns nil if the character is not a bracket or no match is found.
-func FindMatchingBracket(lines []string, row, col int) *BracketMatch {
-	if row < 0 || row >= len(lines) {
+func FindMatchingBracket(buf *buffer.Buffer, row, col int) *BracketMatch {
+	line, err := buf.GetLine(row)
+	if err != nil {
 		return nil
 	}
-	runes := []rune(lines[row])
+	runes := []rune(line)
 	if col < 0 || col >= len(runes) {
 		return nil
 	}
@@ -35,10 +38,10 @@ func FindMatchingBracket(lines []string, row, col int) *BracketMatch {
 	// check if it is an opening or closing bracket
 	for _, pair := range bracketPairs {
 		if ch == pair.Open {
-			return scanForward(lines, row, col, pair.Open, pair.Close)
+			return scanForward(buf, row, col, pair.Open, pair.Close)
 		}
 		if ch == pair.Close {
-			return scanBackward(lines, row, col, pair.Close, pair.Open)
+			return scanBackward(buf, row, col, pair.Close, pair.Open)
 		}
 	}
 
@@ -47,11 +50,12 @@ func FindMatchingBracket(lines []string, row, col int) *BracketMatch {
 
 // scans forward from (row, col) to find the matching close bracket.
 // all column indices are rune offsets.
-func scanForward(lines []string, startRow, startCol int, open, close rune) *BracketMatch {
+func scanForward(buf *buffer.Buffer, startRow, startCol int, open, close rune) *BracketMatch {
 	depth := 0
 
-	for row := startRow; row < len(lines); row++ {
-		runes := []rune(lines[row])
+	for row := startRow; row < buf.NumLines(); row++ {
+		line, _ := buf.GetLine(row)
+		runes := []rune(line)
 		startC := 0
 		if row == startRow {
 			startC = startCol
@@ -78,11 +82,12 @@ func scanForward(lines []string, startRow, startCol int, open, close rune) *Brac
 
 // scans backward from (row, col) to find the matching open bracket.
 // all column indices are rune offsets.
-func scanBackward(lines []string, startRow, startCol int, close, open rune) *BracketMatch {
+func scanBackward(buf *buffer.Buffer, startRow, startCol int, close, open rune) *BracketMatch {
 	depth := 0
 
 	for row := startRow; row >= 0; row-- {
-		runes := []rune(lines[row])
+		line, _ := buf.GetLine(row)
+		runes := []rune(line)
 		endC := len(runes) - 1
 		if row == startRow {
 			endC = startCol
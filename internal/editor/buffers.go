@@ -0,0 +1,371 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+	"github.com/AdityaKrSingh26/Glime/internal/cursor"
+	"github.com/AdityaKrSingh26/Glime/internal/syntax"
+)
+
+// the buffer list: every open file is a bufferEntry carrying the state that
+// belongs to that file (undo history, search, language, swap file). Windows
+// embed the entry they show, so e.buffer, e.undoMgr, ... always refer to
+// the buffer being edited.
+
+type bufferEntry struct {
+	id          int // buffer number shown by :ls and used by :b N
+	buffer      *buffer.Buffer
+	lastCursor  cursor.Cursor // where the cursor was when the buffer was last left
+	undoMgr     *UndoManager
+	search      SearchState
+	highlighter *syntax.Highlighter // nil for plain text
+	swap        swapState
+	diskNotice  diskNotice    // last external change reported
+	lastVisual  visualState   // the last visual selection (gv, '<,'>)
+	bufOpts     bufferOptions // buffer-local options (:setlocal)
+}
+
+// creates a buffer list entry for buf; it still has to be added to the list.
+func (e *Editor) newBufferEntry(buf *buffer.Buffer) *bufferEntry {
+	e.lastBufferID++
+	return &bufferEntry{
+		id:          e.lastBufferID,
+		buffer:      buf,
+		undoMgr:     NewUndoManager(e.undoLevels),
+		highlighter: e.renderer.Highlighter(buf.FilePath()),
+		bufOpts:     e.bufDefaults,
+	}
+}
+
+// reports whether b is the untouched empty buffer glime starts with, which
+// is replaced by the first file opened.
+func (b *bufferEntry) isScratch() bool {
+	return b.buffer.FilePath() == "" && !b.buffer.IsModified() && b.buffer.IsEmpty()
+}
+
+// returns the entry for filePath, or nil when the file is not open.
+func (e *Editor) findBuffer(filePath string) *bufferEntry {
+	want, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil
+	}
+	for _, b := range e.buffers {
+		if b.buffer.FilePath() == "" {
+			continue
+		}
+		if have, err := filepath.Abs(b.buffer.FilePath()); err == nil && have == want {
+			return b
+		}
+	}
+	return nil
+}
+
+// returns the position of b in the buffer list, or -1.
+func (e *Editor) bufferIndex(b *bufferEntry) int {
+	for i, entry := range e.buffers {
+		if entry == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// makes b the current buffer and remembers the previous one as the
+// alternate buffer (Ctrl-^).
+func (e *Editor) switchBuffer(b *bufferEntry) {
+	if b == e.bufferEntry {
+		return
+	}
+
+	// the buffer we leave keeps its swap up to date
+	if e.swapPending() {
+		e.writeSwap()
+	}
+	e.pending.Reset()
+
+	e.alternate = e.bufferEntry
+	e.showBuffer(b)
+	e.setMessage(e.bufferInfo())
+	e.checkDisk()
+}
+
+// shows b in the current window, at the cursor position b was last left at.
+func (e *Editor) showBuffer(b *bufferEntry) {
+	e.window.show(b)
+}
+
+// makes w show b, at the cursor position b was last left at.
+func (w *window) show(b *bufferEntry) {
+	w.bufferEntry.lastCursor = *w.cursor
+	w.bufferEntry = b
+	c := b.lastCursor
+	w.cursor = &c
+}
+
+// describes the current buffer for the message bar, e.g. `"main.go" 120L`.
+func (e *Editor) bufferInfo() string {
+	name := e.buffer.FilePath()
+	if name == "" {
+		name = "[No Name]"
+	}
+	info := fmt.Sprintf("\"%s\" %s%dL", name, formatFlags(e.buffer), e.buffer.NumLines())
+	if e.buffer.IsModified() {
+		info += " [Modified]"
+	}
+	return info
+}
+
+// opens filePath in the buffer list (:e path). An already open file is
+// just switched to; otherwise it is loaded into a new buffer, which takes
+// the place of the empty startup buffer if that was never used.
+func (e *Editor) editFile(filePath string) error {
+	if b := e.findBuffer(filePath); b != nil {
+		e.switchBuffer(b)
+		return nil
+	}
+
+	var buf *buffer.Buffer
+	var msg string
+	if !buffer.Exists(filePath) {
+		buf = buffer.New()
+		buf.SetFilePath(filePath)
+		msg = fmt.Sprintf("\"%s\" [New File]", filePath)
+	} else {
+		var err error
+		buf, err = buffer.Load(filePath, e.loadProgress(filePath))
+		if err != nil {
+			return fmt.Errorf("Error loading file into editor: %w", err)
+		}
+		msg = fmt.Sprintf("\"%s\" %s%dL", filePath, formatFlags(buf), buf.NumLines())
+	}
+
+	b := e.newBufferEntry(buf)
+	if prev := e.bufferEntry; prev.isScratch() && e.windowsShowing(prev) == 1 {
+		// the unused startup buffer makes way for the file
+		b.id = prev.id
+		e.lastBufferID--
+		e.buffers[e.bufferIndex(prev)] = b
+		e.showBuffer(b)
+	} else {
+		e.buffers = append(e.buffers, b)
+		e.switchBuffer(b)
+	}
+
+	if e.isLargeFile() {
+		msg += " [large file: highlighting and bracket matching off]"
+	}
+	e.setMessage(msg)
+	e.openSwap(filePath)
+	return nil
+}
+
+// takes b off the buffer list without switching buffers.
+func (e *Editor) removeBuffer(b *bufferEntry) {
+	if i := e.bufferIndex(b); i >= 0 {
+		e.buffers = append(e.buffers[:i], e.buffers[i+1:]...)
+	}
+	if e.alternate == b {
+		e.alternate = nil
+	}
+}
+
+// looks up a buffer by number or by (part of) its name, as :b and :bd take it.
+func (e *Editor) lookupBuffer(arg string) (*bufferEntry, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		for _, b := range e.buffers {
+			if b.id == n {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("buffer %d does not exist", n)
+	}
+
+	var found []*bufferEntry
+	for _, b := range e.buffers {
+		path := b.buffer.FilePath()
+		if path == arg || b.buffer.FileName() == arg {
+			return b, nil // exact match wins
+		}
+		if path != "" && strings.Contains(path, arg) {
+			found = append(found, b)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no matching buffer for %s", arg)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("more than one match for %s", arg)
+}
+
+// --- Buffer commands ---
+
+// switches to the buffer given by number or name (:b N, :b name).
+func (e *Editor) commandBuffer(arg string) error {
+	if arg == "" {
+		e.setMessage(e.bufferInfo())
+		return nil
+	}
+	b, err := e.lookupBuffer(arg)
+	if err != nil {
+		e.setMessage(err.Error())
+		return nil
+	}
+	e.switchBuffer(b)
+	return nil
+}
+
+// moves count buffers forward (or backward when count is negative) in
+// the list, wrapping around (:bn, :bp).
+func (e *Editor) commandBufferNext(count int) error {
+	n := len(e.buffers)
+	i := e.bufferIndex(e.bufferEntry)
+	e.switchBuffer(e.buffers[((i+count)%n+n)%n])
+	return nil
+}
+
+// switches to the alternate buffer, or to buffer count if one is given (Ctrl-^).
+func (e *Editor) switchAlternate(count int, hasCount bool) {
+	if hasCount {
+		if err := e.commandBuffer(strconv.Itoa(count)); err != nil {
+			e.setMessage(err.Error())
+		}
+		return
+	}
+	if e.alternate == nil {
+		e.setMessage("No alternate file")
+		return
+	}
+	e.switchBuffer(e.alternate)
+}
+
+// removes a buffer from the list (:bd). Buffers with unsaved changes are
+// kept unless force is set. Deleting the current buffer switches to the
+// alternate one (or a neighbour); deleting the last one leaves an empty buffer.
+func (e *Editor) commandBufferDelete(arg string, force bool) error {
+	b := e.bufferEntry
+	if arg != "" {
+		var err error
+		if b, err = e.lookupBuffer(arg); err != nil {
+			e.setMessage(err.Error())
+			return nil
+		}
+	}
+
+	if !force && b.buffer.IsModified() {
+		e.setMessage(fmt.Sprintf("No write since last change for buffer %d (add ! to override)", b.id))
+		return nil
+	}
+
+	e.releaseSwap(b)
+
+	next := e.alternate
+	if next == nil || next == b {
+		i := e.bufferIndex(b)
+		switch {
+		case i+1 < len(e.buffers):
+			next = e.buffers[i+1]
+		case i > 0:
+			next = e.buffers[i-1]
+		default:
+			next = e.newBufferEntry(buffer.New())
+			e.buffers = append(e.buffers, next)
+		}
+	}
+
+	// windows showing the buffer move on to another one
+	for _, w := range e.allWindows() {
+		if w.bufferEntry == b {
+			w.show(next)
+		}
+	}
+
+	shown := b == e.bufferEntry
+	e.removeBuffer(b)
+	if e.bufferEntry == next && shown {
+		e.alternate = nil
+		e.setMessage(e.bufferInfo())
+		e.checkDisk()
+	} else {
+		e.setMessage(fmt.Sprintf("Buffer %d deleted", b.id))
+	}
+	return nil
+}
+
+// lists the open buffers (:ls). Flags follow Vim: % current, # alternate,
+// + modified.
+func (e *Editor) commandListBuffers() error {
+	lines := make([]string, 0, len(e.buffers))
+	for _, b := range e.buffers {
+		flag := " "
+		switch b {
+		case e.bufferEntry:
+			flag = "%"
+		case e.alternate:
+			flag = "#"
+		}
+		modified := " "
+		if b.buffer.IsModified() {
+			modified = "+"
+		}
+		name := b.buffer.FilePath()
+		if name == "" {
+			name = "[No Name]"
+		}
+		row := b.lastCursor.Row()
+		if b == e.bufferEntry {
+			row = e.cursor.Row()
+		}
+		lines = append(lines, fmt.Sprintf("%3d %s %s %-30s line %d",
+			b.id, flag, modified, "\""+name+"\"", row+1))
+	}
+	e.showOutput(lines)
+	return nil
+}
+
+// returns a buffer with unsaved changes, preferring the current one, or nil.
+func (e *Editor) modifiedBuffer() *bufferEntry {
+	if e.buffer.IsModified() {
+		return e.bufferEntry
+	}
+	for _, b := range e.buffers {
+		if b.buffer.IsModified() {
+			return b
+		}
+	}
+	return nil
+}
+
+// writes every modified buffer (:wa).
+func (e *Editor) commandWriteAll(force bool) error {
+	current := e.bufferEntry
+	defer func() { e.bufferEntry = current }()
+
+	written := 0
+	for _, b := range e.buffers {
+		if !b.buffer.IsModified() {
+			continue
+		}
+		if b.buffer.FilePath() == "" {
+			e.setMessage(fmt.Sprintf("No file name for buffer %d", b.id))
+			return nil
+		}
+
+		e.bufferEntry = b
+		if err := e.commandWrite(force); err != nil {
+			return err
+		}
+		if b.buffer.IsModified() {
+			return nil // commandWrite explained why
+		}
+		written++
+	}
+
+	e.setMessage(fmt.Sprintf("%d buffer(s) written", written))
+	return nil
+}
@@ -0,0 +1,199 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AdityaKrSingh26/Glime/pkg/ansi"
+)
+
+// the "+ (clipboard) and "* (primary selection) registers reach the system
+// clipboard. Writing one sends the text to the terminal as an OSC 52
+// sequence, which works over SSH and in tmux (with set-clipboard on), and
+// also hands it to wl-copy, xclip or pbcopy when one is found. Reading asks
+// wl-paste, xclip or pbpaste; without them glime pastes the text it last
+// wrote itself, and the terminal's own paste goes through bracketed paste.
+// Copies with a tool run in the background, so a slow one doesn't hold up
+// every yank and delete under :set clipboard.
+
+// how long a clipboard tool may take before glime gives up on it.
+const clipboardTimeout = 2 * time.Second
+
+// runs clipboard tools on goroutines of their own, one at a time. A copy
+// overtaken by a newer one to the same register while it waited is
+// dropped, so the clipboard ends up with the last text.
+type clipboardCopier struct {
+	run    sync.Mutex       // held while a tool runs
+	latest [2]atomic.Uint64 // number of the newest copy to "+ and to "*
+	errs   chan string      // failures, shown on the message line when the screen is next drawn
+}
+
+func newClipboardCopier() *clipboardCopier {
+	return &clipboardCopier{errs: make(chan string, 1)}
+}
+
+// copies text to clipboard register name with the tool args, in the
+// background.
+func (c *clipboardCopier) copy(name rune, args []string, text string) {
+	newest := &c.latest[0]
+	if name == '*' {
+		newest = &c.latest[1]
+	}
+	n := newest.Add(1)
+
+	go func() {
+		c.run.Lock()
+		defer c.run.Unlock()
+		if newest.Load() != n {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			select {
+			case c.errs <- fmt.Sprintf("%s: %v", args[0], err):
+			default: // one failure is enough to tell
+			}
+		}
+	}()
+}
+
+// returns the failure of a background copy, if one came in.
+func (c *clipboardCopier) failure() (string, bool) {
+	select {
+	case msg := <-c.errs:
+		return msg, true
+	default:
+		return "", false
+	}
+}
+
+// reports whether name is one of the clipboard registers.
+func isClipboardRegister(name rune) bool {
+	return name == '+' || name == '*'
+}
+
+// returns the register plain yanks, deletes and pastes use instead of the
+// unnamed one: "+ with clipboard=unnamedplus, "* with clipboard=unnamed,
+// 0 otherwise.
+func (e *Editor) clipboardRegister() rune {
+	for _, v := range strings.Split(e.clipboard, ",") {
+		if v == "unnamedplus" {
+			return '+'
+		}
+	}
+	for _, v := range strings.Split(e.clipboard, ",") {
+		if v == "unnamed" {
+			return '*'
+		}
+	}
+	return 0
+}
+
+// checks a value for :set clipboard.
+func parseClipboardOption(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v != "" && v != "unnamed" && v != "unnamedplus" {
+			return fmt.Errorf("invalid clipboard: %s", value)
+		}
+	}
+	return nil
+}
+
+// puts reg into clipboard register name and copies it to the system
+// clipboard. Lines end in a line break there, so other programs paste
+// them as whole lines.
+func (e *Editor) writeClipboard(name rune, reg Register) {
+	e.putRegister(name, reg)
+
+	text := reg.Content
+	if reg.Type == RegisterLine {
+		text += "\n"
+	}
+	selection := byte('c')
+	if name == '*' {
+		selection = 'p'
+	}
+	e.terminal.Write(ansi.SetClipboard(selection, text))
+
+	if args := clipboardCommand(name, false); args != nil {
+		e.clipCopier.copy(name, args, text)
+	}
+}
+
+// returns the content of clipboard register name. Text read back that
+// glime wrote itself keeps its type; other text ending in a line break is
+// taken as lines.
+func (e *Editor) readClipboard(name rune) Register {
+	own := e.registers[name]
+	args := clipboardCommand(name, true)
+	if args == nil {
+		return own
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clipboardTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	if err != nil {
+		return own
+	}
+
+	text := strings.ReplaceAll(string(out), "\r\n", "\n")
+	if own.Type == RegisterLine && text == own.Content+"\n" || own.Type != RegisterLine && text == own.Content {
+		return own
+	}
+	if trimmed, ok := strings.CutSuffix(text, "\n"); ok {
+		return Register{Content: trimmed, Type: RegisterLine}
+	}
+	return Register{Content: text}
+}
+
+// returns the command line of the clipboard tool that copies to (or with
+// paste, prints) clipboard register name, or nil when none is installed.
+// Wayland and X11 tools are only used inside their session.
+func clipboardCommand(name rune, paste bool) []string {
+	primary := name == '*'
+	var candidates [][]string
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		args := []string{"wl-copy"}
+		if paste {
+			args = []string{"wl-paste", "--no-newline"}
+		}
+		if primary {
+			args = append(args, "--primary")
+		}
+		candidates = append(candidates, args)
+	}
+	if os.Getenv("DISPLAY") != "" {
+		selection := "clipboard"
+		if primary {
+			selection = "primary"
+		}
+		args := []string{"xclip", "-selection", selection}
+		if paste {
+			args = append(args, "-o")
+		}
+		candidates = append(candidates, args)
+	}
+	if paste {
+		candidates = append(candidates, []string{"pbpaste"})
+	} else {
+		candidates = append(candidates, []string{"pbcopy"})
+	}
+
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err == nil {
+			return args
+		}
+	}
+	return nil
+}
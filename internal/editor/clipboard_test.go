@@ -0,0 +1,72 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// installs a fake pbcopy that runs script, as the only clipboard tool.
+func fakeClipboardTool(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pbcopy"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+}
+
+// a slow clipboard tool doesn't hold up yanks, and the clipboard ends up
+// with the last text copied.
+func TestClipboardCopyInBackground(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "clip")
+	fakeClipboardTool(t, "sleep 0.3\ncat > "+out+"\n")
+	e := newTestEditor(t, "one", "two", "three")
+	e.clipCopier = newClipboardCopier()
+	e.clipboard = "unnamedplus"
+
+	start := time.Now()
+	for range 3 {
+		typeKeys(t, e, runeKeys("yyj")...)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("three yanks took %v, the tool held them up", d)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := os.ReadFile(out)
+		if string(got) == "three\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clipboard holds %q, want %q", got, "three\n")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClipboardCopyFailure(t *testing.T) {
+	fakeClipboardTool(t, "exit 3\n")
+	e := newTestEditor(t, "one")
+	e.clipCopier = newClipboardCopier()
+	e.writeClipboard('+', Register{Content: "one"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if msg, ok := e.clipCopier.failure(); ok {
+			if !strings.HasPrefix(msg, "pbcopy: ") {
+				t.Errorf("failure %q, want it to name pbcopy", msg)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no failure reported")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -2,12 +2,40 @@ package editor
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/AdityaKrSingh26/Glime/internal/buffer"
 )
 
+// the window commands, with the shortest abbreviation of each Vim takes
+// (:sp[lit], :vs[plit], ...).
+var windowCommands = []struct{ name, short string }{
+	{"split", "sp"},
+	{"vsplit", "vs"},
+	{"vnew", "vne"},
+	{"close", "clo"},
+	{"only", "on"},
+	{"resize", "res"},
+}
+
+// returns the full name of the window command name abbreviates, keeping a
+// trailing !, or name itself.
+func windowCommandName(name string) string {
+	base, bang := strings.CutSuffix(name, "!")
+	for _, wc := range windowCommands {
+		if abbreviates(base, wc.name, wc.short) {
+			if bang {
+				return wc.name + "!"
+			}
+			return wc.name
+		}
+	}
+	return name
+}
+
 // execute command in command mode, command start with ":" and executed when enter is pressed
 func (e *Editor) executeCommand(cmd string) error {
 	// remove leading ":"
@@ -18,32 +46,161 @@ func (e *Editor) executeCommand(cmd string) error {
 		return nil
 	}
 
+	// a range, or a command that works on lines (see excommand.go)
+	c, err := parseCommand(cmd)
+	if err != nil {
+		return err
+	}
+	if len(c.addrs) > 0 || findRangeCommand(c.name) != nil {
+		return e.runRangeCommand(c)
+	}
+
 	parts := strings.Fields(cmd)
+
+	// :vertical makes the following split, new or resize side by side
+	vertical := false
+	if (parts[0] == "vert" || parts[0] == "vertical") && len(parts) > 1 {
+		vertical = true
+		parts = parts[1:]
+	}
+
 	command := parts[0]
+	arg := strings.Join(parts[1:], " ")
 
-	switch command {
-	case "q":
-		return e.commandQuit(false)
-	case "q!":
-		return e.commandQuit(true)
-	case "w":
-		if len(parts) > 1 {
-			return e.commandWriteAs(parts[1])
+	// :b3 and :bd3 take the buffer number without a space
+	if strings.HasPrefix(command, "b") {
+		if i := strings.IndexAny(command, "0123456789"); i > 0 && isDigits(command[i:]) {
+			command, arg = command[:i], command[i:]
 		}
-		return e.commandWrite()
-	case "wq":
+	}
+
+	command = windowCommandName(command)
+
+	switch command {
+	case "q", "quit", "q!", "quit!":
+		return e.commandQuit(strings.HasSuffix(command, "!"))
+	case "qa", "qall", "qa!", "qall!":
+		return e.commandQuitAll(strings.HasSuffix(command, "!"))
+	case "wq", "wq!":
+		force := command == "wq!"
 		if len(parts) > 1 {
-			if err := e.commandWriteAs(parts[1]); err != nil {
+			if err := e.commandWriteAs(parts[1], force); err != nil {
 				return err
 			}
 			if !e.buffer.IsModified() {
-				e.shouldQuit = true
+				return e.commandQuit(force)
 			}
 			return nil
 		}
-		return e.commandWriteQuit()
+		return e.commandWriteQuit(force)
 	case "x":
-		return e.commandWriteQuit() // Same as :wq
+		return e.commandWriteQuit(false) // Same as :wq
+	case "wa", "wall", "wa!", "wall!":
+		return e.commandWriteAll(strings.HasSuffix(command, "!"))
+	case "wqa", "wqall", "xa", "xall":
+		if err := e.commandWriteAll(false); err != nil {
+			return err
+		}
+		if e.modifiedBuffer() == nil {
+			return e.commandQuitAll(false)
+		}
+		return nil
+	case "e", "edit":
+		if arg != "" {
+			return e.editFile(arg)
+		}
+		if e.buffer.IsModified() {
+			e.setMessage("No write since last change (add ! to override)")
+			return nil
+		}
+		return e.reloadFile()
+	case "e!", "edit!":
+		if arg != "" {
+			return e.editFile(arg)
+		}
+		return e.reloadFile()
+	case "ls", "buffers", "files":
+		return e.commandListBuffers()
+	case "b", "buffer":
+		return e.commandBuffer(arg)
+	case "bn", "bnext", "bp", "bprevious", "bN", "bNext":
+		count := 1
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid count: %s", arg)
+			}
+			count = n
+		}
+		if command != "bn" && command != "bnext" {
+			count = -count
+		}
+		return e.commandBufferNext(count)
+	case "bd", "bdelete", "bd!", "bdelete!":
+		return e.commandBufferDelete(arg, strings.HasSuffix(command, "!"))
+	case "split":
+		return e.commandSplit(vertical, arg)
+	case "vsplit":
+		return e.commandSplit(true, arg)
+	case "new":
+		return e.commandNew(vertical)
+	case "vnew":
+		return e.commandNew(true)
+	case "close", "close!":
+		return e.commandClose()
+	case "only", "only!":
+		e.onlyWindow()
+		return nil
+	case "resize":
+		return e.commandResize(vertical, arg)
+	case "tabnew", "tabe", "tabedit":
+		return e.commandTabNew(arg)
+	case "tabc", "tabclose", "tabc!", "tabclose!":
+		return e.commandTabClose(arg)
+	case "tabo", "tabonly", "tabo!", "tabonly!":
+		return e.commandTabOnly()
+	case "tabn", "tabnext":
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("invalid tab page: %s", arg)
+			}
+			e.tabNext(n, true)
+			return nil
+		}
+		e.tabNext(1, false)
+		return nil
+	case "tabp", "tabprevious", "tabN", "tabNext":
+		count := 1
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid count: %s", arg)
+			}
+			count = n
+		}
+		e.tabNext(-count, false)
+		return nil
+	case "tabm", "tabmove":
+		return e.commandTabMove(arg)
+	case "reg", "registers", "di", "display":
+		return e.commandRegisters(strings.Join(parts[1:], ""))
+	case "set", "se", "setl", "setlocal":
+		return e.commandSet(parts[1:], strings.HasPrefix(command, "setl"))
+	case "so", "source":
+		if arg == "" {
+			return fmt.Errorf("argument required")
+		}
+		if err := e.Source(arg); err != nil {
+			e.ShowError(err)
+		}
+		return nil
+	case "map", "nmap", "vmap", "omap", "imap", "cmap",
+		"noremap", "nnoremap", "vnoremap", "onoremap", "inoremap", "cnoremap":
+		// the rhs is taken as it was typed, spaces included
+		return e.commandMap(command, strings.TrimPrefix(cmd, command))
+	case "unmap", "nunmap", "vunmap", "ounmap", "iunmap", "cunmap":
+		return e.commandUnmap(command, strings.TrimPrefix(cmd, command))
 	case "E", "Explore":
 		dir := ""
 		if len(parts) > 1 {
@@ -51,21 +208,31 @@ func (e *Editor) executeCommand(cmd string) error {
 		}
 		return e.commandExplore(dir)
 	default:
-		// Check if it's a line number (e.g., :42)
-		if lineNum, err := strconv.Atoi(command); err == nil {
-			return e.commandGotoLine(lineNum)
-		}
-		e.setMessage(fmt.Sprintf("Unknown command: %s", command))
+		return fmt.Errorf("not an editor command: %s", command)
 	}
-
-	return nil
 }
 
-// quits the editor, if force is false, it checks for unsaved changes.
+// closes the current window (and with it a tab page left empty), or quits
+// the editor when it is the last one.
 func (e *Editor) commandQuit(force bool) error {
-	if !force && e.buffer.IsModified() {
-		e.setMessage("No write since last change (use :q! to override)")
-		return nil
+	if len(e.windows()) > 1 || len(e.tabs) > 1 {
+		return e.commandClose()
+	}
+	return e.commandQuitAll(force)
+}
+
+// quits the editor, if force is false, it checks every buffer for unsaved changes.
+func (e *Editor) commandQuitAll(force bool) error {
+	if !force {
+		switch b := e.modifiedBuffer(); {
+		case b == e.bufferEntry:
+			e.setMessage("No write since last change (use :q! to override)")
+			return nil
+		case b != nil:
+			e.setMessage(fmt.Sprintf("No write since last change for buffer %d \"%s\" (use :q! to override)",
+				b.id, b.buffer.FileName()))
+			return nil
+		}
 	}
 
 	e.shouldQuit = true
@@ -73,7 +240,7 @@ func (e *Editor) commandQuit(force bool) error {
 }
 
 // saves the buffer to disk.
-func (e *Editor) commandWrite() error {
+func (e *Editor) commandWrite(force bool) error {
 	filePath := e.buffer.FilePath()
 
 	if filePath == "" {
@@ -81,38 +248,66 @@ func (e *Editor) commandWrite() error {
 		return nil
 	}
 
-	// Back up existing file before overwriting
-	if err := buffer.Backup(filePath); err != nil {
-		e.setMessage(fmt.Sprintf("Backup failed: %v", err))
-		return err
+	// don't clobber changes made behind our back (:w! does)
+	if !force {
+		if status, _, _ := e.buffer.CheckDisk(); status == buffer.DiskChanged {
+			e.setMessage("WARNING: file changed on disk (add ! to override, :e! to reload)")
+			return nil
+		}
+	}
+
+	// Back up existing file before overwriting (:set backup)
+	if e.backup {
+		opts := buffer.BackupOptions{Dir: expandHome(e.backupDir), Keep: e.backupKeep}
+		if _, err := buffer.Backup(filePath, opts); err != nil {
+			e.setMessage(fmt.Sprintf("Backup failed: %v", err))
+			return err
+		}
 	}
 
 	// Save the file
-	if err := buffer.Save(filePath, e.buffer.GetLines()); err != nil {
+	if err := buffer.Save(filePath, e.buffer, e.saveOptions()); err != nil {
 		e.setMessage(fmt.Sprintf("Error writing file: %v", err))
 		return err
 	}
 
 	e.buffer.SetModified(false)
-	e.setMessage(fmt.Sprintf("\"%s\" %dL written", e.buffer.FileName(), e.buffer.NumLines()))
+	e.writeSwap() // the swap no longer needs to carry the text
+	flags := writtenFlags(e.buffer, e.buffer.EndOfLine() || e.fixEOL)
+	e.setMessage(fmt.Sprintf("\"%s\" %s%dL written", e.buffer.FileName(), flags, e.buffer.NumLines()))
 	return nil
 }
 
+// returns how :w and friends write files.
+func (e *Editor) saveOptions() buffer.SaveOptions {
+	return buffer.SaveOptions{Mode: e.writeMode, FixEOL: e.fixEOL}
+}
+
 // sets the file path and language, then saves the buffer.
-func (e *Editor) commandWriteAs(filePath string) error {
+func (e *Editor) commandWriteAs(filePath string, force bool) error {
+	if filePath != e.buffer.FilePath() {
+		// the swap file belongs next to the new name
+		e.releaseSwap(e.bufferEntry)
+		defer func() {
+			if e.swapFile {
+				e.startSwap(buffer.FreeSwapPath(filePath))
+			}
+		}()
+	}
+
 	e.buffer.SetFilePath(filePath)
-	e.renderer.SetLanguage(filePath)
-	return e.commandWrite()
+	e.highlighter = e.renderer.Highlighter(filePath)
+	return e.commandWrite(force)
 }
 
-func (e *Editor) commandWriteQuit() error {
-	if err := e.commandWrite(); err != nil {
+func (e *Editor) commandWriteQuit(force bool) error {
+	if err := e.commandWrite(force); err != nil {
 		return err
 	}
 
 	// quit only if save was successful
 	if !e.buffer.IsModified() {
-		e.shouldQuit = true
+		return e.commandQuit(force)
 	}
 
 	return nil
@@ -134,3 +329,56 @@ func (e *Editor) commandGotoLine(lineNum int) error {
 	e.setMessage(fmt.Sprintf("Line %d", lineNum+1))
 	return nil
 }
+
+// returns Vim-style "[dos][noeol]" flags for file messages; empty for plain unix files.
+func formatFlags(b *buffer.Buffer) string {
+	return writtenFlags(b, b.EndOfLine())
+}
+
+// returns the flags of formatFlags for b written with or without a final
+// line ending.
+func writtenFlags(b *buffer.Buffer, eol bool) string {
+	var flags string
+	if b.BOM() {
+		flags += "[bom]"
+	}
+	if b.Format() == buffer.FormatDos {
+		flags += "[dos]"
+	}
+	if !eol {
+		flags += "[noeol]"
+	}
+	if flags != "" {
+		flags += " "
+	}
+	return flags
+}
+
+// returns the file format label shown in the status bar, e.g. "unix" or "dos bom".
+func fileFormatLabel(b *buffer.Buffer) string {
+	label := b.Format().String()
+	if b.BOM() {
+		label += " bom"
+	}
+	return label
+}
+
+// expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
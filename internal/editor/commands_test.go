@@ -0,0 +1,47 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// :set fixeol ends the written file's last line but leaves the buffer alone.
+func TestWriteFixEOL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f")
+	e := newTestEditor(t, "one")
+	e.buffer.SetFilePath(path)
+	e.buffer.SetEndOfLine(false)
+	e.buffer.SetModified(false)
+	tick := e.buffer.ChangeTick()
+
+	if err := e.executeCommand("w"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one" {
+		t.Errorf("nofixeol wrote %q, want %q", data, "one")
+	}
+
+	if err := e.executeCommand("set fixeol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.executeCommand("w"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\n" {
+		t.Errorf("fixeol wrote %q, want %q", data, "one\n")
+	}
+	if e.message != `"f" 1L written` {
+		t.Errorf("message %q, want %q", e.message, `"f" 1L written`)
+	}
+
+	// a write that fails changes nothing either
+	if err := e.executeCommand("w " + filepath.Join(dir, "missing", "g")); err == nil {
+		t.Error("write into a missing directory gave no error")
+	}
+	if e.buffer.EndOfLine() || e.buffer.IsModified() || e.buffer.ChangeTick() != tick {
+		t.Errorf("buffer eol %v, modified %v, change tick %d after the writes, want false, false, %d",
+			e.buffer.EndOfLine(), e.buffer.IsModified(), e.buffer.ChangeTick(), tick)
+	}
+}
@@ -0,0 +1,68 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the startup file: a file of Ex commands (:set, :map, ...) run before
+// any file is opened, from $XDG_CONFIG_HOME/glime/init or
+// ~/.config/glime/init. :source runs such a file at any time.
+
+// returns the path of the startup file, or "" when there is no home
+// directory to look in.
+func InitFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "glime", "init")
+}
+
+// runs the Ex commands in the file at path, one per line; the leading :
+// is optional. Empty lines and lines starting with " (comments) are
+// skipped. A failing command doesn't stop the rest: the errors are
+// returned together, one line each.
+func (e *Editor) Source(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, `"`) {
+			continue
+		}
+		if err := e.executeCommand(line); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(append([]error{fmt.Errorf("Error detected while processing %s:", path)}, errs...)...)
+}
+
+// shows err on the message line, or above it when it has several lines.
+// A question waiting for an answer (a swap file found at startup) is
+// left alone.
+func (e *Editor) ShowError(err error) {
+	if e.mode == ModePrompt {
+		return
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) == 1 {
+		e.setMessage(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	e.showOutput(lines)
+}
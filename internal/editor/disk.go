@@ -0,0 +1,92 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// external change detection: the buffer remembers the version of its file
+// (mtime, size and hash) and compares it with the disk when the terminal
+// regains focus, after updateTime of idling and before every write.
+
+// the last disk change the user was told about, so one change is only
+// reported once.
+type diskNotice struct {
+	status buffer.DiskStatus
+	stamp  buffer.FileStamp
+}
+
+// checks whether the current file changed on disk. An unmodified buffer is
+// reloaded when autoread is on; otherwise the user is asked what to do.
+func (e *Editor) checkDisk() {
+	// never interrupt typing or an open question
+	if e.mode != ModeNormal {
+		return
+	}
+
+	status, stamp, err := e.buffer.CheckDisk()
+	if err != nil || status == buffer.DiskUnchanged {
+		e.diskNotice = diskNotice{}
+		return
+	}
+
+	notice := diskNotice{status: status, stamp: stamp}
+	if notice == e.diskNotice {
+		return
+	}
+	e.diskNotice = notice
+
+	name := e.buffer.FileName()
+	if status == buffer.DiskDeleted {
+		e.setMessage(fmt.Sprintf("\"%s\" no longer exists on disk", name))
+		return
+	}
+
+	if e.autoread && !e.buffer.IsModified() {
+		if err := e.reloadFile(); err != nil {
+			e.setMessage(fmt.Sprintf("Reload failed: %v", err))
+			return
+		}
+		e.setMessage(fmt.Sprintf("\"%s\" file changed on disk; reloaded", name))
+		return
+	}
+
+	e.ask(fmt.Sprintf("\"%s\" file changed on disk: [l]oad it, [k]eep the buffer", name), func(ch rune) bool {
+		switch ch {
+		case 'l':
+			if err := e.reloadFile(); err != nil {
+				e.setMessage(fmt.Sprintf("Reload failed: %v", err))
+			}
+		case 'k', keyEscape:
+			e.setMessage("Buffer kept; :w! overwrites the file, :e! reloads it")
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+// re-reads the current file from disk, discarding unsaved changes (:e!).
+func (e *Editor) reloadFile() error {
+	filePath := e.buffer.FilePath()
+	if filePath == "" {
+		return fmt.Errorf("no file name")
+	}
+	if !buffer.Exists(filePath) {
+		return fmt.Errorf("\"%s\" no longer exists on disk", filePath)
+	}
+
+	buf, err := buffer.Load(filePath, e.loadProgress(filePath))
+	if err != nil {
+		return fmt.Errorf("error reloading file: %w", err)
+	}
+
+	e.buffer = buf
+	e.undoMgr = NewUndoManager(e.undoLevels)
+	e.cursor.MoveTo(e.cursor.Row(), e.cursor.Col(), e.buffer)
+	e.diskNotice = diskNotice{}
+	e.writeSwap() // the swap no longer needs to carry the old text
+	e.setMessage(fmt.Sprintf("\"%s\" %s%dL reloaded", e.buffer.FileName(), formatFlags(e.buffer), e.buffer.NumLines()))
+	return nil
+}
@@ -4,34 +4,100 @@ package editor
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 	"unicode/utf8"
 
 	"github.com/AdityaKrSingh26/Glime/internal/buffer"
-	"github.com/AdityaKrSingh26/Glime/internal/cursor"
 	"github.com/AdityaKrSingh26/Glime/internal/terminal"
 	"github.com/AdityaKrSingh26/Glime/internal/ui"
+	"github.com/AdityaKrSingh26/Glime/pkg/ansi"
+)
+
+const (
+	// files at least this big (MiB) open in large file mode by default
+	defaultLargeFileMB = 100
+
+	// files smaller than this load without a progress indicator
+	progressMinSize = 8 << 20
 )
 
 // represents the main editor state
 type Editor struct {
 	terminal *terminal.Terminal
-	buffer   *buffer.Buffer
-	cursor   *cursor.Cursor
 	renderer *ui.Renderer
 
+	// the current tab page and, through it, the current window and buffer;
+	// their fields (layout, cursor, buffer, undoMgr, ...) are promoted, so
+	// e.cursor and e.buffer always belong to the window being edited
+	*tabPage
+	tabs []*tabPage // tab pages, in tab line order
+
+	buffers      []*bufferEntry // the buffer list, in :ls order
+	alternate    *bufferEntry   // buffer edited before the current one (Ctrl-^)
+	lastBufferID int
+
 	mode       Mode
-	prevMode   Mode   // Mode before entering command mode
+	prevMode   Mode // Mode before entering command mode
 	message    string
 	commandBuf string // Buffer for command mode input
-	shouldQuit  bool
-
-	undoMgr   *UndoManager   // Undo/Redo
-	pending   PendingCommand // Multi-key commands
-	register  Register       // Copy/Paste
-	search    SearchState    // Search
-	searchBuf string         // Input buffer for search mode
-	explorer  ExplorerState  // File explorer
+	shouldQuit bool
+	fixEOL     bool // always terminate the last line on write (:set fixeol, off by default)
+
+	largeFileMB int // size in MiB from which large file mode kicks in (:set largefile)
+
+	backup     bool             // keep a copy of the previous file on write (:set backup)
+	backupDir  string           // where backups go, "" = next to the file (:set backupdir)
+	backupKeep int              // backups retained per file (:set backupkeep)
+	writeMode  buffer.WriteMode // how files are replaced on write (:set backupcopy)
+
+	swapWriter  *swapWriter // writes swap files in the background
+	swapFile    bool        // keep swap files at all (:set swapfile)
+	updateCount int         // keystrokes between swap writes (:set updatecount)
+	updateTime  int         // idle milliseconds before a swap write (:set updatetime)
+
+	autoread bool // reload unmodified buffers changed on disk (:set autoread)
+
+	bufDefaults bufferOptions // buffer-local options of new buffers
+	winDefaults windowOptions // window-local options :set gives new windows
+	scrollOff   int           // lines kept in view around the cursor (:set scrolloff)
+	ignoreCase  bool          // search ignores case (:set ignorecase)
+	smartCase   bool          // ... unless the pattern has upper case letters (:set smartcase)
+	magic       bool          // patterns are regular expressions, not literal text (:set magic)
+	undoLevels  int           // changes that can be undone (:set undolevels)
+
+	clipboard  string           // "unnamed" and/or "unnamedplus": plain y, d and p use "* or "+ (:set clipboard)
+	clipCopier *clipboardCopier // copies to the system clipboard in the background
+	mouse      string           // modes the mouse works in: n, v, i, c or a for all (:set mouse)
+
+	mapLeader  string // what <leader> stands for in mappings (:set mapleader)
+	timeout    bool   // typed keys that start a mapping wait at most timeoutlen (:set timeout)
+	timeoutLen int    // milliseconds they wait for the next key (:set timeoutlen)
+
+	prompt *prompt  // open question in ModePrompt
+	output []string // command output shown above the message bar
+
+	pending     PendingCommand    // Multi-key commands
+	visual      visualState       // selection of the visual modes
+	block       *blockInsert      // pending blockwise I/A, finished on ESC
+	registers   map[rune]Register // yanks, deletes and macros by register name
+	regName     rune              // register named with "x for the command being typed
+	inserted    []rune            // text typed in the current insert session
+	lastFind    charSearch        // last f, t, F or T, repeated by ; and ,
+	lastSub     *substitute       // last :s, repeated by a :s without pattern
+	lastPattern string            // last pattern of :s or :g, used by an empty one
+	global      *globalCommand    // the :g running, nil when none is
+	subMatch    *SearchMatch      // the match :s///c is asking about
+	dot         changeRecord      // last change, repeated by .
+	typing      *changeRecord     // keys of the command being typed
+	keymaps     map[rune]keymap   // mappings by mode: n, v, o, i, c (:map)
+	typeahead   []typedKey        // typed keys not yet mapped and run
+	macro       macroState        // q recording and @ playback
+	searchBuf   string            // Input buffer for search mode
+	explorer    ExplorerState     // File explorer
 }
 
 func New() (*Editor, error) {
@@ -40,21 +106,40 @@ func New() (*Editor, error) {
 		return nil, fmt.Errorf("failed to create new terminal: %w", err)
 	}
 
-	buf := buffer.New()
-	cur := cursor.New()
 	renderer := ui.NewRenderer(term)
 
-	return &Editor{
+	e := &Editor{
 		terminal:   term,
-		buffer:     buf,
-		cursor:     cur,
 		renderer:   renderer,
 		mode:       ModeNormal,
 		message:    "Glime editor - Type :q to quit",
 		commandBuf: "",
 		shouldQuit: false,
-		undoMgr:    NewUndoManager(1000),
-	}, nil
+
+		largeFileMB: defaultLargeFileMB,
+		backupKeep:  1,
+		writeMode:   buffer.WriteAuto,
+		swapWriter:  newSwapWriter(),
+		clipCopier:  newClipboardCopier(),
+		swapFile:    true,
+		updateCount: 200,
+		updateTime:  4000,
+		bufDefaults: defaultBufferOptions,
+		winDefaults: defaultWindowOptions,
+		undoLevels:  1000,
+		magic:       true,
+		mapLeader:   `\`,
+		timeout:     true,
+		timeoutLen:  1000,
+	}
+	e.initKeymaps()
+
+	// start with a single window on an empty buffer
+	b := e.newBufferEntry(buffer.New())
+	e.buffers = []*bufferEntry{b}
+	e.tabPage = newTabPage(b, e.winDefaults)
+	e.tabs = []*tabPage{e.tabPage}
+	return e, nil
 }
 
 // opens the file explorer at the given directory.
@@ -62,27 +147,77 @@ func (e *Editor) OpenExplorer(dir string) error {
 	return e.commandExplore(dir)
 }
 
-// load file into editor
+// opens a file in a new buffer (or switches to it if it is already open).
 func (e *Editor) LoadFile(filePath string) error {
-	if !buffer.Exists(filePath) {
-		// file does not exist create a buffer with this path
-		e.buffer.SetFilePath(filePath)
-		e.renderer.SetLanguage(filePath)
-		e.setMessage(fmt.Sprintf("\"%s\" [New File]", filePath))
-		return nil
-	}
+	return e.editFile(filePath)
+}
 
-	lines, err := buffer.Load(filePath)
-	if err != nil {
-		return fmt.Errorf("Error loading file into editor: %w", err)
+// opens more files after the first one: in tab pages of their own when
+// tabs is set, otherwise in the buffer list. The first file stays current.
+func (e *Editor) AddFiles(filePaths []string, tabs bool) error {
+	first, firstTab := e.bufferEntry, e.tabPage
+	for _, filePath := range filePaths {
+		var err error
+		if tabs {
+			err = e.commandTabNew(filePath)
+		} else {
+			err = e.editFile(filePath)
+		}
+		if err != nil {
+			return err
+		}
+		if e.mode == ModePrompt {
+			// a swap file question is about this file; stay with it
+			return nil
+		}
 	}
 
-	e.buffer = buffer.NewFromLines(lines, filePath)
-	e.renderer.SetLanguage(filePath)
-	e.setMessage(fmt.Sprintf("\"%s\" %dL", filePath, e.buffer.NumLines()))
+	e.enterTab(firstTab)
+	if !tabs {
+		e.switchBuffer(first)
+	}
 	return nil
 }
 
+// returns a progress reporter for loading filePath. Small files load
+// silently; bigger ones show a percentage on the message line (or on
+// stderr when the editor screen is not up yet).
+func (e *Editor) loadProgress(filePath string) buffer.ProgressFunc {
+	lastPercent := -1
+	return func(read, total int64) {
+		if total < progressMinSize {
+			return
+		}
+		percent := int(read * 100 / total)
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+
+		msg := fmt.Sprintf("\"%s\" loading... %d%%", filePath, percent)
+		if e.terminal.IsRawMode() {
+			e.terminal.Write(ansi.MoveCursorTo(e.terminal.Height(), 1) + msg + ansi.ClearToLineEnd)
+		} else {
+			end := ""
+			if read >= total {
+				end = "\n"
+			}
+			fmt.Fprintf(os.Stderr, "\r%s%s", msg, end)
+		}
+	}
+}
+
+// reports whether the current buffer is over the largefile threshold,
+// in which case syntax highlighting and bracket matching are skipped.
+func (e *Editor) isLargeFile() bool {
+	return e.largeFile(e.largeFileMB)
+}
+
+// reports whether b is at least thresholdMB big (0 disables large file mode).
+func (b *bufferEntry) largeFile(thresholdMB int) bool {
+	return thresholdMB > 0 && b.buffer.Size() >= thresholdMB<<20
+}
+
 // start the editor event loop
 func (e *Editor) Run() error {
 	// enable raw mode
@@ -97,9 +232,47 @@ func (e *Editor) Run() error {
 	}
 	defer e.terminal.DisableAlternateBuffer()
 
+	// focus events tell us when to look for external file changes
+	if err := e.terminal.EnableFocusReporting(); err != nil {
+		return fmt.Errorf("failed to enable focus reporting: %w", err)
+	}
+	defer e.terminal.DisableFocusReporting()
+
+	// pasted text comes in one piece, see paste.go
+	if err := e.terminal.EnableBracketedPaste(); err != nil {
+		return fmt.Errorf("failed to enable bracketed paste: %w", err)
+	}
+	defer e.terminal.DisableBracketedPaste()
+
+	// keys like Ctrl-i and Tab are told apart where the terminal supports it
+	if err := e.terminal.EnableKittyKeyboard(); err != nil {
+		return fmt.Errorf("failed to enable kitty keyboard protocol: %w", err)
+	}
+	defer e.terminal.DisableKittyKeyboard()
+
+	// the mouse is only taken from the terminal with :set mouse
+	if e.mouse != "" {
+		if err := e.terminal.EnableMouse(); err != nil {
+			return fmt.Errorf("failed to enable mouse: %w", err)
+		}
+	}
+	defer e.terminal.DisableMouse()
+
 	// watch for terminal resize (SIGWINCH)
 	e.terminal.WatchResize()
 
+	// survive SIGHUP so a dying terminal ends in a read error below,
+	// which keeps the swap file instead of killing us outright
+	signal.Ignore(syscall.SIGHUP)
+
+	// a panic must not take unsaved edits with it
+	defer func() {
+		if r := recover(); r != nil {
+			e.emergencySwap()
+			panic(r)
+		}
+	}()
+
 	// hide cursor during setup
 	if err := e.terminal.HideCursor(); err != nil {
 		return err
@@ -112,6 +285,11 @@ func (e *Editor) Run() error {
 
 	// main event loop
 	for !e.shouldQuit {
+		// a clipboard tool that failed in the background says so now
+		if msg, ok := e.clipCopier.failure(); ok {
+			e.setMessage(msg)
+		}
+
 		// update scroll to make sure cursor is visible
 		e.updateScroll()
 
@@ -121,23 +299,79 @@ func (e *Editor) Run() error {
 			return fmt.Errorf("render error: %w", err)
 		}
 
-		// read key input
-		key, err := e.terminal.ReadKey()
+		// read key input; after updateTime of idling, catch up on the
+		// swap file and look for changes made to the file on disk. Keys
+		// that start a mapping wait timeoutlen for the rest of it.
+		wait := time.Duration(e.updateTime) * time.Millisecond
+		mapWait := e.typeaheadWaiting() && e.mapTimeout() > 0
+		if mapWait {
+			wait = e.mapTimeout()
+		}
+		key, err := e.terminal.ReadKeyTimeout(wait)
 		if err != nil {
+			e.emergencySwap()
 			return fmt.Errorf("failed to read key: %w", err)
 		}
+		if key == nil && mapWait {
+			if err := e.resolveTypeahead(true); err != nil {
+				e.emergencySwap()
+				return fmt.Errorf("key processing error: %w", err)
+			}
+			continue
+		}
+		if key == nil {
+			if e.swapPending() {
+				e.writeSwap()
+			}
+			e.checkDisk()
+			continue
+		}
 
 		// process the key
-		if err := e.processKey(key); err != nil {
+		e.recordMacroKey(key)
+		if err := e.feedKey(*key, false); err != nil {
+			e.emergencySwap()
 			return fmt.Errorf("key processing error: %w", err)
 		}
+		e.swapAfterKey()
 	}
 
+	// clean exit: nothing left to recover
+	for _, b := range e.buffers {
+		e.releaseSwap(b)
+	}
+	e.swapWriter.Close()
 	return nil
 }
 
 // to handle a key press based on the current mode.
 func (e *Editor) processKey(key *terminal.Key) error {
+	switch key.Type {
+	case terminal.KeyFocusIn:
+		e.checkDisk()
+		return nil
+	case terminal.KeyFocusOut:
+		return nil
+	case terminal.KeyMouse:
+		// not part of a change for . to repeat
+		return e.processMouse(&key.Mouse)
+	}
+
+	e.recordKey(key)
+	defer e.endRecordedKey()
+
+	// a register named with "x is for the command that follows it only
+	regName := e.regName
+	defer func() {
+		if e.regName == regName && e.commandComplete() {
+			e.regName = 0
+		}
+	}()
+
+	if key.Type == terminal.KeyPaste {
+		return e.processPaste(key)
+	}
+
 	switch e.mode {
 	case ModeNormal:
 		return e.processNormalMode(key)
@@ -149,56 +383,69 @@ func (e *Editor) processKey(key *terminal.Key) error {
 		return e.processSearchMode(key)
 	case ModeExplore:
 		return e.processExploreMode(key)
+	case ModePrompt:
+		return e.processPromptMode(key)
+	case ModeVisual, ModeVisualLine, ModeVisualBlock:
+		return e.processVisualMode(key)
 	}
 	return nil
 }
 
 // handles keys in Normal mode with multi-key support.
 func (e *Editor) processNormalMode(key *terminal.Key) error {
-	// Step 1: Handle non-rune keys first (arrows, page, ctrl, escape)
-	switch key.Type {
-	case terminal.KeyArrowLeft:
-		e.pending.Reset()
-		e.cursor.MoveLeft(e.buffer)
-		return nil
-	case terminal.KeyArrowRight:
-		e.pending.Reset()
-		e.cursor.MoveRight(e.buffer)
-		return nil
-	case terminal.KeyArrowUp:
-		e.pending.Reset()
-		e.cursor.MoveUp(e.buffer)
-		return nil
-	case terminal.KeyArrowDown:
+	if e.pending.Operator == ctrlW {
+		count, hasCount := e.pending.EffectiveCount(), e.pending.HasCount
 		e.pending.Reset()
-		e.cursor.MoveDown(e.buffer)
-		return nil
-	case terminal.KeyPageUp:
-		e.pending.Reset()
-		e.cursor.PageUp(e.buffer, e.terminal.Height()-2)
-		return nil
-	case terminal.KeyPageDown:
-		e.pending.Reset()
-		e.cursor.PageDown(e.buffer, e.terminal.Height()-2)
+		return e.processWindowCommand(key, count, hasCount)
+	}
+
+	// f, t, F and T take the next key as the character to look for, '
+	// and ` as the mark to go to
+	if p := e.pending.Prefix; p == 'f' || p == 't' || p == 'F' || p == 'T' || p == '\'' || p == '`' {
+		if key.Type != terminal.KeyRune {
+			e.pending.Reset()
+			return nil
+		}
+		e.normalMotion(string(p), key.Rune)
 		return nil
-	case terminal.KeyHome:
-		e.pending.Reset()
-		e.cursor.MoveToLineStart()
+	}
+
+	// after an operator, i and a take the next key as a text object
+	if p := e.pending.Prefix; p == 'i' || p == 'a' {
+		if key.Type != terminal.KeyRune {
+			e.pending.Reset()
+			return nil
+		}
+		e.operatorTextObject(p == 'i', key.Rune)
 		return nil
-	case terminal.KeyEnd:
+	}
+
+	// Step 1: Handle non-rune keys first (page, ctrl, escape); the arrows,
+	// Home and End are mapped to h, j, k, l, 0 and $
+	if e.moveCursorKey(key) {
 		e.pending.Reset()
-		e.cursor.MoveToLineEndNormal(e.buffer)
 		return nil
+	}
+	switch key.Type {
 	case terminal.KeyEscape:
 		e.pending.Reset()
 		return nil
 	case terminal.KeyCtrl:
+		count, hasCount := e.pending.Count, e.pending.HasCount
 		e.pending.Reset()
 		switch key.Rune {
 		case 'c':
 			e.shouldQuit = true
 		case 'r':
 			e.redo()
+		case '^':
+			e.switchAlternate(count, hasCount)
+		case 'w':
+			// window command: the next key says what to do
+			e.pending.Operator = ctrlW
+			e.pending.Count, e.pending.HasCount = count, hasCount
+		case 'v':
+			e.startVisual(ModeVisualBlock)
 		}
 		return nil
 	}
@@ -209,7 +456,40 @@ func (e *Editor) processNormalMode(key *terminal.Key) error {
 
 	ch := key.Rune
 
-	// Step 2: Accumulate count prefix
+	// Step 2: The key after g
+	if e.pending.Prefix == 'g' {
+		e.pending.Prefix = 0
+		return e.executeGCommand(ch)
+	}
+
+	// " names the register for the command that follows
+	if e.pending.Prefix == '"' {
+		e.pending.Prefix = 0
+		e.nameRegister(ch)
+		return nil
+	}
+
+	// m takes the next key as the mark to set
+	if e.pending.Prefix == 'm' {
+		e.pending.Reset()
+		if !e.setMark(ch) {
+			e.commandFailed()
+		}
+		return nil
+	}
+
+	// q and @ take the next key as a register name
+	if p := e.pending.Prefix; p == 'q' || p == '@' {
+		count := e.pending.Count
+		e.pending.Reset()
+		if p == 'q' {
+			e.startRecording(ch)
+			return nil
+		}
+		return e.runMacro(ch, count)
+	}
+
+	// Step 3: Accumulate count prefix
 	if ch >= '1' && ch <= '9' {
 		e.pending.AccumulateDigit(int(ch - '0'))
 		return nil
@@ -219,63 +499,38 @@ func (e *Editor) processNormalMode(key *terminal.Key) error {
 		return nil
 	}
 
-	// Step 3: Operator-pending mode (d, y, g wait for second key)
-	if e.pending.Operator == 0 {
-		switch ch {
-		case 'd', 'y', 'g':
-			e.pending.Operator = ch
-			return nil
-		}
+	// Step 4: Operator-pending mode, the key is the motion
+	if e.pending.Operator != 0 {
+		return e.operatorKey(ch)
 	}
 
-	// Step 4: Execute command
+	// Step 5: Execute command
 	return e.executeNormalCommand(ch)
 }
 
-// dispatches a normal mode command (possibly with pending operator).
+// dispatches a normal mode command.
 func (e *Editor) executeNormalCommand(ch rune) error {
-	count := e.pending.EffectiveCount()
-	op := e.pending.Operator
-	defer e.pending.Reset()
-
-	// Operator + motion combinations
-	if op != 0 {
-		switch op {
-		case 'd':
-			switch ch {
-			case 'd':
-				return e.deleteLines(count)
-			case 'w':
-				return e.deleteWord(count)
-			case '$':
-				return e.deleteToLineEnd()
-			default:
-				return nil // unknown motion, ignore
-			}
-		case 'y':
-			switch ch {
-			case 'y':
-				return e.yankLines(count)
-			case 'w':
-				return e.yankWord(count)
-			case '$':
-				return e.yankToLineEnd()
-			default:
-				return nil
-			}
-		case 'g':
-			switch ch {
-			case 'g':
-				e.cursor.MoveToFirstLine()
-				return nil
-			default:
-				return nil
-			}
+	// keys that wait for more keys keep the pending state
+	switch ch {
+	case 'd', 'c', 'y', '>', '<', '=':
+		e.startOperator(ch)
+		return nil
+	case 'g', 'f', 't', 'F', 'T', '@', '"', 'm', '\'', '`':
+		e.pending.Prefix = ch
+		return nil
+	case 'q':
+		if e.macro.recording != 0 {
+			e.pending.Reset()
+			e.stopRecording()
+		} else {
+			e.pending.Prefix = ch
 		}
 		return nil
 	}
 
-	// Simple commands (no operator pending)
+	count := e.pending.EffectiveCount()
+	defer e.pending.Reset()
+
 	switch ch {
 	case 'i':
 		e.setMode(ModeInsert)
@@ -322,73 +577,140 @@ func (e *Editor) executeNormalCommand(ch rune) error {
 		e.enterSearchMode(SearchForward)
 	case '?':
 		e.enterSearchMode(SearchBackward)
-	case 'n':
-		e.searchNext()
-	case 'N':
-		e.searchPrev()
-	case 'h':
-		for i := 0; i < count; i++ {
-			e.cursor.MoveLeft(e.buffer)
-		}
-	case 'j':
-		for i := 0; i < count; i++ {
-			e.cursor.MoveDown(e.buffer)
-		}
-	case 'k':
-		for i := 0; i < count; i++ {
-			e.cursor.MoveUp(e.buffer)
-		}
-	case 'l':
-		for i := 0; i < count; i++ {
-			e.cursor.MoveRight(e.buffer)
-		}
-	case '0':
-		e.cursor.MoveToLineStart()
-	case '$':
-		e.cursor.MoveToLineEndNormal(e.buffer)
-	case 'G':
-		e.cursor.MoveToLastLine(e.buffer)
-	case 'w':
-		for i := 0; i < count; i++ {
-			row, col := e.findWordEnd(e.cursor.Row(), e.cursor.Col())
-			e.cursor.MoveTo(row, col, e.buffer)
-		}
-	case 'b':
-		for i := 0; i < count; i++ {
-			row, col := e.findWordStart(e.cursor.Row(), e.cursor.Col())
-			e.cursor.MoveTo(row, col, e.buffer)
-		}
+	case 'v':
+		e.startVisual(ModeVisual)
+	case 'V':
+		e.startVisual(ModeVisualLine)
+	case 'J':
+		e.undoMgr.BeginGroup()
+		e.joinLineRange(e.cursor.Row(), e.cursor.Row()+max(count, 2)-1)
+		e.undoMgr.EndGroup()
+	// x, s, D, C, S and Y are short for dl, cl, d$, c$, cc and yy
 	case 'x':
-		e.deleteCharUnderCursor()
+		e.startOperator('d')
+		e.operatorMotion("l", 0)
+	case 's':
+		e.startOperator('c')
+		e.operatorMotion("l", 0)
+	case 'D':
+		e.startOperator('d')
+		e.operatorMotion("$", 0)
+	case 'C':
+		e.startOperator('c')
+		e.operatorMotion("$", 0)
+	case 'S':
+		e.startOperator('c')
+		e.operateLines()
+	case 'Y':
+		e.startOperator('y')
+		e.operateLines()
+	case '.':
+		return e.repeatChange(e.pending.Count)
 	case 'u':
 		e.undo()
 	case 'p':
 		e.pasteAfter()
 	case 'P':
 		e.pasteBefore()
+	default:
+		e.normalMotion(string(ch), 0)
 	}
 
 	return nil
 }
 
+// handles the key after g.
+func (e *Editor) executeGCommand(ch rune) error {
+	switch ch {
+	case 'g':
+		e.normalMotion("gg", 0)
+		return nil
+	case '*', '#':
+		e.normalMotion("g"+string(ch), 0)
+		return nil
+	case '~', 'u', 'U':
+		if e.pending.Operator == 0 {
+			e.startOperator(ch)
+			return nil
+		}
+		return e.operatorKey(ch) // g~g~, gugu, gUgU
+	}
+
+	count, hasCount := e.pending.EffectiveCount(), e.pending.HasCount
+	op := e.pending.Operator
+	e.pending.Reset()
+	if op != 0 {
+		return nil
+	}
+	switch ch {
+	case 't':
+		e.tabNext(count, hasCount)
+	case 'T':
+		e.tabNext(-count, false)
+	case 'v':
+		e.reselectVisual()
+	}
+	return nil
+}
+
+// runs motion m: for the pending operator, or as a cursor movement.
+func (e *Editor) normalMotion(m string, arg rune) {
+	if e.pending.Operator != 0 {
+		e.operatorMotion(m, arg)
+		return
+	}
+	if _, ok := e.motion(m, arg, e.pending.Count, false); !ok {
+		e.commandFailed()
+	}
+	e.pending.Reset()
+}
+
+// moves the cursor for the page keys. Returns false for other keys.
+func (e *Editor) moveCursorKey(key *terminal.Key) bool {
+	switch key.Type {
+	case terminal.KeyPageUp:
+		e.cursor.PageUp(e.buffer, e.window.height)
+	case terminal.KeyPageDown:
+		e.cursor.PageDown(e.buffer, e.window.height)
+	default:
+		return false
+	}
+	return true
+}
+
 // handles keys in Insert mode.
 func (e *Editor) processInsertMode(key *terminal.Key) error {
 	switch key.Type {
 	case terminal.KeyEscape:
+		e.finishBlockInsert()
 		e.undoMgr.EndGroup()
+		e.putRegister('.', Register{Content: string(e.inserted)})
+		e.inserted = e.inserted[:0]
 		e.setMode(ModeNormal)
 
 	case terminal.KeyRune:
 		e.insertChar(e.cursor.Row(), e.cursor.Col(), key.Rune)
 		e.cursor.MoveRight(e.buffer)
+		e.inserted = append(e.inserted, key.Rune)
 
 	case terminal.KeyEnter:
 		e.splitLine(e.cursor.Row(), e.cursor.Col())
 		e.cursor.MoveDown(e.buffer)
 		e.cursor.MoveToLineStart()
+		e.inserted = append(e.inserted, '\n')
+
+	case terminal.KeyTab:
+		for _, r := range e.tabText() {
+			e.insertChar(e.cursor.Row(), e.cursor.Col(), r)
+			e.cursor.MoveRight(e.buffer)
+			e.inserted = append(e.inserted, r)
+		}
 
 	case terminal.KeyBackspace:
 		e.backspace(e.cursor.Row(), e.cursor.Col())
+		if n := len(e.inserted); n > 0 {
+			e.inserted = e.inserted[:n-1]
+		}
 
 	case terminal.KeyDelete:
 		e.deleteCharAt(e.cursor.Row(), e.cursor.Col())
@@ -414,13 +736,21 @@ func (e *Editor) processCommandMode(key *terminal.Key) error {
 
 	case terminal.KeyEnter:
 		// Execute the command
-		if err := e.executeCommand(e.commandBuf); err != nil {
+		cmd := e.commandBuf
+		if err := e.executeCommand(cmd); err != nil {
 			e.setMessage(fmt.Sprintf("Error: %v", err))
+			e.commandFailed()
+		}
+		if cmd = strings.TrimPrefix(cmd, ":"); strings.TrimSpace(cmd) != "" {
+			e.putRegister(':', Register{Content: cmd})
 		}
 
-		// Return to previous mode only if the command didn't change mode itself
+		// Return to previous mode only if the command didn't change mode itself,
+		// keeping whatever message the command left behind
 		if !e.shouldQuit && e.mode == ModeCommand {
+			msg := e.message
 			e.setMode(e.prevMode)
+			e.message = msg
 		}
 		e.commandBuf = ""
 
@@ -444,40 +774,41 @@ func (e *Editor) processSearchMode(key *terminal.Key) error {
 		e.search.Pattern = ""
 		e.search.Matches = nil
 		e.searchBuf = ""
+		e.pending.Reset()
 		e.setMode(ModeNormal)
 
 	case terminal.KeyEnter:
-		// Finalize search and jump to nearest match
-		e.search.Pattern = e.searchBuf
-		e.search.FindAll(e.buffer.GetLines())
-		if len(e.search.Matches) > 0 {
-			idx := e.search.NextMatch(e.cursor.Row(), e.cursor.Col())
-			if idx >= 0 {
-				m := e.search.Matches[idx]
-				e.search.CurrentIndex = idx
-				e.cursor.MoveTo(m.Row, m.ColStart, e.buffer)
-			}
-			e.setMessage(fmt.Sprintf("/%s [%d matches]", e.search.Pattern, len(e.search.Matches)))
-		} else {
-			e.setMessage(fmt.Sprintf("Pattern not found: %s", e.searchBuf))
-			e.search.Active = false
-		}
+		// Finalize search and jump to the first match
+		// leave search mode first, it clears the message the search leaves
+		row, col := e.cursor.Row(), e.cursor.Col()
+		input := e.searchBuf
 		e.searchBuf = ""
 		e.setMode(ModeNormal)
+		err := e.startSearch(input)
+		if err != nil {
+			e.setMessage(fmt.Sprintf("Error: %v", err))
+			e.commandFailed()
+		}
+
+		// a search after an operator is its motion (d/foo)
+		if op := e.pending.Operator; op != 0 {
+			e.pending.Reset()
+			if err == nil {
+				e.operateTo(op, row, col, e.search.Offset.kind())
+			}
+		}
 
 	case terminal.KeyBackspace:
 		if len(e.searchBuf) > 0 {
 			e.searchBuf = e.searchBuf[:len(e.searchBuf)-1]
 			// Re-run incremental search
-			e.search.Pattern = e.searchBuf
-			e.search.FindAll(e.buffer.GetLines())
+			e.incrementalSearch()
 		}
 
 	case terminal.KeyRune:
 		e.searchBuf += string(key.Rune)
 		// Incremental search
-		e.search.Pattern = e.searchBuf
-		e.search.FindAll(e.buffer.GetLines())
+		e.incrementalSearch()
 	}
 
 	return nil
@@ -491,56 +822,6 @@ func (e *Editor) enterSearchMode(dir SearchDirection) {
 	e.setMode(ModeSearch)
 }
 
-// searchNext jumps in the original search direction (n key).
-func (e *Editor) searchNext() {
-	if !e.search.Active || len(e.search.Matches) == 0 {
-		e.setMessage("No search pattern")
-		return
-	}
-
-	var idx int
-	if e.search.Direction == SearchForward {
-		idx = e.search.NextMatch(e.cursor.Row(), e.cursor.Col())
-	} else {
-		idx = e.search.PrevMatch(e.cursor.Row(), e.cursor.Col())
-	}
-	if idx >= 0 {
-		m := e.search.Matches[idx]
-		e.search.CurrentIndex = idx
-		e.cursor.MoveTo(m.Row, m.ColStart, e.buffer)
-		dir := "/"
-		if e.search.Direction == SearchBackward {
-			dir = "?"
-		}
-		e.setMessage(fmt.Sprintf("%s%s [%d/%d]", dir, e.search.Pattern, idx+1, len(e.search.Matches)))
-	}
-}
-
-// searchPrev jumps opposite to the original search direction (N key).
-func (e *Editor) searchPrev() {
-	if !e.search.Active || len(e.search.Matches) == 0 {
-		e.setMessage("No search pattern")
-		return
-	}
-
-	var idx int
-	if e.search.Direction == SearchForward {
-		idx = e.search.PrevMatch(e.cursor.Row(), e.cursor.Col())
-	} else {
-		idx = e.search.NextMatch(e.cursor.Row(), e.cursor.Col())
-	}
-	if idx >= 0 {
-		m := e.search.Matches[idx]
-		e.search.CurrentIndex = idx
-		e.cursor.MoveTo(m.Row, m.ColStart, e.buffer)
-		dir := "/"
-		if e.search.Direction == SearchBackward {
-			dir = "?"
-		}
-		e.setMessage(fmt.Sprintf("%s%s [%d/%d]", dir, e.search.Pattern, idx+1, len(e.search.Matches)))
-	}
-}
-
 // --- Undo-aware buffer wrapper methods ---
 
 // records and executes a character insertion.
@@ -579,21 +860,6 @@ func (e *Editor) deleteCharAt(row, col int) {
 	e.buffer.DeleteChar(row, col)
 }
 
-// deletes the char under cursor and yanks it.
-func (e *Editor) deleteCharUnderCursor() {
-	line, err := e.buffer.GetLine(e.cursor.Row())
-	if err != nil {
-		return
-	}
-	runes := []rune(line)
-	if e.cursor.Col() >= len(runes) {
-		return
-	}
-	ch := string(runes[e.cursor.Col()])
-	e.register = Register{Content: ch, Type: RegisterChar}
-	e.deleteCharAt(e.cursor.Row(), e.cursor.Col())
-}
-
 func (e *Editor) splitLine(row, col int) {
 	line, _ := e.buffer.GetLine(row)
 	e.undoMgr.Record(Action{
@@ -651,82 +917,6 @@ func (e *Editor) backspace(row, col int) {
 	}
 }
 
-// --- Yank operations ---
-// When you "yank" text, it gets copied into the register,
-// so you can paste it somewhere else later.
-
-// copies count lines to the register.
-func (e *Editor) yankLines(count int) error {
-	row := e.cursor.Row()
-	lines := e.buffer.GetLines()
-
-	if count > len(lines)-row {
-		count = len(lines) - row
-	}
-
-	var yanked string
-	for i := 0; i < count; i++ {
-		if i > 0 {
-			yanked += "\n"
-		}
-		yanked += lines[row+i]
-	}
-	e.register = Register{Content: yanked, Type: RegisterLine}
-
-	if count == 1 {
-		e.setMessage("1 line yanked")
-	} else {
-		e.setMessage(fmt.Sprintf("%d lines yanked", count))
-	}
-	return nil
-}
-
-// copies from cursor to next word boundary to the register.
-func (e *Editor) yankWord(count int) error {
-	row := e.cursor.Row()
-	col := e.cursor.Col()
-	endRow, endCol := row, col
-	for i := 0; i < count; i++ {
-		endRow, endCol = e.findWordEnd(endRow, endCol)
-	}
-
-	if endRow == row {
-		line, _ := e.buffer.GetLine(row)
-		runes := []rune(line)
-		if endCol > len(runes) {
-			endCol = len(runes)
-		}
-		e.register = Register{Content: string(runes[col:endCol]), Type: RegisterChar}
-	} else {
-		lines := e.buffer.GetLines()
-		var yanked string
-		startRunes := []rune(lines[row])
-		yanked = string(startRunes[col:])
-		for r := row + 1; r < endRow && r < len(lines); r++ {
-			yanked += "\n" + lines[r]
-		}
-		if endRow < len(lines) {
-			endRunes := []rune(lines[endRow])
-			yanked += "\n" + string(endRunes[:endCol])
-		}
-		e.register = Register{Content: yanked, Type: RegisterChar}
-	}
-	e.setMessage("yanked")
-	return nil
-}
-
-// copies from cursor to end of line to the register.
-func (e *Editor) yankToLineEnd() error {
-	line, _ := e.buffer.GetLine(e.cursor.Row())
-	runes := []rune(line)
-	col := e.cursor.Col()
-	if col < len(runes) {
-		e.register = Register{Content: string(runes[col:]), Type: RegisterChar}
-	}
-	e.setMessage("yanked")
-	return nil
-}
-
 // --- Paste operations ---
 
 // pastes after cursor.
@@ -739,10 +929,11 @@ func (e *Editor) pasteBefore() {
 	e.paste(false)
 }
 
-// inserts register content
+// inserts the content of the register named for the command ("xp), or the unnamed one
 // If afterCursor is true, content goes after the cursor position; otherwise it goes before
 func (e *Editor) paste(afterCursor bool) {
-	if e.register.Content == "" {
+	reg := e.commandRegister()
+	if reg.Content == "" {
 		return
 	}
 
@@ -750,13 +941,15 @@ func (e *Editor) paste(afterCursor bool) {
 	row := e.cursor.Row()
 	col := e.cursor.Col()
 
-	if e.register.Type == RegisterLine {
+	if reg.Type == RegisterBlock {
+		e.pasteBlock(reg.Content, afterCursor)
+	} else if reg.Type == RegisterLine {
 		// Line paste: insert above or below current line
 		insertRow := row
 		if afterCursor {
 			insertRow = row + 1
 		}
-		pasteLines := strings.Split(e.register.Content, "\n")
+		pasteLines := strings.Split(reg.Content, "\n")
 		for i, l := range pasteLines {
 			e.undoMgr.Record(Action{
 				Type:      ActionInsertLine,
@@ -779,17 +972,23 @@ func (e *Editor) paste(afterCursor bool) {
 				insertCol = len(runes)
 			}
 		}
-		newLine := string(runes[:insertCol]) + e.register.Content + string(runes[insertCol:])
-		e.undoMgr.Record(Action{
-			Type:      ActionSetLine,
-			Row:       row,
-			Text:      newLine,
-			PrevText:  line,
-			CursorRow: row,
-			CursorCol: col,
-		})
-		e.buffer.SetLine(row, newLine)
-		e.cursor.MoveTo(row, insertCol+utf8.RuneCountInString(e.register.Content)-1, e.buffer)
+		newLine := string(runes[:insertCol]) + reg.Content + string(runes[insertCol:])
+		if strings.Contains(reg.Content, "\n") {
+			// text spanning lines splits the cursor line around it
+			e.replaceLines(row, row, strings.Split(newLine, "\n"))
+			e.cursor.MoveTo(row, insertCol, e.buffer)
+		} else {
+			e.undoMgr.Record(Action{
+				Type:      ActionSetLine,
+				Row:       row,
+				Text:      newLine,
+				PrevText:  line,
+				CursorRow: row,
+				CursorCol: col,
+			})
+			e.buffer.SetLine(row, newLine)
+			e.cursor.MoveTo(row, insertCol+utf8.RuneCountInString(reg.Content)-1, e.buffer)
+		}
 	}
 
 	e.undoMgr.EndGroup()
@@ -901,7 +1100,7 @@ func (e *Editor) setMessage(msg string) {
 	e.message = msg
 }
 
-// updates the scroll offsets to keep the cursor visible.
+// updates the window layout and scroll offsets to keep the cursor visible.
 func (e *Editor) updateScroll() {
 	if e.mode == ModeExplore {
 		// 2 header rows + 1 status + 1 message = 4 reserved
@@ -910,22 +1109,17 @@ func (e *Editor) updateScroll() {
 		return
 	}
 
-	// visible rows (total height - status bar - message bar)
-	visibleRows := e.terminal.Height() - 2
-
-	// gutter width to determine available text width
-	gutterWidth := ui.GutterWidth(e.buffer.NumLines())
-	visibleCols := e.terminal.Width() - gutterWidth
-
-	e.cursor.UpdateScroll(visibleRows, visibleCols)
+	e.arrangeWindows()
 }
 
-// creates a view struct for rendering.
-func (e *Editor) buildView() ui.EditorView {
+// creates the frame to render: a view per window plus the message bar.
+func (e *Editor) buildView() ui.Frame {
 	// Show command buffer in command mode, search prompt in search mode
 	msg := e.message
 	if e.mode == ModeCommand {
 		msg = e.commandBuf
+	} else if e.mode == ModePrompt {
+		msg = e.prompt.text
 	} else if e.mode == ModeSearch {
 		prefix := "/"
 		if e.search.Direction == SearchBackward {
@@ -934,24 +1128,23 @@ func (e *Editor) buildView() ui.EditorView {
 		msg = prefix + e.searchBuf
 	}
 
-	view := ui.EditorView{
-		Lines:      e.buffer.GetLines(),
-		FileName:   e.buffer.FileName(),
-		IsModified: e.buffer.IsModified(),
-		CursorRow:  e.cursor.Row(),
-		CursorCol:  e.cursor.Col(),
-		RowOffset:  e.cursor.RowOffset(),
-		ColOffset:  e.cursor.ColOffset(),
-		ModeName:   e.mode.ShortString(),
+	frame := ui.Frame{
+		Tabs:       e.tabLabels(),
 		Message:    msg,
 		TermWidth:  e.terminal.Width(),
 		TermHeight: e.terminal.Height(),
-		TotalLines: e.buffer.NumLines(),
+		Output:     e.output,
 	}
 
 	// Explorer mode
 	if e.mode == ModeExplore {
-		view.IsExplorer = true
+		view := e.windowView(e.window)
+		view.Top, view.Left = 0, 0
+		view.Width, view.Height = frame.TermWidth, frame.TermHeight-2
+
+		frame.Tabs = nil // the explorer takes the whole screen
+		frame.Windows = []ui.EditorView{view}
+		frame.IsExplorer = true
 		entries := make([]ui.ExplorerViewEntry, len(e.explorer.Entries))
 		for i, ent := range e.explorer.Entries {
 			entries[i] = ui.ExplorerViewEntry{
@@ -960,20 +1153,60 @@ func (e *Editor) buildView() ui.EditorView {
 				Size:        ent.Size,
 			}
 		}
-		view.Explorer = ui.ExplorerView{
+		frame.Explorer = ui.ExplorerView{
 			Dir:       e.explorer.Dir,
 			Entries:   entries,
 			CursorRow: e.explorer.CursorRow,
 			RowOffset: e.explorer.RowOffset,
 		}
-		return view
+		return frame
+	}
+
+	windows := e.windows()
+	for _, w := range windows {
+		frame.Windows = append(frame.Windows, e.windowView(w))
+	}
+	return frame
+}
+
+// creates the view of a single window.
+func (e *Editor) windowView(w *window) ui.EditorView {
+	modeName := e.mode.ShortString()
+
+	view := ui.EditorView{
+		Lines:      w.buffer,
+		FileName:   w.buffer.FileName(),
+		IsModified: w.buffer.IsModified(),
+		CursorRow:  w.cursor.Row(),
+		CursorCol:  w.cursor.Col(),
+		RowOffset:  w.cursor.RowOffset(),
+		ColOffset:  w.cursor.ColOffset(),
+		ModeName:   modeName,
+		Recording:  e.recordingLabel(),
+		TotalLines: w.buffer.NumLines(),
+		FileFormat: fileFormatLabel(w.buffer),
+		PlainText:  w.largeFile(e.largeFileMB),
+
+		Highlighter: w.highlighter,
+
+		TabStop:        w.bufOpts.tabStop,
+		Number:         w.winOpts.number,
+		RelativeNumber: w.winOpts.relativeNumber,
+		Wrap:           w.winOpts.wrap,
+
+		Top:       w.top,
+		Left:      w.left,
+		Width:     w.width,
+		Height:    w.height,
+		Active:    w == e.window,
+		Separator: w.left+w.width < e.terminal.Width(),
 	}
 
 	// Search highlighting
-	if e.search.Active && len(e.search.Matches) > 0 {
+	if w.search.Active && len(w.search.Matches) > 0 {
 		view.SearchActive = true
 		view.SearchMatches = make(map[int][]ui.MatchRange)
-		for _, m := range e.search.Matches {
+		for _, m := range w.search.Matches {
 			view.SearchMatches[m.Row] = append(view.SearchMatches[m.Row], ui.MatchRange{
 				ColStart: m.ColStart,
 				ColEnd:   m.ColEnd,
@@ -981,12 +1214,31 @@ func (e *Editor) buildView() ui.EditorView {
 		}
 	}
 
-	// Bracket matching
-	match := FindMatchingBracket(e.buffer.GetLines(), e.cursor.Row(), e.cursor.Col())
-	if match != nil {
-		view.BracketMatch = &ui.BracketMatchView{
-			Row: match.Row,
-			Col: match.Col,
+	// Match counter of the search, for the window with the cursor
+	if w == e.window && w.search.Active && len(w.search.Matches) > 0 {
+		view.SearchCount = fmt.Sprintf("[%d/%d]", w.search.Counter(w.cursor.Row(), w.cursor.Col()), len(w.search.Matches))
+	}
+
+	// Visual selection, in the window it is made in
+	if w == e.window && e.mode.IsVisual() {
+		view.Selection = e.visualSelection(view.RowOffset, view.RowOffset+view.Height-1)
+	}
+
+	// The match a confirmed substitution asks about
+	if w == e.window && e.subMatch != nil {
+		view.Selection = map[int]ui.MatchRange{
+			e.subMatch.Row: {ColStart: e.subMatch.ColStart, ColEnd: e.subMatch.ColEnd},
+		}
+	}
+
+	// Bracket matching (skipped in large file mode, the scan can cover the whole file)
+	if !view.PlainText {
+		match := FindMatchingBracket(w.buffer, w.cursor.Row(), w.cursor.Col())
+		if match != nil {
+			view.BracketMatch = &ui.BracketMatchView{
+				Row: match.Row,
+				Col: match.Col,
+			}
 		}
 	}
 
@@ -0,0 +1,54 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// returns an editor on a buffer holding lines, set up like New but
+// without a terminal.
+func newTestEditor(t *testing.T, lines ...string) *Editor {
+	t.Helper()
+	e := &Editor{
+		renderer:    ui.NewRenderer(nil),
+		mode:        ModeNormal,
+		writeMode:   buffer.WriteAuto,
+		bufDefaults: defaultBufferOptions,
+		winDefaults: defaultWindowOptions,
+		undoLevels:  1000,
+		magic:       true,
+		mapLeader:   `\`,
+	}
+	e.initKeymaps()
+	b := e.newBufferEntry(buffer.NewFromLines(lines, ""))
+	e.buffers = []*bufferEntry{b}
+	e.tabPage = newTabPage(b, e.winDefaults)
+	e.tabs = []*tabPage{e.tabPage}
+	return e
+}
+
+// types keys as they come from the terminal, through the mappings, and
+// then waits out timeoutlen.
+func typeKeys(t *testing.T, e *Editor, keys ...terminal.Key) {
+	t.Helper()
+	for _, key := range keys {
+		if err := e.feedKey(key, false); err != nil {
+			t.Fatalf("key %v: %v", key, err)
+		}
+	}
+	if err := e.resolveTypeahead(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// returns the keys typing text.
+func runeKeys(text string) []terminal.Key {
+	var keys []terminal.Key
+	for _, r := range text {
+		keys = append(keys, terminal.Key{Type: terminal.KeyRune, Rune: r})
+	}
+	return keys
+}
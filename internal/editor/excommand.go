@@ -0,0 +1,410 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ex command lines are parsed into a range, a command name, a ! and the
+// arguments: ":'a,'b>" or ":.,$d x". Commands that work on lines are
+// listed in rangeCommands and get the range resolved to rows before they
+// run, so a new one only says what it does with them.
+//
+// A range is one or two addresses separated by , or ; (after ; the second
+// address counts from the first, not the cursor line):
+//
+//	N        line N              .      the cursor line
+//	$        the last line       %      every line (1,$)
+//	'x       the line of mark x, '< and '> the last visual selection
+//	/pat/    the next line containing pat, ?pat? the previous one
+//
+// Every address can be followed by offsets: .+3, $-1, /pat/+, 'a-2. An
+// address left out next to a separator is the cursor line (",5", "3,").
+
+// the kinds of line addresses.
+type addressKind int
+
+const (
+	addrCurrent addressKind = iota // . (or only an offset: +3)
+	addrNumber                     // N
+	addrLast                       // $
+	addrMark                       // 'x
+	addrSearch                     // /pat/ forward, ?pat? backward
+)
+
+// a line address of a range.
+type address struct {
+	kind     addressKind
+	number   int    // addrNumber: the line number
+	mark     rune   // addrMark: the mark name
+	pattern  string // addrSearch: the pattern
+	backward bool   // addrSearch: ?pat?
+	offset   int    // the sum of the +N and -N after the address
+
+	setCurrent bool // followed by ;: the next address counts from this line
+}
+
+// a parsed command line.
+type exCommand struct {
+	addrs []address // the range as typed: no addresses for none
+	name  string    // the command name as typed, "" for a bare range (:42)
+	bang  bool      // a ! after the name
+	args  string    // the rest of the line, without leading blanks
+
+	first, last int // the range as rows, resolved before the command runs
+}
+
+// a command that works on a range of lines.
+type rangeCommand struct {
+	name      string // the full name
+	short     string // the shortest abbreviation of name accepted
+	wholeFile bool   // without a range it works on every line, not the cursor line
+	run       func(e *Editor, c *exCommand) error
+}
+
+// filled in by init: commands like :normal run other commands, which go
+// through this list again.
+var rangeCommands []*rangeCommand
+
+func init() {
+	rangeCommands = []*rangeCommand{
+		{name: "delete", short: "d", run: (*Editor).commandDelete},
+		{name: "yank", short: "y", run: (*Editor).commandYank},
+		{name: "move", short: "m", run: (*Editor).commandMove},
+		{name: "copy", short: "co", run: (*Editor).commandCopy},
+		{name: "t", short: "t", run: (*Editor).commandCopy},
+		{name: ">", short: ">", run: (*Editor).commandShift},
+		{name: "<", short: "<", run: (*Editor).commandShift},
+		{name: "join", short: "j", run: (*Editor).commandJoin},
+		{name: "normal", short: "norm", run: (*Editor).commandNormal},
+		{name: "substitute", short: "s", run: (*Editor).commandSubstitute},
+		{name: "global", short: "g", wholeFile: true, run: (*Editor).commandGlobal},
+		{name: "vglobal", short: "v", wholeFile: true, run: (*Editor).commandGlobal},
+		{name: "write", short: "w", wholeFile: true, run: (*Editor).commandWriteRange},
+		{name: "print", short: "p", run: (*Editor).commandPrint},
+		{name: "number", short: "nu", run: (*Editor).commandPrint},
+		{name: "#", short: "#", run: (*Editor).commandPrint},
+	}
+}
+
+// returns the range command called name, which may be abbreviated down
+// to its short name, or nil.
+func findRangeCommand(name string) *rangeCommand {
+	for _, rc := range rangeCommands {
+		if abbreviates(name, rc.name, rc.short) {
+			return rc
+		}
+	}
+	return nil
+}
+
+// reports whether name is full, or an abbreviation of it no shorter than
+// short.
+func abbreviates(name, full, short string) bool {
+	return len(name) >= len(short) && strings.HasPrefix(full, name)
+}
+
+// runs a command that has a range or works on lines: resolves the range
+// and hands it to the command. A bare range goes to its last line.
+func (e *Editor) runRangeCommand(c *exCommand) error {
+	rc := findRangeCommand(c.name)
+	if c.name != "" && rc == nil {
+		return fmt.Errorf("no range allowed for :%s", c.name)
+	}
+	if err := e.resolveRange(c, rc != nil && rc.wholeFile); err != nil {
+		return err
+	}
+	if rc == nil {
+		return e.commandGotoLine(c.last + 1)
+	}
+	return rc.run(e, c)
+}
+
+// --- Parsing ---
+
+// parses a command line into its range, name, ! and arguments.
+func parseCommand(line string) (*exCommand, error) {
+	c := &exCommand{}
+	s := line
+	for sep := false; ; sep = true {
+		s = strings.TrimLeft(s, " \t")
+		found := false
+		if rest, ok := strings.CutPrefix(s, "%"); ok {
+			c.addrs = append(c.addrs, address{kind: addrNumber, number: 1}, address{kind: addrLast})
+			s, found = rest, true
+		} else {
+			a, rest, ok, err := parseAddress(s)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				c.addrs = append(c.addrs, a)
+				s, found = rest, true
+			}
+		}
+
+		s = strings.TrimLeft(s, " \t")
+		more := s != "" && (s[0] == ',' || s[0] == ';')
+		if !found && (sep || more) {
+			c.addrs = append(c.addrs, address{kind: addrCurrent})
+		}
+		if !more {
+			break
+		}
+		if s[0] == ';' {
+			c.addrs[len(c.addrs)-1].setCurrent = true
+		}
+		s = s[1:]
+	}
+
+	// the name is a run of letters, or a single other character (:>, :#)
+	n := 0
+	for n < len(s) && (s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z') {
+		n++
+	}
+	if n == 0 && s != "" {
+		_, n = utf8.DecodeRuneInString(s)
+	}
+	c.name, s = s[:n], s[n:]
+	if rest, ok := strings.CutPrefix(s, "!"); ok && c.name != "" {
+		c.bang, s = true, rest
+	}
+	c.args = strings.TrimLeft(s, " \t")
+	return c, nil
+}
+
+// parses the line address at the start of s with its offsets. ok is false
+// when s doesn't start with one.
+func parseAddress(s string) (a address, rest string, ok bool, err error) {
+	switch {
+	case s == "":
+		return a, s, false, nil
+	case isDigit(s[0]):
+		n := 0
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+		a.kind = addrNumber
+		a.number, _ = strconv.Atoi(s[:n])
+		s = s[n:]
+	case s[0] == '.':
+		a.kind, s = addrCurrent, s[1:]
+	case s[0] == '$':
+		a.kind, s = addrLast, s[1:]
+	case s[0] == '\'':
+		name, size := utf8.DecodeRuneInString(s[1:])
+		if size == 0 {
+			return a, s, false, fmt.Errorf("missing mark name")
+		}
+		a.kind, a.mark, s = addrMark, name, s[1+size:]
+	case s[0] == '/' || s[0] == '?':
+		a.kind, a.backward = addrSearch, s[0] == '?'
+		a.pattern, s, _ = splitPattern(s[1:], rune(s[0]))
+	case s[0] == '+' || s[0] == '-':
+		a.kind = addrCurrent
+	default:
+		return a, s, false, nil
+	}
+
+	// offsets: +N, -N, + and - alone for 1, a number alone for +N
+	for s != "" {
+		sign := 1
+		switch {
+		case s[0] == '+':
+			s = s[1:]
+		case s[0] == '-':
+			sign, s = -1, s[1:]
+		case isDigit(s[0]) && a.kind != addrNumber:
+		default:
+			return a, s, true, nil
+		}
+		n := 0
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+		step := 1
+		if n > 0 {
+			step, _ = strconv.Atoi(s[:n])
+		}
+		a.offset += sign * step
+		s = s[n:]
+	}
+	return a, s, true, nil
+}
+
+// splits s at the first delim that is not escaped with a backslash.
+// Returns the text before it, with \delim turned into delim, and the text
+// after it; closed is false when there is no delim and pattern is all of s.
+func splitPattern(s string, delim rune) (pattern, rest string, closed bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == delim:
+			return b.String(), s[i+size:], true
+		case r == '\\' && i+size < len(s):
+			next, nsize := utf8.DecodeRuneInString(s[i+size:])
+			if next != delim {
+				b.WriteRune(r)
+			}
+			b.WriteRune(next)
+			size += nsize
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String(), "", false
+}
+
+// reports whether r can delimit the pattern of :s and :g: anything but a
+// letter, a digit, a blank, \, " and |.
+func isPatternDelimiter(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) &&
+		r != '\\' && r != '"' && r != '|'
+}
+
+// reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// --- Resolving ---
+
+// resolves the range of c to rows. Without a range it is the cursor line,
+// or every line with wholeFile. Line 0 counts as line 1 and a range given
+// backwards is turned round.
+func (e *Editor) resolveRange(c *exCommand, wholeFile bool) error {
+	n := e.buffer.NumLines()
+	if len(c.addrs) == 0 {
+		c.first, c.last = e.cursor.Row(), e.cursor.Row()
+		if wholeFile {
+			c.first, c.last = 0, n-1
+		}
+		return nil
+	}
+
+	cur := e.cursor.Row() + 1
+	lines := make([]int, 0, len(c.addrs))
+	for _, a := range c.addrs {
+		lnum, err := e.resolveAddress(a, cur)
+		if err != nil {
+			return err
+		}
+		if lnum > n {
+			return fmt.Errorf("invalid range")
+		}
+		if a.setCurrent {
+			cur = lnum
+		}
+		lines = append(lines, lnum)
+	}
+
+	// only the last two addresses count
+	if len(lines) == 1 {
+		lines = append(lines, lines[0])
+	}
+	first, last := lines[len(lines)-2], lines[len(lines)-1]
+	if first > last {
+		first, last = last, first
+	}
+	c.first, c.last = max(first, 1)-1, max(last, 1)-1
+	return nil
+}
+
+// returns the line number (1 for the first line, 0 before it) address a
+// stands for, counting from line cur.
+func (e *Editor) resolveAddress(a address, cur int) (int, error) {
+	var lnum int
+	switch a.kind {
+	case addrCurrent:
+		lnum = cur
+	case addrNumber:
+		lnum = a.number
+	case addrLast:
+		lnum = e.buffer.NumLines()
+	case addrMark:
+		row, _, err := e.markPosition(a.mark)
+		if err != nil {
+			return 0, err
+		}
+		lnum = row + 1
+	case addrSearch:
+		pattern, err := e.patternOrLast(a.pattern)
+		if err != nil {
+			return 0, err
+		}
+		re, err := e.compilePattern(pattern, caseOptions)
+		if err != nil {
+			return 0, err
+		}
+		row, ok := e.searchLine(re, cur-1, a.backward)
+		if !ok {
+			return 0, fmt.Errorf("pattern not found: %s", pattern)
+		}
+		lnum = row + 1
+	}
+
+	lnum += a.offset
+	if lnum < 0 {
+		return 0, fmt.Errorf("invalid range")
+	}
+	return lnum, nil
+}
+
+// parses the address a command like :m and :t takes as its argument and
+// returns its line number, 0 for before the first line.
+func (e *Editor) targetLine(arg string) (int, error) {
+	a, rest, ok, err := parseAddress(strings.TrimSpace(arg))
+	if err != nil {
+		return 0, err
+	}
+	if !ok || strings.TrimSpace(rest) != "" {
+		return 0, fmt.Errorf("invalid address: %s", arg)
+	}
+	lnum, err := e.resolveAddress(a, e.cursor.Row()+1)
+	if err != nil {
+		return 0, err
+	}
+	if lnum > e.buffer.NumLines() {
+		return 0, fmt.Errorf("invalid range")
+	}
+	return lnum, nil
+}
+
+// makes count lines from the last line of the range the range, as for
+// ":d 3" or ":>3"; it stops at the end of the buffer.
+func (e *Editor) applyCount(c *exCommand, count int) {
+	if count > 0 {
+		c.first = c.last
+		c.last = min(c.last+count, e.buffer.NumLines()) - 1
+	}
+}
+
+// parses an optional count, as taken by :d, :>, :j, ...
+func parseCount(arg string) (int, error) {
+	if arg == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid count: %s", arg)
+	}
+	return n, nil
+}
+
+// splits the arguments of :d and :y into a register name and a count,
+// both optional (":d a 3").
+func parseRegisterCount(arg string) (reg rune, count int, err error) {
+	if r, size := utf8.DecodeRuneInString(arg); size > 0 && !unicode.IsDigit(r) {
+		if !isRegisterName(r) || isReadOnlyRegister(r) {
+			return 0, 0, fmt.Errorf("invalid register: %c", r)
+		}
+		reg, arg = r, strings.TrimLeft(arg[size:], " \t")
+	}
+	count, err = parseCount(arg)
+	return reg, count, err
+}
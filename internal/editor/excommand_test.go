@@ -0,0 +1,152 @@
+package editor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	cur := address{kind: addrCurrent}
+	num := func(n int) address { return address{kind: addrNumber, number: n} }
+	last := address{kind: addrLast}
+	tests := []struct {
+		line  string
+		addrs []address
+		name  string
+		bang  bool
+		args  string
+	}{
+		{"d", nil, "d", false, ""},
+		{"42", []address{num(42)}, "", false, ""},
+		{"%s/a/b/", []address{num(1), last}, "s", false, "/a/b/"},
+		{".,$d x", []address{cur, last}, "d", false, "x"},
+		{"3,5m0", []address{num(3), num(5)}, "m", false, "0"},
+		{" 1 , 2 d", []address{num(1), num(2)}, "d", false, ""},
+		{",5d", []address{cur, num(5)}, "d", false, ""},
+		{"3,d", []address{num(3), cur}, "d", false, ""},
+		{",", []address{cur, cur}, "", false, ""},
+		{"2;+1d", []address{{kind: addrNumber, number: 2, setCurrent: true}, {kind: addrCurrent, offset: 1}}, "d", false, ""},
+		{"'a,'bd", []address{{kind: addrMark, mark: 'a'}, {kind: addrMark, mark: 'b'}}, "d", false, ""},
+		{"'<,'>normal A;", []address{{kind: addrMark, mark: '<'}, {kind: addrMark, mark: '>'}}, "normal", false, "A;"},
+		{"g!/x/d", nil, "g", true, "/x/d"},
+		{"w! file", nil, "w", true, "file"},
+		{">3", nil, ">", false, "3"},
+		{".,+2#", []address{cur, {kind: addrCurrent, offset: 2}}, "#", false, ""},
+		{"!ls", nil, "!", false, "ls"},
+	}
+	for _, tt := range tests {
+		c, err := parseCommand(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.addrs, tt.addrs) {
+			t.Errorf("%q: addresses %+v, want %+v", tt.line, c.addrs, tt.addrs)
+		}
+		if c.name != tt.name || c.bang != tt.bang || c.args != tt.args {
+			t.Errorf("%q: name %q, bang %v, args %q, want %q, %v, %q",
+				tt.line, c.name, c.bang, c.args, tt.name, tt.bang, tt.args)
+		}
+	}
+
+	if _, err := parseCommand("'"); err == nil {
+		t.Error("a ' without a mark name gave no error")
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		s    string
+		want address
+		rest string
+		ok   bool
+	}{
+		{"", address{}, "", false},
+		{"d", address{}, "d", false},
+		{"12d", address{kind: addrNumber, number: 12}, "d", true},
+		{".", address{kind: addrCurrent}, "", true},
+		{"$-1", address{kind: addrLast, offset: -1}, "", true},
+		{".+3", address{kind: addrCurrent, offset: 3}, "", true},
+		{"+", address{kind: addrCurrent, offset: 1}, "", true},
+		{"--", address{kind: addrCurrent, offset: -2}, "", true},
+		{"-2+5", address{kind: addrCurrent, offset: 3}, "", true},
+		{".5", address{kind: addrCurrent, offset: 5}, "", true},
+		{"3+2", address{kind: addrNumber, number: 3, offset: 2}, "", true},
+		{"'a-2,", address{kind: addrMark, mark: 'a', offset: -2}, ",", true},
+		{"'é", address{kind: addrMark, mark: 'é'}, "", true},
+		{"/foo/+", address{kind: addrSearch, pattern: "foo", offset: 1}, "", true},
+		{`/a\/b/d`, address{kind: addrSearch, pattern: "a/b"}, "d", true},
+		{"?bar?", address{kind: addrSearch, pattern: "bar", backward: true}, "", true},
+		{"/open", address{kind: addrSearch, pattern: "open"}, "", true},
+		{"//", address{kind: addrSearch}, "", true},
+	}
+	for _, tt := range tests {
+		a, rest, ok, err := parseAddress(tt.s)
+		if err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+			continue
+		}
+		if ok != tt.ok || (ok && a != tt.want) || rest != tt.rest {
+			t.Errorf("%q: %+v, rest %q, ok %v, want %+v, %q, %v", tt.s, a, rest, ok, tt.want, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestResolveRange(t *testing.T) {
+	tests := []struct {
+		line        string
+		wholeFile   bool
+		first, last int
+		err         string
+	}{
+		{"p", false, 2, 2, ""},
+		{"p", true, 0, 5, ""},
+		{"%p", false, 0, 5, ""},
+		{"2,4p", false, 1, 3, ""},
+		{"4,2p", false, 1, 3, ""},
+		{"0p", false, 0, 0, ""},
+		{".,$p", false, 2, 5, ""},
+		{".-1,.+1p", false, 1, 3, ""},
+		{"$-1p", false, 4, 4, ""},
+		{"2;+2p", false, 1, 3, ""},
+		{"2,+2p", false, 1, 4, ""},
+		{"1,2,5p", false, 1, 4, ""},
+		{"'a,'bp", false, 1, 4, ""},
+		{"/four/p", false, 3, 3, ""},
+		{"/one/p", false, 0, 0, ""},
+		{"?two?,/five/p", false, 1, 4, ""},
+		{"/f/;/f/p", false, 3, 4, ""},
+		{"/f/,/f/p", false, 3, 3, ""},
+
+		{"7p", false, 0, 0, "invalid range"},
+		{"$+1p", false, 0, 0, "invalid range"},
+		{"1-2p", false, 0, 0, "invalid range"},
+		{"'zp", false, 0, 0, "mark not set"},
+		{"/nothing/p", false, 0, 0, "pattern not found"},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "one", "two", "three", "four", "five", "two more")
+		e.cursor.MoveTo(1, 0, e.buffer)
+		e.setMark('a')
+		e.cursor.MoveTo(4, 0, e.buffer)
+		e.setMark('b')
+		e.cursor.MoveTo(2, 0, e.buffer)
+
+		c, err := parseCommand(tt.line)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.line, err)
+		}
+		err = e.resolveRange(c, tt.wholeFile)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(strings.ToLower(err.Error()), tt.err) {
+				t.Errorf("%q: error %v, want %q", tt.line, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.line, err)
+		case c.first != tt.first || c.last != tt.last:
+			t.Errorf("%q: rows %d-%d, want %d-%d", tt.line, c.first, c.last, tt.first, tt.last)
+		}
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/AdityaKrSingh26/Glime/internal/buffer"
-	"github.com/AdityaKrSingh26/Glime/internal/cursor"
 	"github.com/AdityaKrSingh26/Glime/internal/terminal"
 )
 
@@ -23,9 +21,6 @@ type ExplorerState struct {
 	Entries   []ExplorerEntry
 	CursorRow int
 	RowOffset int
-
-	savedBuffer *buffer.Buffer
-	savedCursor *cursor.Cursor
 }
 
 // reads the directory and populates entries, sorted dirs-first then files (case-insensitive).
@@ -162,16 +157,13 @@ func (e *Editor) resolveExplorerDir(dir string) (string, error) {
 	return os.Getwd()
 }
 
-// opens the explorer, saving current buffer state.
+// opens the explorer; the current buffer stays in the buffer list.
 func (e *Editor) commandExplore(dir string) error {
 	dir, err := e.resolveExplorerDir(dir)
 	if err != nil {
 		return err
 	}
 
-	e.explorer.savedBuffer = e.buffer
-	e.explorer.savedCursor = e.cursor
-
 	if err := e.explorer.LoadDir(dir); err != nil {
 		e.setMessage(fmt.Sprintf("Explore: %v", err))
 		return nil
@@ -198,35 +190,18 @@ func (e *Editor) explorerOpen() {
 		return
 	}
 
-	// opening a file — warn if saved buffer has unsaved changes
-	if e.explorer.savedBuffer != nil && e.explorer.savedBuffer.IsModified() {
-		e.setMessage("Unsaved changes! Use :q! or save first")
-		return
-	}
-
-	// restore cursor so LoadFile works on a fresh state
-	e.cursor = cursor.New()
-	e.buffer = buffer.New()
-
-	if err := e.LoadFile(fullPath); err != nil {
+	if err := e.editFile(fullPath); err != nil {
 		e.setMessage(fmt.Sprintf("Error opening file: %v", err))
 		return
 	}
 
-	e.explorer.savedBuffer = nil
-	e.explorer.savedCursor = nil
-	e.setMode(ModeNormal)
+	if e.mode == ModeExplore { // editFile may have opened a swap prompt
+		e.mode = ModeNormal // keep the file info message
+	}
 }
 
-// exits the explorer and restores the previous buffer.
+// exits the explorer and returns to the current buffer.
 func (e *Editor) explorerQuit() {
-	if e.explorer.savedBuffer != nil {
-		e.buffer = e.explorer.savedBuffer
-		e.cursor = e.explorer.savedCursor
-		e.renderer.SetLanguage(e.buffer.FilePath())
-		e.explorer.savedBuffer = nil
-		e.explorer.savedCursor = nil
-	}
 	e.setMode(ModeNormal)
 	e.setMessage("")
 }
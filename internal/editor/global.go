@@ -0,0 +1,99 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// :[range]g/pattern/command runs an Ex command on every line of the range
+// (the whole file by default) that matches pattern, :[range]v/pattern/command
+// or :g! on every line that doesn't. The lines are marked first and the
+// command then runs with the cursor on each marked line still there, so a
+// command that deletes or adds lines doesn't throw the others off. The
+// command is :p when left out; any other one works, with its own range
+// counted from the marked line (:g/^func/.,+2d, :g/TODO/normal A;). The
+// whole run is undone in one step.
+
+// the state of a running :g.
+type globalCommand struct {
+	output         []string // lines printed by :p, shown together at the end
+	subs, subLines int      // substitutions :s made and the lines they are on
+}
+
+// runs a command on the lines matching, or with :v and :g! not matching,
+// a pattern (:[range]g[!]/pat/cmd, :[range]v/pat/cmd).
+func (e *Editor) commandGlobal(c *exCommand) error {
+	if e.global != nil {
+		return fmt.Errorf("cannot nest :global")
+	}
+	invert := c.bang || c.name[0] == 'v'
+	delim, size := utf8.DecodeRuneInString(c.args)
+	if size == 0 || !isPatternDelimiter(delim) {
+		return fmt.Errorf("regular expression missing from :%s", c.name)
+	}
+	pattern, cmd, _ := splitPattern(c.args[size:], delim)
+	pattern, err := e.patternOrLast(pattern)
+	if err != nil {
+		return err
+	}
+	re, err := e.compilePattern(pattern, caseOptions)
+	if err != nil {
+		return err
+	}
+	e.lastPattern = pattern
+	if strings.TrimSpace(cmd) == "" {
+		cmd = "p"
+	}
+
+	buf := e.buffer
+	var marks []*buffer.Mark
+	for row := c.first; row <= c.last; row++ {
+		line, _ := buf.GetLine(row)
+		if re.MatchString(line) != invert {
+			marks = append(marks, buf.NewMark(row, 0))
+		}
+	}
+	if len(marks) == 0 {
+		if invert {
+			return fmt.Errorf("pattern found in every line: %s", pattern)
+		}
+		return fmt.Errorf("pattern not found: %s", pattern)
+	}
+
+	g := &globalCommand{}
+	e.global = g
+	e.undoMgr.BeginBatch()
+	defer func() {
+		for _, m := range marks {
+			buf.DropMark(m)
+		}
+		e.undoMgr.EndBatch()
+		e.global = nil
+	}()
+
+	for _, m := range marks {
+		if m.Deleted {
+			continue
+		}
+		e.cursor.MoveTo(m.Row, 0, e.buffer)
+		if err := e.executeCommand(cmd); err != nil {
+			return err
+		}
+		if e.shouldQuit || e.buffer != buf {
+			break // the command left the buffer
+		}
+	}
+
+	switch {
+	case len(g.output) == 1:
+		e.setMessage(g.output[0])
+	case len(g.output) > 1:
+		e.showOutput(g.output)
+	case g.subs > 0:
+		e.setMessage(fmt.Sprintf("%s on %s", plural(g.subs, "substitution"), plural(g.subLines, "line")))
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGlobal(t *testing.T) {
+	lines := []string{"x 1", "y 2", "x 3", "y 4", "x 5"}
+	tests := []struct {
+		cmd  string
+		want []string
+	}{
+		{"g/x/d", []string{"y 2", "y 4"}},
+		{"v/x/d", []string{"x 1", "x 3", "x 5"}},
+		{"g!/x/d", []string{"x 1", "x 3", "x 5"}},
+		{"2,4g/x/d", []string{"x 1", "y 2", "y 4", "x 5"}},
+		{"g/y/.,+1d", []string{"x 1"}},
+		{"g/y/m0", []string{"y 4", "y 2", "x 1", "x 3", "x 5"}},
+		{"g/x/t.", []string{"x 1", "x 1", "y 2", "x 3", "x 3", "y 4", "x 5", "x 5"}},
+		{"g/^/m0", []string{"x 5", "y 4", "x 3", "y 2", "x 1"}},
+		{"g/x/s/\\d/#/", []string{"x #", "y 2", "x #", "y 4", "x #"}},
+		{"g/y/normal Az", []string{"x 1", "y 2z", "x 3", "y 4z", "x 5"}},
+		{"g/x/j", []string{"x 1 y 2", "x 3 y 4", "x 5"}},
+		{"g#y#d", []string{"x 1", "x 3", "x 5"}},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, lines...)
+		if err := e.executeCommand(tt.cmd); err != nil {
+			t.Errorf(":%s: %v", tt.cmd, err)
+		}
+		if got := e.buffer.GetLines(); !slices.Equal(got, tt.want) {
+			t.Errorf(":%s: lines %q, want %q", tt.cmd, got, tt.want)
+		}
+
+		// the whole run is one undo step
+		typeKeys(t, e, runeKeys("u")...)
+		if got := e.buffer.GetLines(); !slices.Equal(got, lines) {
+			t.Errorf(":%s then u: lines %q, want %q", tt.cmd, got, lines)
+		}
+	}
+}
+
+func TestGlobalErrors(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"g/z/d", "pattern not found: z"},
+		{"v/ /d", "pattern found in every line:  "},
+		{"g", "regular expression missing from :g"},
+		{"g a", "regular expression missing from :g"},
+		{"g/x/g/x/d", "cannot nest :global"},
+		{"g/x/s/x/y/c", "cannot ask for confirmation under :global"},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "x 1", "y 2")
+		err := e.executeCommand(tt.cmd)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf(":%s: error %v, want %q", tt.cmd, err, tt.want)
+		}
+	}
+}
+
+// the marks :g sets on the lines stay with them as the command moves
+// them, and the user's marks stay with theirs.
+func TestGlobalMarks(t *testing.T) {
+	e := newTestEditor(t, "a", "x", "b", "x", "c")
+	for _, m := range []struct {
+		name rune
+		row  int
+	}{{'a', 0}, {'b', 2}, {'c', 4}, {'x', 3}} {
+		e.cursor.MoveTo(m.row, 0, e.buffer)
+		e.setMark(m.name)
+	}
+
+	// each x line is moved to the top in turn: marks made before the
+	// moves must follow the lines, or the second x would be missed
+	if err := e.executeCommand("g/x/m0"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"x", "x", "a", "b", "c"}
+	if got := e.buffer.GetLines(); !slices.Equal(got, want) {
+		t.Errorf("lines %q, want %q", got, want)
+	}
+	for name, row := range map[rune]int{'a': 2, 'b': 3, 'c': 4} {
+		if r, _, err := e.markPosition(name); err != nil || r != row {
+			t.Errorf("mark %c on row %d (%v), want %d", name, r, err, row)
+		}
+	}
+
+	if err := e.executeCommand("g/x/d"); err != nil {
+		t.Fatal(err)
+	}
+	for name, row := range map[rune]int{'a': 0, 'b': 1, 'c': 2} {
+		if r, _, err := e.markPosition(name); err != nil || r != row {
+			t.Errorf("after :g/x/d mark %c on row %d (%v), want %d", name, r, err, row)
+		}
+	}
+	if _, _, err := e.markPosition('x'); err == nil {
+		t.Error("the mark on a deleted line is still set")
+	}
+}
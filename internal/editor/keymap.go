@@ -0,0 +1,319 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// key mappings: typed keys go through a table of mappings for the current
+// mode before the mode handles them. A key sequence that starts a mapping
+// waits for the keys that follow it; when they don't complete it, or
+// nothing is typed for timeoutlen, the keys are used as they are (or as
+// the shorter mapping they complete). The keys of a :map are mapped again,
+// those of a :noremap are not. The explorer takes the Normal mode
+// mappings, as a netrw buffer does in Vim.
+//
+// Mapping modes follow Vim: n (Normal), v (Visual), o (Operator-pending,
+// after d, c, y, ...), i (Insert) and c (the command line of : and /).
+
+// expansions of mappings within mappings beyond this are taken for a
+// mapping that maps itself forever.
+const maxMapDepth = 1000
+
+// the default mappings: keys that do what other keys do. They are in the
+// tables with the user's, so they can be mapped to something else or
+// removed with :unmap. The commands of each mode (h, x, i, ...) are not
+// mappings but are built into it; a mapping of their keys is used instead.
+var defaultMappings = []struct {
+	modes    string
+	lhs, rhs string
+}{
+	{"nvo", "<Left>", "h"},
+	{"nvo", "<Right>", "l"},
+	{"nvo", "<Up>", "k"},
+	{"nvo", "<Down>", "j"},
+	{"nvo", "<Home>", "0"},
+	{"nvo", "<End>", "$"},
+	{"nvo", "<BS>", "h"},
+	{"nvo", "<Space>", "l"},
+	{"nv", "<Del>", "x"},
+	{"n", "<Insert>", "i"},
+	// with the kitty keyboard protocol Ctrl-i and Ctrl-m are keys of their
+	// own; they still type a Tab and a line break unless mapped otherwise
+	{"ic", "<C-i>", "<Tab>"},
+	{"ic", "<C-m>", "<CR>"},
+}
+
+// a mapping of one key sequence to another.
+type mapping struct {
+	lhs     []terminal.Key
+	rhs     []terminal.Key
+	noremap bool
+}
+
+// the mappings of one mode, by the notation of their left-hand side.
+type keymap map[string]*mapping
+
+// a key waiting in the typeahead; noremap keys come from a :noremap or a
+// mapping to itself and are not looked up again.
+type typedKey struct {
+	key     terminal.Key
+	noremap bool
+}
+
+// loads the default mappings.
+func (e *Editor) initKeymaps() {
+	e.keymaps = make(map[rune]keymap)
+	for _, d := range defaultMappings {
+		for _, mode := range d.modes {
+			e.addMapping(mode, parseKeys(d.lhs, ""), parseKeys(d.rhs, ""), true)
+		}
+	}
+}
+
+// adds a mapping to the table of mode, replacing one with the same lhs.
+func (e *Editor) addMapping(mode rune, lhs, rhs []terminal.Key, noremap bool) {
+	if e.keymaps[mode] == nil {
+		e.keymaps[mode] = make(keymap)
+	}
+	e.keymaps[mode][keysNotation(lhs)] = &mapping{lhs: lhs, rhs: rhs, noremap: noremap}
+}
+
+// returns the mapping mode of the keys typed next, or 0 when they are not
+// mapped: after a key that takes another (f, ", g, Ctrl-w, ...) and in a
+// prompt.
+func (e *Editor) mapMode() rune {
+	if e.pending.Prefix != 0 || e.pending.Operator == ctrlW {
+		return 0
+	}
+	switch {
+	case e.mode == ModeNormal && e.pending.Operator != 0:
+		return 'o'
+	case e.mode == ModeNormal, e.mode == ModeExplore:
+		return 'n'
+	case e.mode.IsVisual():
+		return 'v'
+	case e.mode == ModeInsert:
+		return 'i'
+	case e.mode == ModeCommand, e.mode == ModeSearch:
+		return 'c'
+	}
+	return 0
+}
+
+// feeds a typed key to the editor through the mappings; with noremap it
+// is used as it is, once the keys typed before it are.
+func (e *Editor) feedKey(key terminal.Key, noremap bool) error {
+	e.typeahead = append(e.typeahead, typedKey{key: key, noremap: noremap})
+	return e.resolveTypeahead(false)
+}
+
+// reports whether typed keys wait for more keys to tell which mapping
+// they are.
+func (e *Editor) typeaheadWaiting() bool {
+	return len(e.typeahead) > 0
+}
+
+// maps and runs the keys in the typeahead. Keys that could still become a
+// longer mapping wait, unless timedOut says no more keys are coming.
+func (e *Editor) resolveTypeahead(timedOut bool) error {
+	depth := 0
+	for len(e.typeahead) > 0 && !e.shouldQuit {
+		m, complete := e.matchMapping(timedOut)
+		if !complete {
+			return nil // wait for the next key
+		}
+
+		if m != nil {
+			if depth++; depth > maxMapDepth {
+				e.typeahead = nil
+				e.setMessage("E223: recursive mapping")
+				e.commandFailed()
+				return nil
+			}
+			// a mapping to itself doesn't map its first key again
+			self := !m.noremap && len(m.rhs) >= len(m.lhs) && keysNotation(m.rhs[:len(m.lhs)]) == keysNotation(m.lhs)
+			expanded := make([]typedKey, len(m.rhs))
+			for i, k := range m.rhs {
+				expanded[i] = typedKey{key: k, noremap: m.noremap || self && i == 0}
+			}
+			e.typeahead = append(expanded, e.typeahead[len(m.lhs):]...)
+			continue
+		}
+
+		// the first key is not mapped: run it. Keys fed while it runs (by
+		// a macro) go before the rest of the typeahead.
+		key, rest := e.typeahead[0].key, e.typeahead[1:]
+		e.typeahead = nil
+		err := e.processKey(&key)
+		e.typeahead = append(e.typeahead, rest...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looks up the typeahead in the mappings of the current mode. Returns the
+// mapping it starts with, or nil when its first key is not mapped;
+// complete is false while more keys could make a longer mapping.
+func (e *Editor) matchMapping(timedOut bool) (m *mapping, complete bool) {
+	mode := e.mapMode()
+	if mode == 0 || e.typeahead[0].noremap {
+		return nil, true
+	}
+
+	// the keys that can be looked up: up to the first noremap one
+	typed := make([]string, 0, len(e.typeahead))
+	for _, t := range e.typeahead {
+		if t.noremap {
+			break
+		}
+		typed = append(typed, keyNotation(t.key))
+	}
+
+	longer := false
+	for _, cand := range e.keymaps[mode] {
+		n := len(cand.lhs)
+		matched := 0
+		for matched < min(n, len(typed)) && keyNotation(cand.lhs[matched]) == typed[matched] {
+			matched++
+		}
+		switch {
+		case matched == n && (m == nil || n > len(m.lhs)):
+			m = cand
+		case matched == len(typed) && n > len(typed):
+			longer = true
+		}
+	}
+	if longer && !timedOut {
+		return nil, false
+	}
+	return m, true
+}
+
+// returns how long to wait for the key that tells an incomplete mapping
+// from a longer one, or 0 to wait as long as it takes (:set notimeout).
+func (e *Editor) mapTimeout() time.Duration {
+	if !e.timeout {
+		return 0
+	}
+	return time.Duration(e.timeoutLen) * time.Millisecond
+}
+
+// --- Commands ---
+
+// handles :map and its variants. cmd is the command name, which says the
+// modes and whether the mapping is remapped ("nnoremap"), args the rest of
+// the line: nothing to list mappings, an lhs to list those starting with
+// it, or an lhs and rhs to add one.
+func (e *Editor) commandMap(cmd, args string) error {
+	modes, noremap := mapCommandModes(cmd)
+
+	lhsText, rhsText, _ := strings.Cut(strings.TrimLeft(args, " \t"), " ")
+	rhsText = strings.TrimLeft(rhsText, " \t")
+	lhs := parseKeys(lhsText, e.mapLeader)
+
+	if rhsText == "" {
+		return e.listMappings(modes, lhs)
+	}
+
+	rhs := parseKeys(rhsText, e.mapLeader)
+	if strings.EqualFold(rhsText, "<Nop>") {
+		rhs = nil
+	}
+	for _, mode := range modes {
+		e.addMapping(mode, lhs, rhs, noremap)
+	}
+	return nil
+}
+
+// handles :unmap and its variants: removes the mapping of args.
+func (e *Editor) commandUnmap(cmd, args string) error {
+	modes, _ := mapCommandModes(strings.Replace(cmd, "unmap", "map", 1))
+	lhs := keysNotation(parseKeys(strings.TrimSpace(args), e.mapLeader))
+	if lhs == "" {
+		return fmt.Errorf("E474: Invalid argument")
+	}
+
+	found := false
+	for _, mode := range modes {
+		if _, ok := e.keymaps[mode][lhs]; ok {
+			delete(e.keymaps[mode], lhs)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("E31: No such mapping")
+	}
+	return nil
+}
+
+// returns the modes a map command works on and whether it is a noremap
+// one: "map" and "noremap" for Normal, Visual and Operator-pending,
+// otherwise the mode letter before "map" or "noremap".
+func mapCommandModes(cmd string) (modes string, noremap bool) {
+	name, noremap := strings.CutSuffix(cmd, "noremap")
+	if !noremap {
+		name = strings.TrimSuffix(cmd, "map")
+	}
+	if name == "" {
+		return "nvo", noremap
+	}
+	return name, noremap
+}
+
+// shows the mappings of modes whose lhs starts with prefix, one line per
+// mapping with the modes it is in (blank for Normal, Visual and
+// Operator-pending) and a * for noremap.
+func (e *Editor) listMappings(modes string, prefix []terminal.Key) error {
+	start := keysNotation(prefix)
+	type entry struct{ modes, lhs, rhs string }
+	var entries []*entry
+	byMapping := make(map[string]*entry)
+	for _, mode := range modes {
+		for lhs, m := range e.keymaps[mode] {
+			if !strings.HasPrefix(lhs, start) {
+				continue
+			}
+			rhs := "  " + keysNotation(m.rhs)
+			if m.rhs == nil {
+				rhs = "  <Nop>"
+			}
+			if m.noremap {
+				rhs = "*" + rhs[1:]
+			}
+			if en, ok := byMapping[lhs+"\x00"+rhs]; ok {
+				en.modes += string(mode)
+				continue
+			}
+			en := &entry{string(mode), lhs, rhs}
+			byMapping[lhs+"\x00"+rhs] = en
+			entries = append(entries, en)
+		}
+	}
+	if len(entries) == 0 {
+		e.setMessage("No mapping found")
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].lhs != entries[j].lhs {
+			return entries[i].lhs < entries[j].lhs
+		}
+		return entries[i].modes < entries[j].modes
+	})
+	lines := make([]string, len(entries))
+	for i, en := range entries {
+		if en.modes == "nvo" {
+			en.modes = ""
+		}
+		lines[i] = fmt.Sprintf("%-3s %-12s %s", en.modes, en.lhs, en.rhs)
+	}
+	e.showOutput(lines)
+	return nil
+}
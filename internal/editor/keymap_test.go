@@ -0,0 +1,107 @@
+package editor
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// the keys the kitty keyboard protocol sends for Ctrl-i and Ctrl-m.
+var (
+	kittyCtrlI = terminal.Key{Type: terminal.KeyCtrl, Rune: 'i', Ctrl: true}
+	kittyCtrlM = terminal.Key{Type: terminal.KeyCtrl, Rune: 'm', Ctrl: true}
+)
+
+// Ctrl-i and Ctrl-m type what Tab and Enter do.
+func TestCtrlIAndCtrlMInInsertMode(t *testing.T) {
+	withCtrl := newTestEditor(t, "ab")
+	typeKeys(t, withCtrl, runeKeys("a")...)
+	typeKeys(t, withCtrl, kittyCtrlI, kittyCtrlM)
+
+	withKeys := newTestEditor(t, "ab")
+	typeKeys(t, withKeys, runeKeys("a")...)
+	typeKeys(t, withKeys, terminal.Key{Type: terminal.KeyTab}, terminal.Key{Type: terminal.KeyEnter})
+
+	got, want := withCtrl.buffer.GetLines(), withKeys.buffer.GetLines()
+	if len(got) != 2 || !slices.Equal(got, want) {
+		t.Errorf("lines %q, want %q", got, want)
+	}
+}
+
+func TestCtrlMOnCommandLine(t *testing.T) {
+	e := newTestEditor(t, "foo", "bar")
+	typeKeys(t, e, runeKeys(":s/foo/baz/")...)
+	typeKeys(t, e, kittyCtrlM)
+	if e.mode != ModeNormal {
+		t.Errorf("mode %v after Ctrl-m, want Normal", e.mode)
+	}
+	if line, _ := e.buffer.GetLine(0); line != "baz" {
+		t.Errorf("line 0 is %q, want %q", line, "baz")
+	}
+
+	typeKeys(t, e, runeKeys("/bar")...)
+	typeKeys(t, e, kittyCtrlM)
+	if e.mode != ModeNormal || e.cursor.Row() != 1 {
+		t.Errorf("after /bar Ctrl-m: mode %v, row %d, want Normal on row 1", e.mode, e.cursor.Row())
+	}
+}
+
+func TestCtrlIAndCtrlMRemapped(t *testing.T) {
+	e := newTestEditor(t, "")
+	if err := e.executeCommand("inoremap <C-i> x"); err != nil {
+		t.Fatal(err)
+	}
+	typeKeys(t, e, runeKeys("i")...)
+	typeKeys(t, e, kittyCtrlI)
+	if line, _ := e.buffer.GetLine(0); line != "x" {
+		t.Errorf("line %q after a mapped Ctrl-i, want %q", line, "x")
+	}
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyEscape})
+
+	if err := e.executeCommand("cunmap <C-m>"); err != nil {
+		t.Fatal(err)
+	}
+	typeKeys(t, e, runeKeys(":")...)
+	typeKeys(t, e, kittyCtrlM)
+	if e.mode != ModeCommand {
+		t.Errorf("mode %v after an unmapped Ctrl-m, want Command", e.mode)
+	}
+}
+
+func TestDefaultMappings(t *testing.T) {
+	e := newTestEditor(t, "abcd")
+	typeKeys(t, e, runeKeys("$")...)
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyBackspace}, terminal.Key{Type: terminal.KeyDelete})
+	if line, _ := e.buffer.GetLine(0); line != "abd" {
+		t.Errorf("line %q after <BS><Del>, want %q", line, "abd")
+	}
+
+	// a default mapping can be replaced like any other
+	if err := e.executeCommand("nnoremap <Del> 0"); err != nil {
+		t.Fatal(err)
+	}
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyDelete})
+	if line, _ := e.buffer.GetLine(0); line != "abd" || e.cursor.Col() != 0 {
+		t.Errorf("line %q, col %d after a remapped <Del>, want %q at col 0", line, e.cursor.Col(), "abd")
+	}
+}
+
+func TestExplorerMappings(t *testing.T) {
+	e := newTestEditor(t, "")
+	e.explorer = ExplorerState{Entries: []ExplorerEntry{{Name: "../", IsDir: true}, {Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	e.mode = ModeExplore
+
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyArrowDown})
+	if e.explorer.CursorRow != 1 {
+		t.Errorf("row %d after <Down>, want 1", e.explorer.CursorRow)
+	}
+
+	if err := e.executeCommand("nnoremap J jj"); err != nil {
+		t.Fatal(err)
+	}
+	typeKeys(t, e, runeKeys("J")...)
+	if e.explorer.CursorRow != 3 {
+		t.Errorf("row %d after a mapped J, want 3", e.explorer.CursorRow)
+	}
+}
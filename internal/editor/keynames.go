@@ -0,0 +1,178 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// key notation, as in Vim: a printable character stands for itself, other
+// keys are written in angle brackets, e.g. <CR>, <Esc>, <C-w>, <M-x>,
+// <S-Tab>, <C-Left> or <F5>. <lt> is a literal <, <leader> the mapleader.
+
+// names of the keys written in angle brackets, lower case.
+var keyNames = map[string]terminal.Key{
+	"cr":       {Type: terminal.KeyEnter},
+	"enter":    {Type: terminal.KeyEnter},
+	"return":   {Type: terminal.KeyEnter},
+	"esc":      {Type: terminal.KeyEscape},
+	"tab":      {Type: terminal.KeyTab},
+	"bs":       {Type: terminal.KeyBackspace},
+	"del":      {Type: terminal.KeyDelete},
+	"insert":   {Type: terminal.KeyInsert},
+	"up":       {Type: terminal.KeyArrowUp},
+	"down":     {Type: terminal.KeyArrowDown},
+	"left":     {Type: terminal.KeyArrowLeft},
+	"right":    {Type: terminal.KeyArrowRight},
+	"home":     {Type: terminal.KeyHome},
+	"end":      {Type: terminal.KeyEnd},
+	"pageup":   {Type: terminal.KeyPageUp},
+	"pagedown": {Type: terminal.KeyPageDown},
+	"space":    {Type: terminal.KeyRune, Rune: ' '},
+	"lt":       {Type: terminal.KeyRune, Rune: '<'},
+	"bar":      {Type: terminal.KeyRune, Rune: '|'},
+	"bslash":   {Type: terminal.KeyRune, Rune: '\\'},
+}
+
+// the names keys are shown with, in the case Vim uses.
+var keyLabels = map[terminal.KeyType]string{
+	terminal.KeyEnter:      "CR",
+	terminal.KeyEscape:     "Esc",
+	terminal.KeyTab:        "Tab",
+	terminal.KeyBackspace:  "BS",
+	terminal.KeyDelete:     "Del",
+	terminal.KeyInsert:     "Insert",
+	terminal.KeyArrowUp:    "Up",
+	terminal.KeyArrowDown:  "Down",
+	terminal.KeyArrowLeft:  "Left",
+	terminal.KeyArrowRight: "Right",
+	terminal.KeyHome:       "Home",
+	terminal.KeyEnd:        "End",
+	terminal.KeyPageUp:     "PageUp",
+	terminal.KeyPageDown:   "PageDown",
+}
+
+func init() {
+	for n := range 12 {
+		name := "F" + strconv.Itoa(n+1)
+		keyNames[strings.ToLower(name)] = terminal.Key{Type: terminal.KeyF1 + terminal.KeyType(n)}
+		keyLabels[terminal.KeyF1+terminal.KeyType(n)] = name
+	}
+}
+
+// returns the keys written in notation s. leader replaces <leader>. Text
+// in angle brackets that names no key stays as it is.
+func parseKeys(s, leader string) []terminal.Key {
+	var keys []terminal.Key
+	for s != "" {
+		if s[0] == '<' {
+			if end := strings.IndexByte(s[1:], '>'); end > 0 {
+				name := s[1 : end+1]
+				if strings.EqualFold(name, "leader") {
+					keys = append(keys, parseKeys(leader, "")...)
+					s = s[end+2:]
+					continue
+				}
+				if k, ok := parseKeyName(name); ok {
+					keys = append(keys, k)
+					s = s[end+2:]
+					continue
+				}
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s)
+		keys = append(keys, textKeys(s[:size])...)
+		s = s[size:]
+	}
+	return keys
+}
+
+// returns the key written as name inside angle brackets, e.g. "C-w",
+// "S-Tab" or "F5".
+func parseKeyName(name string) (terminal.Key, bool) {
+	var ctrl, alt, shift bool
+	for len(name) > 2 && name[1] == '-' {
+		switch unicode.ToLower(rune(name[0])) {
+		case 'c':
+			ctrl = true
+		case 'm', 'a':
+			alt = true
+		case 's':
+			shift = true
+		default:
+			return terminal.Key{}, false
+		}
+		name = name[2:]
+	}
+
+	k, ok := keyNames[strings.ToLower(name)]
+	if !ok {
+		r := []rune(name)
+		if len(r) != 1 {
+			return terminal.Key{}, false
+		}
+		k = terminal.Key{Type: terminal.KeyRune, Rune: r[0]}
+	}
+
+	if k.Type == terminal.KeyRune {
+		switch {
+		case ctrl && k.Rune == '[':
+			k = terminal.Key{Type: terminal.KeyEscape} // Ctrl-[ is Escape
+		case ctrl:
+			k = terminal.Key{Type: terminal.KeyCtrl, Rune: unicode.ToLower(k.Rune), Ctrl: true}
+		case shift:
+			k.Rune = unicode.ToUpper(k.Rune)
+		}
+		k.Alt = alt
+		return k, true
+	}
+	k.Ctrl, k.Alt, k.Shift = ctrl, alt, shift
+	return k, true
+}
+
+// returns key in key notation, or "" for events that are not keys (mouse,
+// paste, focus). Keys that are the same key give the same text.
+func keyNotation(k terminal.Key) string {
+	var mods string
+	if k.Ctrl && k.Type != terminal.KeyCtrl {
+		mods += "C-"
+	}
+	if k.Alt {
+		mods += "M-"
+	}
+	if k.Shift && k.Type != terminal.KeyRune && k.Type != terminal.KeyCtrl {
+		mods += "S-"
+	}
+
+	switch k.Type {
+	case terminal.KeyRune:
+		switch k.Rune {
+		case '<':
+			return "<" + mods + "lt>"
+		case ' ':
+			return "<" + mods + "Space>"
+		}
+		if mods != "" {
+			return "<" + mods + string(k.Rune) + ">"
+		}
+		return string(k.Rune)
+	case terminal.KeyCtrl:
+		return "<C-" + mods + string(k.Rune) + ">"
+	}
+	if label, ok := keyLabels[k.Type]; ok {
+		return "<" + mods + label + ">"
+	}
+	return ""
+}
+
+// returns keys in key notation.
+func keysNotation(keys []terminal.Key) string {
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(keyNotation(k))
+	}
+	return b.String()
+}
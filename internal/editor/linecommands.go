@@ -0,0 +1,256 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// the Ex commands working on the lines of a range (see excommand.go).
+// c.first and c.last are the rows of the range.
+
+// deletes the lines into a register (:[range]d [x] [count]).
+func (e *Editor) commandDelete(c *exCommand) error {
+	reg, count, err := parseRegisterCount(c.args)
+	if err != nil {
+		return err
+	}
+	e.applyCount(c, count)
+
+	saved := e.regName
+	e.regName = reg
+	defer func() { e.regName = saved }()
+	e.applyOperator('d', region{kind: regionLine, startRow: c.first, endRow: c.last})
+	return nil
+}
+
+// copies the lines into a register (:[range]y [x] [count]); the cursor
+// stays where it is.
+func (e *Editor) commandYank(c *exCommand) error {
+	reg, count, err := parseRegisterCount(c.args)
+	if err != nil {
+		return err
+	}
+	e.applyCount(c, count)
+
+	saved := e.regName
+	e.regName = reg
+	defer func() { e.regName = saved }()
+	e.yankRegion(region{kind: regionLine, startRow: c.first, endRow: c.last})
+	e.reportYank(c.last - c.first + 1)
+	return nil
+}
+
+// moves the lines below line {address}, 0 for the top
+// (:[range]m {address}).
+func (e *Editor) commandMove(c *exCommand) error {
+	target, err := e.targetLine(c.args)
+	if err != nil {
+		return err
+	}
+	if target > c.first && target < c.last+1 {
+		return fmt.Errorf("cannot move a range of lines into itself")
+	}
+
+	lines := e.lineRange(c.first, c.last)
+	n := len(lines)
+	switch {
+	case target == c.first || target == c.last+1:
+		target = c.first // the lines are there already
+	case target > c.last:
+		e.undoMgr.BeginGroup()
+		e.replaceLines(target, target-1, lines)
+		e.replaceLines(c.first, c.last, nil)
+		e.undoMgr.EndGroup()
+		target -= n
+	default:
+		e.undoMgr.BeginGroup()
+		e.replaceLines(c.first, c.last, nil)
+		e.replaceLines(target, target-1, lines)
+		e.undoMgr.EndGroup()
+	}
+	e.cursor.MoveTo(target+n-1, 0, e.buffer)
+	e.moveToFirstNonBlank()
+	return nil
+}
+
+// puts a copy of the lines below line {address}, 0 for the top
+// (:[range]t {address}, :[range]co {address}).
+func (e *Editor) commandCopy(c *exCommand) error {
+	target, err := e.targetLine(c.args)
+	if err != nil {
+		return err
+	}
+
+	lines := e.lineRange(c.first, c.last)
+	e.undoMgr.BeginGroup()
+	e.replaceLines(target, target-1, lines)
+	e.undoMgr.EndGroup()
+	e.cursor.MoveTo(target+len(lines)-1, 0, e.buffer)
+	e.moveToFirstNonBlank()
+	return nil
+}
+
+// shifts the lines one shiftwidth right or left, one more for every
+// repeated > or < (:[range]>> [count]).
+func (e *Editor) commandShift(c *exCommand) error {
+	levels := 1
+	arg := c.args
+	for strings.HasPrefix(arg, c.name) {
+		levels++
+		arg = strings.TrimLeft(arg[1:], " \t")
+	}
+	count, err := parseCount(arg)
+	if err != nil {
+		return err
+	}
+	e.applyCount(c, count)
+	if c.name == "<" {
+		levels = -levels
+	}
+
+	e.undoMgr.BeginGroup()
+	e.shiftLines(c.first, c.last, levels)
+	e.undoMgr.EndGroup()
+	e.cursor.MoveTo(c.last, 0, e.buffer)
+	e.moveToFirstNonBlank()
+	return nil
+}
+
+// joins the lines, or the line with the next one for a single line;
+// with ! without adding or removing white space (:[range]j[!] [count]).
+func (e *Editor) commandJoin(c *exCommand) error {
+	count, err := parseCount(c.args)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		c.first, c.last = c.last, c.last+count-1
+	}
+	if c.last == c.first {
+		c.last++
+	}
+	c.last = min(c.last, e.buffer.NumLines()-1)
+	if c.last <= c.first {
+		return nil
+	}
+
+	e.undoMgr.BeginGroup()
+	defer e.undoMgr.EndGroup()
+	if !c.bang {
+		e.joinLineRange(c.first, c.last)
+		return nil
+	}
+	lines := e.lineRange(c.first, c.last)
+	col := len([]rune(strings.Join(lines[:len(lines)-1], "")))
+	e.replaceLines(c.first, c.last, []string{strings.Join(lines, "")})
+	e.cursor.MoveTo(c.first, col, e.buffer)
+	return nil
+}
+
+// runs {commands} as Normal mode keys typed on every line of the range,
+// from its start; with ! mappings are not used (:[range]norm[!] {commands}).
+// Keys are written as in a mapping, <Esc> for Escape. A command left
+// unfinished is ended as if Escape was typed. The changes of all lines
+// are undone together.
+func (e *Editor) commandNormal(c *exCommand) error {
+	if c.args == "" {
+		return fmt.Errorf("argument required")
+	}
+	keys := parseKeys(c.args, e.mapLeader)
+
+	e.undoMgr.BeginBatch()
+	defer e.undoMgr.EndBatch()
+	if len(c.addrs) == 0 {
+		return e.runNormal(keys, c.bang)
+	}
+	for row := c.first; row <= c.last && row < e.buffer.NumLines(); row++ {
+		e.cursor.MoveTo(row, 0, e.buffer)
+		if err := e.runNormal(keys, c.bang); err != nil || e.shouldQuit {
+			return err
+		}
+	}
+	return nil
+}
+
+// runs keys in Normal mode, then ends what they left unfinished.
+func (e *Editor) runNormal(keys []terminal.Key, noremap bool) error {
+	e.setMode(ModeNormal)
+	e.pending.Reset()
+	for _, key := range keys {
+		if err := e.feedKey(key, noremap); err != nil {
+			return err
+		}
+		if e.shouldQuit {
+			return nil
+		}
+	}
+	if err := e.resolveTypeahead(true); err != nil {
+		return err
+	}
+	if e.mode != ModeNormal || !e.commandComplete() {
+		return e.feedKey(terminal.Key{Type: terminal.KeyEscape}, true)
+	}
+	return nil
+}
+
+// writes the lines to file, or with ! over the current file; a range of
+// the whole buffer writes it as :w does (:[range]w[!] [file]).
+func (e *Editor) commandWriteRange(c *exCommand) error {
+	file := c.args
+	if c.first == 0 && c.last == e.buffer.NumLines()-1 {
+		if file != "" {
+			return e.commandWriteAs(file, c.bang)
+		}
+		return e.commandWrite(c.bang)
+	}
+
+	if file == "" {
+		if !c.bang {
+			return fmt.Errorf("use ! to write partial buffer")
+		}
+		if file = e.buffer.FilePath(); file == "" {
+			return fmt.Errorf("no file name")
+		}
+	} else if !c.bang && buffer.Exists(file) {
+		return fmt.Errorf("\"%s\" exists (add ! to override)", file)
+	}
+
+	part := buffer.NewFromLines(e.lineRange(c.first, c.last), file)
+	part.SetFormat(e.buffer.Format())
+	if err := buffer.Save(file, part, e.saveOptions()); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	e.setMessage(fmt.Sprintf("\"%s\" %dL written", file, c.last-c.first+1))
+	return nil
+}
+
+// shows the lines, with their numbers for :nu and :# or with a #
+// flag, and puts the cursor on the last one (:[range]p [#]).
+func (e *Editor) commandPrint(c *exCommand) error {
+	number := !strings.HasPrefix("print", c.name) || c.args == "#"
+	if c.args != "" && c.args != "#" {
+		return fmt.Errorf("trailing characters: %s", c.args)
+	}
+
+	lines := e.lineRange(c.first, c.last)
+	if number {
+		for i, line := range lines {
+			lines[i] = fmt.Sprintf("%3d %s", c.first+i+1, line)
+		}
+	}
+	e.cursor.MoveTo(c.last, 0, e.buffer)
+	e.moveToFirstNonBlank()
+	if e.global != nil {
+		e.global.output = append(e.global.output, lines...)
+		return nil
+	}
+	if len(lines) == 1 {
+		e.setMessage(lines[0])
+		return nil
+	}
+	e.showOutput(lines)
+	return nil
+}
@@ -0,0 +1,223 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// macros: q{a-z} records the keys typed from then on into a register, up to
+// the q that ends the recording; qA appends to register a. @{a-z} plays the
+// keys back as if they were typed again, @@ plays the last one again and
+// @: repeats the last command line. Text yanked into a register plays back
+// as the keys typing it. A command that fails during playback (a motion
+// that can't move, a search without a match, an Ex error) stops the macro,
+// like in Vim, so 100@a can run a macro over the rest of the buffer.
+
+// nested playback deeper than this is taken for a macro calling itself
+// without ever failing.
+const maxMacroDepth = 100
+
+// the state of q recording and @ playback.
+type macroState struct {
+	recording rune // register being recorded into, 0 if none
+	keys      []terminal.Key
+	last      rune // register last played, for @@
+	depth     int  // nested playbacks running
+	failed    bool // a command failed; a playback in progress stops
+}
+
+// handles the register name after q: starts recording into it.
+func (e *Editor) startRecording(reg rune) {
+	if !isMacroRegister(reg) {
+		e.commandFailed()
+		return
+	}
+	e.macro.recording = reg
+	e.macro.keys = nil
+	if unicode.IsUpper(reg) {
+		e.macro.keys = registerKeys(e.getRegister(reg))
+	}
+}
+
+// ends the recording (q) and stores the keys in the register, without the
+// q itself.
+func (e *Editor) stopRecording() {
+	keys := e.macro.keys
+	if n := len(keys); n > 0 {
+		keys = keys[:n-1]
+	}
+	e.putRegister(unicode.ToLower(e.macro.recording), Register{Content: keysText(keys), Keys: keys})
+	e.macro.recording = 0
+	e.macro.keys = nil
+}
+
+// adds a typed key to the macro being recorded. Keys played back by @ or .
+// are not recorded again: the @ and . keys that started them are.
+func (e *Editor) recordMacroKey(key *terminal.Key) {
+	if e.macro.recording != 0 {
+		e.macro.keys = append(e.macro.keys, *key)
+	}
+}
+
+// plays the keys of register reg count times (@a, 3@a, @@).
+func (e *Editor) runMacro(reg rune, count int) error {
+	if reg == '@' {
+		if e.macro.last == 0 {
+			e.setMessage("E748: No previously used register")
+			e.commandFailed()
+			return nil
+		}
+		reg = e.macro.last
+	}
+	if !isMacroRegister(reg) && reg != ':' {
+		e.commandFailed()
+		return nil
+	}
+	reg = unicode.ToLower(reg)
+	e.macro.last = reg
+
+	keys := registerKeys(e.getRegister(reg))
+	if reg == ':' {
+		keys = textKeys(":" + e.getRegister(reg).Content + "\r")
+	}
+
+	if e.macro.depth == 0 {
+		e.macro.failed = false
+	} else if e.macro.depth >= maxMacroDepth {
+		e.setMessage(fmt.Sprintf("E223: recursive macro @%c", reg))
+		e.commandFailed()
+		return nil
+	}
+	e.macro.depth++
+	defer func() { e.macro.depth-- }()
+
+	// every command of the macro is a change of its own for .; the keys
+	// are mapped like typed ones, except those of a command line
+	e.pending.Reset()
+	e.typing = nil
+	for n := 0; n < max(count, 1); n++ {
+		for i := range keys {
+			if err := e.feedKey(keys[i], reg == ':'); err != nil {
+				return err
+			}
+			if e.macro.failed || e.shouldQuit {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// marks the command being run as failed, which stops a macro playing.
+func (e *Editor) commandFailed() {
+	e.macro.failed = true
+}
+
+// reports whether reg names a register macros can be recorded into.
+func isMacroRegister(reg rune) bool {
+	return reg >= 'a' && reg <= 'z' || reg >= 'A' && reg <= 'Z' || reg >= '0' && reg <= '9' || reg == '"'
+}
+
+// returns the keys to play for reg: the recorded ones, or those typing its
+// text, with a line break after every line of a linewise register.
+func registerKeys(reg Register) []terminal.Key {
+	if reg.Keys != nil {
+		return reg.Keys
+	}
+	if reg.Type == RegisterLine {
+		return textKeys(reg.Content + "\n")
+	}
+	return textKeys(reg.Content)
+}
+
+// returns the text of keys, kept in the register next to them: control
+// keys as their control characters, cursor keys as their escape sequences.
+func keysText(keys []terminal.Key) string {
+	var b strings.Builder
+	for _, k := range keys {
+		switch k.Type {
+		case terminal.KeyRune:
+			b.WriteRune(k.Rune)
+		case terminal.KeyPaste:
+			b.WriteString(k.Text)
+		case terminal.KeyEnter:
+			b.WriteByte('\r')
+		case terminal.KeyEscape:
+			b.WriteByte(0x1b)
+		case terminal.KeyTab:
+			b.WriteByte('\t')
+		case terminal.KeyBackspace:
+			b.WriteByte(0x7f)
+		case terminal.KeyCtrl:
+			if k.Rune >= 'a' && k.Rune <= 'z' || k.Rune >= '[' && k.Rune <= '_' {
+				b.WriteRune(k.Rune & 0x1f)
+			}
+		default:
+			b.WriteString(keySequences[k.Type])
+		}
+	}
+	return b.String()
+}
+
+// the escape sequences of the cursor and function keys, as the terminal
+// sends them.
+var keySequences = map[terminal.KeyType]string{
+	terminal.KeyArrowUp:    "\x1b[A",
+	terminal.KeyArrowDown:  "\x1b[B",
+	terminal.KeyArrowRight: "\x1b[C",
+	terminal.KeyArrowLeft:  "\x1b[D",
+	terminal.KeyHome:       "\x1b[H",
+	terminal.KeyEnd:        "\x1b[F",
+	terminal.KeyPageUp:     "\x1b[5~",
+	terminal.KeyPageDown:   "\x1b[6~",
+	terminal.KeyDelete:     "\x1b[3~",
+	terminal.KeyInsert:     "\x1b[2~",
+	terminal.KeyF1:         "\x1bOP",
+	terminal.KeyF2:         "\x1bOQ",
+	terminal.KeyF3:         "\x1bOR",
+	terminal.KeyF4:         "\x1bOS",
+	terminal.KeyF5:         "\x1b[15~",
+	terminal.KeyF6:         "\x1b[17~",
+	terminal.KeyF7:         "\x1b[18~",
+	terminal.KeyF8:         "\x1b[19~",
+	terminal.KeyF9:         "\x1b[20~",
+	terminal.KeyF10:        "\x1b[21~",
+	terminal.KeyF11:        "\x1b[23~",
+	terminal.KeyF12:        "\x1b[24~",
+}
+
+// returns the keys typing s. Control characters become the keys sending
+// them; escape sequences are not decoded.
+func textKeys(s string) []terminal.Key {
+	keys := make([]terminal.Key, 0, len(s))
+	for _, r := range s {
+		var k terminal.Key
+		switch {
+		case r == '\r' || r == '\n':
+			k.Type = terminal.KeyEnter
+		case r == 0x1b:
+			k.Type = terminal.KeyEscape
+		case r == '\t':
+			k.Type = terminal.KeyTab
+		case r == 0x7f || r == 0x08:
+			k.Type = terminal.KeyBackspace
+		case r < 0x20:
+			k = terminal.Key{Type: terminal.KeyCtrl, Rune: unicode.ToLower(r + '@'), Ctrl: true}
+		default:
+			k = terminal.Key{Type: terminal.KeyRune, Rune: r}
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// returns the status bar note for a recording in progress, e.g. "recording @a".
+func (e *Editor) recordingLabel() string {
+	if e.macro.recording == 0 {
+		return ""
+	}
+	return fmt.Sprintf("recording @%c", unicode.ToLower(e.macro.recording))
+}
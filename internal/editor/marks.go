@@ -0,0 +1,60 @@
+package editor
+
+import "fmt"
+
+// marks: m{a-z} marks the cursor position in the buffer, 'x goes to the
+// line of mark x and `x to the position itself, as motions operators can
+// take. '< and '> are the start and end of the last visual selection. The
+// marks stay with their lines as lines are added and deleted around them
+// (see buffer.Mark).
+
+// sets mark name at the cursor (m). Reports false for a name that can't
+// be set.
+func (e *Editor) setMark(name rune) bool {
+	if name < 'a' || name > 'z' {
+		return false
+	}
+	e.buffer.SetMark(name, e.cursor.Row(), e.cursor.Col())
+	return true
+}
+
+// returns the position of mark name.
+func (e *Editor) markPosition(name rune) (row, col int, err error) {
+	switch name {
+	case '<', '>':
+		v := e.lastVisual
+		if !v.mode.IsVisual() {
+			return 0, 0, fmt.Errorf("mark not set: '%c", name)
+		}
+		row, col = v.startRow, v.startCol
+		endRow, endCol := v.endRow, v.endCol
+		if endRow < row || endRow == row && endCol < col {
+			row, col, endRow, endCol = endRow, endCol, row, col
+		}
+		if name == '>' {
+			row, col = endRow, endCol
+		}
+		return min(row, e.buffer.NumLines()-1), col, nil
+	}
+	if m := e.buffer.Mark(name); m != nil {
+		return m.Row, m.Col, nil
+	}
+	return 0, 0, fmt.Errorf("mark not set: '%c", name)
+}
+
+// moves the cursor to mark name: to the first non-blank of its line (')
+// or to its position (`).
+func (e *Editor) jumpToMark(cmd, name rune) (motionKind, bool) {
+	row, col, err := e.markPosition(name)
+	if err != nil {
+		e.setMessage(fmt.Sprintf("Error: %v", err))
+		return motionExclusive, false
+	}
+	if cmd == '\'' {
+		e.cursor.MoveTo(row, 0, e.buffer)
+		e.moveToFirstNonBlank()
+		return motionLinewise, true
+	}
+	e.cursor.MoveTo(row, col, e.buffer)
+	return motionExclusive, true
+}
@@ -3,13 +3,22 @@ package editor
 type Mode int
 
 const (
-	ModeNormal  Mode = iota // default mode for navigation and commands
-	ModeInsert              // mode for inserting text
-	ModeCommand             // mode for entering commands (eg :w , :q)
-	ModeSearch              // mode for incremental search (ed /, ?)
-	ModeExplore             // mode for file explorer (netrw-style)
+	ModeNormal      Mode = iota // default mode for navigation and commands
+	ModeInsert                  // mode for inserting text
+	ModeCommand                 // mode for entering commands (eg :w , :q)
+	ModeSearch                  // mode for incremental search (ed /, ?)
+	ModeExplore                 // mode for file explorer (netrw-style)
+	ModePrompt                  // waiting for a one-key answer to a question
+	ModeVisual                  // characterwise selection (v)
+	ModeVisualLine              // linewise selection (V)
+	ModeVisualBlock             // blockwise selection (Ctrl-v)
 )
 
+// reports whether m is one of the visual modes.
+func (m Mode) IsVisual() bool {
+	return m == ModeVisual || m == ModeVisualLine || m == ModeVisualBlock
+}
+
 // represent a short string representation for the status bar
 func (m Mode) ShortString() string {
 	switch m {
@@ -23,6 +32,14 @@ func (m Mode) ShortString() string {
 		return "SRCH"
 	case ModeExplore:
 		return "EXPL"
+	case ModePrompt:
+		return "ASK"
+	case ModeVisual:
+		return "VIS"
+	case ModeVisualLine:
+		return "V-LINE"
+	case ModeVisualBlock:
+		return "V-BLOCK"
 	default:
 		return "???"
 	}
@@ -0,0 +1,260 @@
+package editor
+
+// motions move the cursor. After an operator they also decide how much
+// text the operator covers: up to the new position (exclusive), up to and
+// including the character there (inclusive) or all the lines in between
+// (linewise).
+
+type motionKind int
+
+const (
+	motionExclusive motionKind = iota
+	motionInclusive
+	motionLinewise
+)
+
+// the last f, t, F or T, repeated by ; and ,.
+type charSearch struct {
+	cmd    rune
+	target rune
+}
+
+// moves the cursor for motion m ("w", "gg", "f", ...); arg is the
+// character f, t, F and T look for or the mark ' and ` go to, and count is 0 when none was typed.
+// After an operator h and l stay on the line and w stops at the end of
+// the line holding the last word. Returns the motion's kind, or false when
+// m is not a motion or it failed.
+func (e *Editor) motion(m string, arg rune, count int, operator bool) (motionKind, bool) {
+	n := max(count, 1)
+	row, col := e.cursor.Row(), e.cursor.Col()
+	lineLen, _ := e.buffer.LineLength(row)
+
+	switch m {
+	case "h":
+		if !operator {
+			for i := 0; i < n; i++ {
+				e.cursor.MoveLeft(e.buffer)
+			}
+			return motionExclusive, e.cursor.Row() != row || e.cursor.Col() != col
+		}
+		e.cursor.MoveTo(row, col-n, e.buffer)
+		return motionExclusive, col > 0
+	case "l":
+		if !operator {
+			for i := 0; i < n; i++ {
+				e.cursor.MoveRight(e.buffer)
+			}
+			return motionExclusive, e.cursor.Row() != row || e.cursor.Col() != col
+		}
+		e.cursor.MoveTo(row, col+n, e.buffer)
+		return motionExclusive, col < lineLen
+	case "j", "k":
+		for i := 0; i < n; i++ {
+			if m == "j" {
+				e.cursor.MoveDown(e.buffer)
+			} else {
+				e.cursor.MoveUp(e.buffer)
+			}
+		}
+		return motionLinewise, e.cursor.Row() != row
+	case "0":
+		e.cursor.MoveToLineStart()
+		return motionExclusive, true
+	case "^":
+		e.moveToFirstNonBlank()
+		return motionExclusive, true
+	case "$":
+		for i := 1; i < n; i++ {
+			e.cursor.MoveDown(e.buffer)
+		}
+		e.cursor.MoveToLineEndNormal(e.buffer)
+		return motionInclusive, true
+	case "G", "gg":
+		target := 0
+		if m == "G" {
+			target = e.buffer.NumLines() - 1
+		}
+		if count > 0 {
+			target = count - 1
+		}
+		e.cursor.MoveTo(target, 0, e.buffer)
+		e.moveToFirstNonBlank()
+		return motionLinewise, true
+	case "w", "W":
+		r, c := row, col
+		for i := 0; i < n; i++ {
+			nr, nc := e.wordForward(r, c, m == "W")
+			if operator && i == n-1 && nr > r {
+				// the last word moved over ends its line: stop there
+				nr = r
+				nc, _ = e.buffer.LineLength(r)
+			}
+			r, c = nr, nc
+		}
+		e.cursor.MoveTo(r, c, e.buffer)
+		if !operator {
+			e.clampToLastChar()
+		}
+		return motionExclusive, true
+	case "b", "B":
+		r, c := row, col
+		for i := 0; i < n; i++ {
+			r, c = e.wordBackward(r, c, m == "B")
+		}
+		e.cursor.MoveTo(r, c, e.buffer)
+		return motionExclusive, true
+	case "e", "E":
+		r, c := row, col
+		for i := 0; i < n; i++ {
+			r, c = e.wordEnd(r, c, m == "E")
+		}
+		e.cursor.MoveTo(r, c, e.buffer)
+		return motionInclusive, true
+	case "'", "`":
+		return e.jumpToMark(rune(m[0]), arg)
+	case "f", "t", "F", "T":
+		e.lastFind = charSearch{cmd: rune(m[0]), target: arg}
+		return e.findChar(rune(m[0]), arg, n, false)
+	case ";", ",":
+		if e.lastFind.cmd == 0 {
+			return motionExclusive, false
+		}
+		cmd := e.lastFind.cmd
+		if m == "," {
+			cmd = reverseFind(cmd)
+		}
+		return e.findChar(cmd, e.lastFind.target, n, true)
+	case "%":
+		if count > 0 {
+			// N% goes to the line N percent into the buffer
+			if count > 100 {
+				return motionLinewise, false
+			}
+			e.cursor.MoveTo((count*e.buffer.NumLines()+99)/100-1, 0, e.buffer)
+			e.moveToFirstNonBlank()
+			return motionLinewise, true
+		}
+		return motionInclusive, e.matchPair()
+	case "}", "{":
+		r, c := row, col
+		for i := 0; i < n; i++ {
+			if m == "}" {
+				r, c = e.paragraphForward(r)
+			} else {
+				r, c = e.paragraphBackward(r)
+			}
+		}
+		e.cursor.MoveTo(r, c, e.buffer)
+		if !operator {
+			e.clampToLastChar()
+		}
+		return motionExclusive, true
+	case "n", "N":
+		for i := 0; i < n; i++ {
+			if !e.searchAgain(m == "N") {
+				return motionExclusive, false
+			}
+		}
+		return e.search.Offset.kind(), true
+	case "*", "#", "g*", "g#":
+		dir := SearchForward
+		if m == "#" || m == "g#" {
+			dir = SearchBackward
+		}
+		return motionExclusive, e.searchWord(dir, m[0] != 'g', n)
+	}
+	return motionExclusive, false
+}
+
+// moves to the count-th target on the cursor line: onto it (f, F) or next
+// to it (t, T). A repeated t or T (;) skips a target right next to the
+// cursor, so it does not get stuck.
+func (e *Editor) findChar(cmd, target rune, count int, repeat bool) (motionKind, bool) {
+	line, _ := e.buffer.GetLine(e.cursor.Row())
+	runes := []rune(line)
+	col := e.cursor.Col()
+
+	step := 1
+	kind := motionInclusive
+	if cmd == 'F' || cmd == 'T' {
+		step, kind = -1, motionExclusive
+	}
+
+	p := col
+	if repeat && (cmd == 't' || cmd == 'T') {
+		p += step
+	}
+	for i := 0; i < count; i++ {
+		for p += step; p >= 0 && p < len(runes) && runes[p] != target; p += step {
+		}
+		if p < 0 || p >= len(runes) {
+			return kind, false
+		}
+	}
+
+	switch cmd {
+	case 't':
+		p--
+	case 'T':
+		p++
+	}
+	e.cursor.MoveTo(e.cursor.Row(), p, e.buffer)
+	return kind, true
+}
+
+// returns the f/t command searching the other way.
+func reverseFind(cmd rune) rune {
+	switch cmd {
+	case 'f':
+		return 'F'
+	case 'F':
+		return 'f'
+	case 't':
+		return 'T'
+	}
+	return 't'
+}
+
+// moves to the bracket matching the one under the cursor, or the first
+// one after it on the line (%).
+func (e *Editor) matchPair() bool {
+	row := e.cursor.Row()
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+	for col := e.cursor.Col(); col < len(runes); col++ {
+		if !isBracket(runes[col]) {
+			continue
+		}
+		m := FindMatchingBracket(e.buffer, row, col)
+		if m == nil {
+			return false
+		}
+		e.cursor.MoveTo(m.Row, m.Col, e.buffer)
+		return true
+	}
+	return false
+}
+
+// reports whether r is one of the brackets % jumps between.
+func isBracket(r rune) bool {
+	for _, pair := range bracketPairs {
+		if r == pair.Open || r == pair.Close {
+			return true
+		}
+	}
+	return false
+}
+
+// moves the cursor back onto the last character when it is past the end
+// of its line, where a normal mode cursor can't be.
+func (e *Editor) clampToLastChar() {
+	lineLen, _ := e.buffer.LineLength(e.cursor.Row())
+	if e.cursor.Col() >= lineLen && lineLen > 0 {
+		e.cursor.MoveTo(e.cursor.Row(), lineLen-1, e.buffer)
+	}
+}
+
+// moves the cursor to the first non-blank character of its line.
+func (e *Editor) moveToFirstNonBlank() {
+	e.cursor.MoveTo(e.cursor.Row(), e.firstNonBlank(e.cursor.Row()), e.buffer)
+}
@@ -0,0 +1,194 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// the mouse, with :set mouse: a click puts the cursor on the character
+// under the pointer (in any window, which becomes the current one),
+// dragging selects characters in visual mode and the wheel scrolls the
+// window under the pointer without moving the cursor, unless it would
+// leave the view. In the explorer a click selects an entry and a second
+// click on it opens it.
+
+// rows scrolled by one notch of the wheel.
+const mouseScrollRows = 3
+
+// reports whether the mouse is on in the current mode: the mouse option
+// holds n (normal and the explorer), v (visual), i (insert), c (command
+// line, where only the wheel works) or a for all of them.
+func (e *Editor) mouseEnabled() bool {
+	flag := "n"
+	switch {
+	case e.mode == ModePrompt:
+		return false
+	case e.mode.IsVisual():
+		flag = "v"
+	case e.mode == ModeInsert:
+		flag = "i"
+	case e.mode == ModeCommand || e.mode == ModeSearch:
+		flag = "c"
+	}
+	return strings.Contains(e.mouse, "a") || strings.Contains(e.mouse, flag)
+}
+
+// turns the mouse on or off for the modes in value (:set mouse).
+func (e *Editor) setMouse(value string) {
+	if value != "" && e.mouse == "" {
+		e.terminal.EnableMouse()
+	} else if value == "" && e.mouse != "" {
+		e.terminal.DisableMouse()
+	}
+	e.mouse = value
+}
+
+// handles a mouse event reported by the terminal.
+func (e *Editor) processMouse(m *terminal.MouseEvent) error {
+	if !e.mouseEnabled() {
+		return nil
+	}
+	if e.mode == ModeExplore {
+		e.explorerMouse(m)
+		return nil
+	}
+
+	switch m.Button {
+	case terminal.MouseWheelUp:
+		e.scrollWindowAt(m, -mouseScrollRows)
+	case terminal.MouseWheelDown:
+		e.scrollWindowAt(m, mouseScrollRows)
+	case terminal.MouseLeft:
+		if e.mode == ModeCommand || e.mode == ModeSearch || e.block != nil {
+			return nil
+		}
+		switch m.Action {
+		case terminal.MousePress:
+			e.mouseClick(m)
+		case terminal.MouseDrag:
+			e.mouseDrag(m)
+		}
+	}
+	return nil
+}
+
+// returns the window whose text area or status line is at the pointer, or
+// nil when it is elsewhere (tab line, message bar).
+func (e *Editor) windowAt(m *terminal.MouseEvent) *window {
+	for _, w := range e.windows() {
+		if m.Row >= w.top && m.Row <= w.top+w.height && m.Col >= w.left && m.Col < w.left+w.width {
+			return w
+		}
+	}
+	return nil
+}
+
+// returns the buffer position shown in w at the pointer, clamped to the
+// lines and columns there are; a click on the gutter gives column 0.
+func (w *window) positionAt(m *terminal.MouseEvent) (row, col int) {
+	textRow := min(max(m.Row-w.top, 0), w.height-1)
+	dcol := max(m.Col-w.left-w.gutterWidth(), 0)
+
+	row = w.cursor.RowOffset()
+	if w.winOpts.wrap {
+		// walk down the lines shown above the pointer
+		for row < w.buffer.NumLines()-1 && textRow >= w.lineRows(row) {
+			textRow -= w.lineRows(row)
+			row++
+		}
+		dcol += min(textRow, w.lineRows(row)-1) * w.textWidth()
+	} else {
+		row = min(row+textRow, w.buffer.NumLines()-1)
+		dcol += w.cursor.ColOffset()
+	}
+
+	line, _ := w.buffer.GetLine(row)
+	return row, ui.ColumnAt(line, dcol, w.bufOpts.tabStop)
+}
+
+// moves the cursor to the character clicked on, entering its window. A
+// click ends visual mode and, in insert mode, starts a new undo step.
+func (e *Editor) mouseClick(m *terminal.MouseEvent) {
+	w := e.windowAt(m)
+	if w == nil {
+		return
+	}
+	if e.mode.IsVisual() {
+		e.exitVisual()
+	}
+	e.pending.Reset()
+	if w != e.window {
+		if e.mode == ModeInsert {
+			return // insert mode stays in its window
+		}
+		e.enterWindow(w)
+	}
+	if m.Row == w.top+w.height {
+		return // the status line only selects the window
+	}
+
+	if e.mode == ModeInsert {
+		e.undoMgr.EndGroup()
+		e.undoMgr.BeginGroup()
+	}
+	row, col := w.positionAt(m)
+	e.cursor.MoveTo(row, col, e.buffer)
+	if e.mode == ModeNormal {
+		e.clampToLastChar()
+	}
+}
+
+// extends a selection to the pointer while the left button is held down,
+// starting characterwise visual mode at the click that began the drag.
+func (e *Editor) mouseDrag(m *terminal.MouseEvent) {
+	if e.mode == ModeNormal {
+		e.startVisual(ModeVisual)
+	}
+	if !e.mode.IsVisual() {
+		return
+	}
+	row, col := e.window.positionAt(m)
+	e.cursor.MoveTo(row, col, e.buffer)
+	e.clampToLastChar()
+}
+
+// scrolls the window under the pointer by n rows.
+func (e *Editor) scrollWindowAt(m *terminal.MouseEvent, n int) {
+	w := e.windowAt(m)
+	if w == nil {
+		return
+	}
+	w.cursor.Scroll(n, w.height, w.buffer)
+	if w == e.window && e.mode != ModeInsert {
+		e.clampToLastChar()
+	}
+}
+
+// handles the mouse in the explorer: a click selects the entry under the
+// pointer or opens it when it is already selected, the wheel moves the
+// selection.
+func (e *Editor) explorerMouse(m *terminal.MouseEvent) {
+	switch m.Button {
+	case terminal.MouseWheelUp:
+		for range mouseScrollRows {
+			e.explorer.MoveUp()
+		}
+	case terminal.MouseWheelDown:
+		for range mouseScrollRows {
+			e.explorer.MoveDown()
+		}
+	case terminal.MouseLeft:
+		// the listing starts below the two header rows
+		entry := m.Row - 2 + e.explorer.RowOffset
+		if m.Action != terminal.MousePress || m.Row < 2 || entry >= len(e.explorer.Entries) {
+			return
+		}
+		if entry == e.explorer.CursorRow {
+			e.explorerOpen()
+			return
+		}
+		e.explorer.CursorRow = entry
+	}
+}
@@ -1,16 +1,23 @@
 package editor
 
+// pending operator value for Ctrl-w, which waits for a window command.
+const ctrlW rune = 0x17
+
 // PendingCommand tracks multi-key command state (e.g., count + operator + motion).
 type PendingCommand struct {
-	Count    int  // numeric prefix
+	Count    int  // numeric prefix (of the motion once an operator is pending)
 	Operator rune // pending operator
 	HasCount bool // whether a count has been started
+	OpCount  int  // count typed before the operator, 0 if none
+	Prefix   rune // first key of a two-key command: g, f/t/F/T waiting for a character, m/'/` for a mark
 }
 
 func (p *PendingCommand) Reset() {
 	p.Count = 0
 	p.Operator = 0
 	p.HasCount = false
+	p.OpCount = 0
+	p.Prefix = 0
 }
 
 // returns the count, default to 1 if none specified.
@@ -26,3 +33,12 @@ func (p *PendingCommand) AccumulateDigit(d int) {
 	p.Count = p.Count*10 + d
 	p.HasCount = true
 }
+
+// returns the count for an operator's motion: the operator's count times
+// the motion's (2d3w deletes six words), or 0 when neither was typed.
+func (p *PendingCommand) TotalCount() int {
+	if p.OpCount == 0 && !p.HasCount {
+		return 0
+	}
+	return max(p.OpCount, 1) * max(p.Count, 1)
+}
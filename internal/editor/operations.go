@@ -1,284 +1,216 @@
 package editor
 
-import "unicode"
+import (
+	"unicode"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// walks the buffer one character at a time. The position col == len(line)
+// stands for the line break, so motions see lines as Vim does.
+type textIter struct {
+	buf      *buffer.Buffer
+	row, col int
+	line     []rune
+}
 
-// finds the position after the next word boundary
-// return the (row, col) of the start of the next word (rune-indexed)
-func (e *Editor) findWordEnd(row, col int) (int, int) {
-	lines := e.buffer.GetLines()
-	if row >= len(lines) {
-		return row, col
+// returns an iterator at (row, col).
+func (e *Editor) iterAt(row, col int) *textIter {
+	line, _ := e.buffer.GetLine(row)
+	return &textIter{buf: e.buffer, row: row, col: col, line: []rune(line)}
+}
+
+// returns the character at the iterator, '\n' for the line break.
+func (it *textIter) char() rune {
+	if it.col >= len(it.line) {
+		return '\n'
 	}
+	return it.line[it.col]
+}
 
-	runes := []rune(lines[row])
+// moves one character forward, from a line break to the next line.
+// Returns false at the end of the buffer.
+func (it *textIter) next() bool {
+	if it.col < len(it.line) {
+		it.col++
+		return true
+	}
+	if it.row >= it.buf.NumLines()-1 {
+		return false
+	}
+	it.row++
+	it.col = 0
+	line, _ := it.buf.GetLine(it.row)
+	it.line = []rune(line)
+	return true
+}
 
-	// skip over empty lines at current position
-	for col >= len(runes) {
-		if row < len(lines)-1 {
-			row++
-			col = 0
-			runes = []rune(lines[row])
-		} else {
-			return row, col
-		}
-	}
+// moves one character back, from the start of a line to the line break
+// of the one above. Returns false at the start of the buffer.
+func (it *textIter) prev() bool {
+	if it.col > 0 {
+		it.col = min(it.col, len(it.line)) - 1
+		return true
+	}
+	if it.row == 0 {
+		return false
+	}
+	it.row--
+	line, _ := it.buf.GetLine(it.row)
+	it.line = []rune(line)
+	it.col = len(it.line)
+	return true
+}
 
-	// determine current character class
-	ch := runes[col]
-	isWord := isWordChar(ch)
-	isPunct := !isWord && !unicode.IsSpace(ch)
+// returns the class of r for word motions: 0 for white space and line
+// breaks, 1 for word characters and 2 for other characters. With bigWord
+// every non-blank is class 1 (WORD motions W, B and E).
+func charClass(r rune, bigWord bool) int {
+	switch {
+	case r == '\n' || unicode.IsSpace(r):
+		return 0
+	case bigWord || isWordChar(r):
+		return 1
+	}
+	return 2
+}
 
-	// skip current word/punctuation
-	for col < len(runes) {
-		c := runes[col]
-		if isWord && !isWordChar(c) {
-			break
+// returns the start of the next word after (row, col) (w, W). An empty
+// line counts as a word; at the end of the buffer it returns the position
+// past the last character.
+func (e *Editor) wordForward(row, col int, bigWord bool) (int, int) {
+	it := e.iterAt(row, col)
+
+	// skip the rest of the current word
+	if cls := charClass(it.char(), bigWord); cls != 0 {
+		for charClass(it.char(), bigWord) == cls {
+			if !it.next() {
+				return it.row, it.col
+			}
 		}
-		if isPunct && (isWordChar(c) || unicode.IsSpace(c)) {
+	}
+
+	// then white space and line breaks, stopping at an empty line
+	for charClass(it.char(), bigWord) == 0 {
+		if it.char() == '\n' && len(it.line) == 0 && (it.row != row || it.col != col) {
 			break
 		}
-		if unicode.IsSpace(ch) && !unicode.IsSpace(c) {
+		if !it.next() {
 			break
 		}
-		col++
-	}
-
-	for col < len(runes) && unicode.IsSpace(runes[col]) {
-		col++
 	}
-
-	// end of line, go to start of next line
-	if col >= len(runes) && row < len(lines)-1 {
-		return row + 1, 0
-	}
-
-	return row, col
+	return it.row, it.col
 }
 
-// finds the position of the previous word start (rune-indexed)
-func (e *Editor) findWordStart(row, col int) (int, int) {
-	lines := e.buffer.GetLines()
-	if row >= len(lines) {
+// returns the start of the word before (row, col) (b, B). An empty line
+// counts as a word.
+func (e *Editor) wordBackward(row, col int, bigWord bool) (int, int) {
+	it := e.iterAt(row, col)
+	if !it.prev() {
 		return row, col
 	}
 
-	// if at start of line, move to end of previous line
-	if col == 0 {
-		if row > 0 {
-			prevRunes := []rune(lines[row-1])
-			if len(prevRunes) == 0 {
-				return row - 1, 0
-			}
-			return row - 1, len(prevRunes) - 1
+	for charClass(it.char(), bigWord) == 0 {
+		if len(it.line) == 0 {
+			return it.row, 0
+		}
+		if !it.prev() {
+			return it.row, it.col
 		}
-		return 0, 0
 	}
 
-	runes := []rune(lines[row])
-	col-- // move back one
-
-	// skip trailing whitespace to get to the previous word
-	for col > 0 && unicode.IsSpace(runes[col]) {
-		col--
+	cls := charClass(it.char(), bigWord)
+	for {
+		r, c := it.row, it.col
+		if !it.prev() || it.row != r || charClass(it.char(), bigWord) != cls {
+			return r, c
+		}
 	}
+}
 
-	if col == 0 {
-		return row, 0
+// returns the last character of the next word end after (row, col) (e, E).
+func (e *Editor) wordEnd(row, col int, bigWord bool) (int, int) {
+	it := e.iterAt(row, col)
+	if !it.next() {
+		return row, col
 	}
 
-	// determine current character class
-	ch := runes[col]
-	isWord := isWordChar(ch)
-
-	// skip same class backward
-	for col > 0 {
-		prev := runes[col-1]
-		if isWord && !isWordChar(prev) {
-			break
+	for charClass(it.char(), bigWord) == 0 {
+		if !it.next() {
+			return row, col // no word ahead
 		}
-		if !isWord && (isWordChar(prev) || unicode.IsSpace(prev)) {
-			break
-		}
-		col--
 	}
 
-	return row, col
-}
-
-// deletes count lines starting from the current cursor row.
-func (e *Editor) deleteLines(count int) error {
-	row := e.cursor.Row()
-	lines := e.buffer.GetLines()
-
-	if count > len(lines)-row {
-		count = len(lines) - row
+	cls := charClass(it.char(), bigWord)
+	for {
+		r, c := it.row, it.col
+		if !it.next() || charClass(it.char(), bigWord) != cls {
+			return r, c
+		}
 	}
+}
 
-	// collect lines for yank register
-	var yanked string
-	for i := 0; i < count; i++ {
-		if i > 0 {
-			yanked += "\n"
-		}
-		yanked += lines[row+i]
+// returns the last character of the word under (row, col), which may be
+// the position itself.
+func (e *Editor) currentWordEnd(row, col int, bigWord bool) (int, int) {
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+	if col >= len(runes) {
+		return row, col
 	}
-	e.register = Register{
-		Content: yanked,
-		Type:    RegisterLine,
+	cls := charClass(runes[col], bigWord)
+	for col+1 < len(runes) && charClass(runes[col+1], bigWord) == cls {
+		col++
 	}
+	return row, col
+}
 
-	// record undo actions (reverse order so undo re-inserts bottom-up)
-	e.undoMgr.BeginGroup()
-	for i := count - 1; i >= 0; i-- {
-		lineText := lines[row+i]
-		e.undoMgr.Record(Action{
-			Type:      ActionDeleteLine,
-			Row:       row + i,
-			Text:      lineText,
-			CursorRow: e.cursor.Row(),
-			CursorCol: e.cursor.Col(),
-		})
-	}
+// reports whether line row is empty; empty lines separate paragraphs.
+func (e *Editor) isEmptyLine(row int) bool {
+	lineLen, _ := e.buffer.LineLength(row)
+	return lineLen == 0
+}
 
-	// perform deletions
-	for i := 0; i < count; i++ {
-		e.buffer.DeleteLine(row)
+// returns the empty line after the paragraph at or below row (}), or the
+// end of the last line when there is none.
+func (e *Editor) paragraphForward(row int) (int, int) {
+	last := e.buffer.NumLines() - 1
+	for row < last && e.isEmptyLine(row) {
+		row++
 	}
-	e.undoMgr.EndGroup()
-
-	// adjust cursor position
-	if row >= e.buffer.NumLines() {
-		row = e.buffer.NumLines() - 1
+	for row < last && !e.isEmptyLine(row) {
+		row++
 	}
-	if row < 0 {
-		row = 0
+	if !e.isEmptyLine(row) {
+		lineLen, _ := e.buffer.LineLength(row)
+		return row, lineLen
 	}
-	e.cursor.MoveTo(row, 0, e.buffer)
-
-	return nil
+	return row, 0
 }
 
-// deleteWord deletes from cursor to next word boundary (count times). Rune-indexed.
-func (e *Editor) deleteWord(count int) error {
-	row := e.cursor.Row()
-	col := e.cursor.Col()
-
-	endRow, endCol := row, col
-	for i := 0; i < count; i++ {
-		endRow, endCol = e.findWordEnd(endRow, endCol)
+// returns the empty line before the paragraph at or above row ({), or the
+// start of the buffer.
+func (e *Editor) paragraphBackward(row int) (int, int) {
+	for row > 0 && e.isEmptyLine(row) {
+		row--
 	}
-
-	if endRow == row {
-		// same line deletion
-		line, _ := e.buffer.GetLine(row)
-		runes := []rune(line)
-		if endCol > len(runes) {
-			endCol = len(runes)
-		}
-		deleted := string(runes[col:endCol])
-		e.register = Register{
-			Content: deleted,
-			Type:    RegisterChar,
-		}
-
-		newLine := string(runes[:col]) + string(runes[endCol:])
-		e.undoMgr.Record(Action{
-			Type:      ActionSetLine,
-			Row:       row,
-			Text:      newLine,
-			PrevText:  line,
-			CursorRow: e.cursor.Row(),
-			CursorCol: e.cursor.Col(),
-		})
-		e.buffer.SetLine(row, newLine)
-	} else {
-		// multi-line deletion
-		e.undoMgr.BeginGroup()
-
-		lines := e.buffer.GetLines()
-		var deleted string
-
-		// capture text being deleted (rune-safe)
-		firstLine := lines[row]
-		firstRunes := []rune(firstLine)
-		deleted = string(firstRunes[col:])
-		for r := row + 1; r < endRow; r++ {
-			deleted += "\n" + lines[r]
-		}
-		if endRow < len(lines) {
-			endRunes := []rune(lines[endRow])
-			deleted += "\n" + string(endRunes[:endCol])
-		}
-		e.register = Register{Content: deleted, Type: RegisterChar}
-
-		// Merge: keep beginning of first line + end of last line
-		newLine := string(firstRunes[:col])
-		if endRow < len(lines) {
-			endRunes := []rune(lines[endRow])
-			newLine += string(endRunes[endCol:])
-		}
-
-		// Record SetLine FIRST, then DeleteLine entries (reverse order)
-		// On undo (applied in reverse): lines get re-inserted first, then first line restored
-		e.undoMgr.Record(Action{
-			Type:      ActionSetLine,
-			Row:       row,
-			Text:      newLine,
-			PrevText:  firstLine,
-			CursorRow: e.cursor.Row(),
-			CursorCol: e.cursor.Col(),
-		})
-		for r := endRow; r > row; r-- {
-			e.undoMgr.Record(Action{
-				Type:      ActionDeleteLine,
-				Row:       r,
-				Text:      lines[r],
-				CursorRow: e.cursor.Row(),
-				CursorCol: e.cursor.Col(),
-			})
-		}
-		e.undoMgr.EndGroup()
-
-		// perform deletions
-		e.buffer.SetLine(row, newLine)
-		for r := endRow; r > row; r-- {
-			e.buffer.DeleteLine(r)
-		}
+	for row > 0 && !e.isEmptyLine(row) {
+		row--
 	}
-
-	// Clamp cursor
-	e.cursor.MoveTo(e.cursor.Row(), col, e.buffer)
-	return nil
+	return row, 0
 }
 
-// deletes from cursor to end of line (rune-indexed).
-func (e *Editor) deleteToLineEnd() error {
-	row := e.cursor.Row()
-	col := e.cursor.Col()
+// returns the column of the first non-blank character of line row.
+func (e *Editor) firstNonBlank(row int) int {
 	line, _ := e.buffer.GetLine(row)
-	runes := []rune(line)
-
-	if col >= len(runes) {
-		return nil
+	col := 0
+	for _, r := range line {
+		if r != ' ' && r != '\t' {
+			break
+		}
+		col++
 	}
-
-	deleted := string(runes[col:])
-	e.register = Register{Content: deleted, Type: RegisterChar}
-
-	newLine := string(runes[:col])
-	e.undoMgr.Record(Action{
-		Type:      ActionSetLine,
-		Row:       row,
-		Text:      newLine,
-		PrevText:  line,
-		CursorRow: row,
-		CursorCol: col,
-	})
-	e.buffer.SetLine(row, newLine)
-
-	// Clamp cursor
-	e.cursor.MoveTo(row, col, e.buffer)
-	return nil
+	return col
 }
 
 // returns true if the rune is a word character (alphanumeric or _).
@@ -0,0 +1,180 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operators (d, c, y, >, <, =, g~, gu, gU) act on the text a motion moves
+// over, or on whole lines when the operator key is repeated (dd, cc, >>).
+// Both the operator and the motion take a count: 2d3w deletes six words.
+// A pending operator is kept in e.pending as the key that started it;
+// g~, gu and gU are stored as ~, u and U.
+
+// starts operator op; a count typed so far belongs to it.
+func (e *Editor) startOperator(op rune) {
+	e.pending.OpCount = e.pending.Count
+	e.pending.Count, e.pending.HasCount = 0, false
+	e.pending.Operator = op
+}
+
+// handles a key typed while an operator is pending.
+func (e *Editor) operatorKey(ch rune) error {
+	switch ch {
+	case e.pending.Operator:
+		e.operateLines()
+	case 'g', 'f', 't', 'F', 'T', 'i', 'a', '\'', '`':
+		e.pending.Prefix = ch // i and a start a text object
+	case '/':
+		e.enterSearchMode(SearchForward) // the operator applies when Enter is pressed
+	case '?':
+		e.enterSearchMode(SearchBackward)
+	default:
+		e.operatorMotion(string(ch), 0)
+	}
+	return nil
+}
+
+// applies the pending operator to count lines from the cursor line (dd,
+// cc, yy, >>, g~~, ...).
+func (e *Editor) operateLines() {
+	op, count := e.pending.Operator, max(e.pending.TotalCount(), 1)
+	e.pending.Reset()
+	row := e.cursor.Row()
+	last := min(row+count, e.buffer.NumLines()) - 1
+	e.applyOperator(op, region{kind: regionLine, startRow: row, endRow: last})
+}
+
+// applies the pending operator to the text motion m moves over.
+func (e *Editor) operatorMotion(m string, arg rune) {
+	op, count := e.pending.Operator, e.pending.TotalCount()
+	e.pending.Reset()
+	row, col := e.cursor.Row(), e.cursor.Col()
+
+	if op == 'c' && (m == "w" || m == "W") && charClass(e.iterAt(row, col).char(), false) != 0 {
+		// cw changes to the end of the word like ce, but only up to the
+		// cursor when it is on the last character of a word
+		bigWord := m == "W"
+		r, c := e.currentWordEnd(row, col, bigWord)
+		for i := 1; i < count; i++ {
+			r, c = e.wordEnd(r, c, bigWord)
+		}
+		e.cursor.MoveTo(r, c, e.buffer)
+		e.operateTo(op, row, col, motionInclusive)
+		return
+	}
+
+	kind, ok := e.motion(m, arg, count, true)
+	if !ok {
+		e.cursor.MoveTo(row, col, e.buffer)
+		e.commandFailed()
+		return
+	}
+	e.operateTo(op, row, col, kind)
+}
+
+// applies op to the text between (row, col) and the cursor, which a
+// motion of the given kind has moved there.
+func (e *Editor) operateTo(op rune, row, col int, kind motionKind) {
+	r := region{startRow: row, startCol: col, endRow: e.cursor.Row(), endCol: e.cursor.Col()}
+	if r.endRow < r.startRow || (r.endRow == r.startRow && r.endCol < r.startCol) {
+		r.startRow, r.startCol, r.endRow, r.endCol = r.endRow, r.endCol, r.startRow, r.startCol
+	}
+
+	switch kind {
+	case motionLinewise:
+		r.kind = regionLine
+	case motionInclusive:
+		r.endCol++
+	default:
+		// an exclusive motion ending at the start of a line stops at the
+		// end of the line before; from the indent on it takes whole lines
+		if r.endCol == 0 && r.endRow > r.startRow {
+			r.endRow--
+			r.endCol, _ = e.buffer.LineLength(r.endRow)
+			if r.startCol <= e.firstNonBlank(r.startRow) {
+				r.kind = regionLine
+			}
+		}
+	}
+
+	e.cursor.MoveTo(row, col, e.buffer)
+	if e.emptyRegion(r) && op != 'c' {
+		return // nothing to operate on
+	}
+	e.applyOperator(op, r)
+}
+
+// applies op to r and puts the cursor at the start of the text, or on the
+// first non-blank of the first line for the line-based operators.
+func (e *Editor) applyOperator(op rune, r region) {
+	switch op {
+	case 'd':
+		e.undoMgr.BeginGroup()
+		e.deleteRegion(r)
+		e.undoMgr.EndGroup()
+		if r.kind == regionLine {
+			e.moveToFirstNonBlank()
+		} else {
+			e.clampToLastChar()
+		}
+	case 'c':
+		e.changeRegion(r)
+	case 'y':
+		e.yankRegion(r)
+		if r.kind != regionLine {
+			e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+			e.setMessage("yanked")
+			break
+		}
+		e.cursor.MoveTo(r.startRow, e.cursor.Col(), e.buffer)
+		e.reportYank(r.endRow - r.startRow + 1)
+	case '>', '<', '=':
+		e.undoMgr.BeginGroup()
+		switch op {
+		case '>':
+			e.shiftLines(r.startRow, r.endRow, 1)
+		case '<':
+			e.shiftLines(r.startRow, r.endRow, -1)
+		default:
+			e.reindentLines(r.startRow, r.endRow)
+		}
+		e.undoMgr.EndGroup()
+		e.cursor.MoveTo(r.startRow, 0, e.buffer)
+		e.moveToFirstNonBlank()
+	case '~', 'u', 'U':
+		fn := toggleCase
+		if op == 'u' {
+			fn = strings.ToLower
+		} else if op == 'U' {
+			fn = strings.ToUpper
+		}
+		e.undoMgr.BeginGroup()
+		e.mapRegion(r, fn)
+		e.undoMgr.EndGroup()
+		e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+	}
+}
+
+// tells how many lines were yanked.
+func (e *Editor) reportYank(lines int) {
+	if lines == 1 {
+		e.setMessage("1 line yanked")
+	} else {
+		e.setMessage(fmt.Sprintf("%d lines yanked", lines))
+	}
+}
+
+// deletes r and starts insert mode in its place (c); lines are replaced
+// by one empty line. The undo group stays open until insert mode ends.
+func (e *Editor) changeRegion(r region) {
+	e.undoMgr.BeginGroup()
+	if r.kind == regionLine {
+		e.saveDeleted(r)
+		e.replaceLines(r.startRow, r.endRow, []string{""})
+		e.cursor.MoveTo(r.startRow, 0, e.buffer)
+	} else {
+		e.deleteRegion(r)
+	}
+	e.setMode(ModeInsert)
+}
@@ -0,0 +1,374 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// options, set with :set: every option is a boolean, a number or a string.
+// Global options are variables of the editor. Buffer-local and
+// window-local options have a value in every buffer or window and a
+// global value new buffers and windows start with: :set changes both,
+// :setlocal only the local one. A new window starts with the options of
+// the window it was split from.
+
+// the options local to a buffer.
+type bufferOptions struct {
+	tabStop    int  // columns between tab stops (:set tabstop)
+	shiftWidth int  // columns per indent level, 0 for tabstop (:set shiftwidth)
+	expandTab  bool // indent with spaces instead of tabs (:set expandtab)
+}
+
+// the options local to a window.
+type windowOptions struct {
+	number         bool // show line numbers (:set number)
+	relativeNumber bool // number lines relative to the cursor (:set relativenumber)
+	wrap           bool // wrap long lines instead of scrolling sideways (:set wrap)
+}
+
+// the buffer-local options new buffers start with.
+var defaultBufferOptions = bufferOptions{
+	tabStop:    8,
+	shiftWidth: 8,
+}
+
+// the window-local options the first window starts with.
+var defaultWindowOptions = windowOptions{
+	number: true,
+}
+
+// returns the columns of one indent level.
+func (o bufferOptions) shift() int {
+	if o.shiftWidth == 0 {
+		return o.tabStop
+	}
+	return o.shiftWidth
+}
+
+// an option :set knows, with its full and its short name.
+type option struct {
+	name, short string
+
+	// where the value is kept, as a *bool, *int or *string: one of
+	global func(e *Editor) any        // a variable of the editor
+	buffer func(o *bufferOptions) any // a buffer-local option
+	window func(o *windowOptions) any // a window-local option
+
+	// options kept elsewhere (in the buffer, ...) are read with get and
+	// written with set, which takes a value of the type get returns
+	get func(e *Editor) any
+	set func(e *Editor, value any) error
+
+	min   int                      // lowest value of a number option
+	check func(value string) error // validates a string option
+	apply func(e *Editor)          // runs after the value changed
+}
+
+var optionList = []*option{
+	{
+		name: "fileformat", short: "ff",
+		get: func(e *Editor) any { return e.buffer.Format().String() },
+		set: func(e *Editor, value any) error {
+			format, err := buffer.ParseFileFormat(value.(string))
+			if err != nil {
+				return err
+			}
+			e.buffer.SetFormat(format)
+			return nil
+		},
+	},
+	{
+		name: "endofline", short: "eol",
+		get: func(e *Editor) any { return e.buffer.EndOfLine() },
+		set: func(e *Editor, value any) error { e.buffer.SetEndOfLine(value.(bool)); return nil },
+	},
+	{
+		name: "bomb",
+		get:  func(e *Editor) any { return e.buffer.BOM() },
+		set:  func(e *Editor, value any) error { e.buffer.SetBOM(value.(bool)); return nil },
+	},
+	// off by default, unlike Vim: a file without a final line ending is
+	// written back as it was read, and :set fixeol adds one
+	{name: "fixendofline", short: "fixeol", global: func(e *Editor) any { return &e.fixEOL }},
+	{name: "largefile", short: "lf", global: func(e *Editor) any { return &e.largeFileMB }},
+	{name: "backup", short: "bk", global: func(e *Editor) any { return &e.backup }},
+	{name: "backupdir", short: "bdir", global: func(e *Editor) any { return &e.backupDir }},
+	{name: "backupkeep", short: "bkeep", global: func(e *Editor) any { return &e.backupKeep }, min: 1},
+	{
+		name: "backupcopy", short: "bkc",
+		get: func(e *Editor) any { return e.writeMode.String() },
+		set: func(e *Editor, value any) error {
+			mode, err := buffer.ParseWriteMode(value.(string))
+			if err != nil {
+				return err
+			}
+			e.writeMode = mode
+			return nil
+		},
+	},
+	{
+		name: "swapfile", short: "swf",
+		global: func(e *Editor) any { return &e.swapFile },
+		apply: func(e *Editor) {
+			if !e.swapFile {
+				e.releaseSwap(e.bufferEntry)
+			} else if e.swap.path == "" && e.buffer.FilePath() != "" {
+				e.startSwap(buffer.FreeSwapPath(e.buffer.FilePath()))
+			}
+		},
+	},
+	{name: "updatecount", short: "uc", global: func(e *Editor) any { return &e.updateCount }, min: 1},
+	{name: "updatetime", short: "ut", global: func(e *Editor) any { return &e.updateTime }, min: 1},
+	{name: "autoread", short: "ar", global: func(e *Editor) any { return &e.autoread }},
+	{name: "tabstop", short: "ts", buffer: func(o *bufferOptions) any { return &o.tabStop }, min: 1},
+	{name: "shiftwidth", short: "sw", buffer: func(o *bufferOptions) any { return &o.shiftWidth }},
+	{name: "expandtab", short: "et", buffer: func(o *bufferOptions) any { return &o.expandTab }},
+	{name: "number", short: "nu", window: func(o *windowOptions) any { return &o.number }},
+	{name: "relativenumber", short: "rnu", window: func(o *windowOptions) any { return &o.relativeNumber }},
+	{name: "wrap", window: func(o *windowOptions) any { return &o.wrap }},
+	{name: "scrolloff", short: "so", global: func(e *Editor) any { return &e.scrollOff }},
+	{name: "ignorecase", short: "ic", global: func(e *Editor) any { return &e.ignoreCase }},
+	{name: "smartcase", short: "scs", global: func(e *Editor) any { return &e.smartCase }},
+	{name: "magic", global: func(e *Editor) any { return &e.magic }},
+	{
+		name: "undolevels", short: "ul",
+		global: func(e *Editor) any { return &e.undoLevels },
+		apply: func(e *Editor) {
+			for _, b := range e.buffers {
+				b.undoMgr.SetMaxSize(e.undoLevels)
+			}
+		},
+	},
+	{
+		name: "clipboard", short: "cb",
+		global: func(e *Editor) any { return &e.clipboard },
+		check:  parseClipboardOption,
+	},
+	{
+		name: "mouse",
+		get:  func(e *Editor) any { return e.mouse },
+		set:  func(e *Editor, value any) error { e.setMouse(value.(string)); return nil },
+		check: func(value string) error {
+			if strings.Trim(value, "nvica") != "" {
+				return fmt.Errorf("invalid mouse: %s", value)
+			}
+			return nil
+		},
+	},
+	{name: "mapleader", global: func(e *Editor) any { return &e.mapLeader }},
+	{name: "timeout", short: "to", global: func(e *Editor) any { return &e.timeout }},
+	{name: "timeoutlen", short: "tm", global: func(e *Editor) any { return &e.timeoutLen }},
+}
+
+// returns the option called name, by its full or short name, or nil.
+func findOption(name string) *option {
+	for _, o := range optionList {
+		if name == o.name || name != "" && name == o.short {
+			return o
+		}
+	}
+	return nil
+}
+
+// reports whether the option has a value per buffer or window.
+func (o *option) isLocal() bool {
+	return o.buffer != nil || o.window != nil
+}
+
+// returns the variable holding option o: the local value of the current
+// buffer or window, or with global the value new ones start with.
+func (e *Editor) optionVar(o *option, global bool) any {
+	switch {
+	case o.buffer != nil && global:
+		return o.buffer(&e.bufDefaults)
+	case o.buffer != nil:
+		return o.buffer(&e.bufOpts)
+	case o.window != nil && global:
+		return o.window(&e.winDefaults)
+	case o.window != nil:
+		return o.window(&e.winOpts)
+	}
+	return o.global(e)
+}
+
+// returns the current value of option o: a bool, int or string.
+func (e *Editor) optionValue(o *option) any {
+	if o.get != nil {
+		return o.get(e)
+	}
+	switch v := e.optionVar(o, false).(type) {
+	case *bool:
+		return *v
+	case *int:
+		return *v
+	case *string:
+		return *v
+	}
+	return nil
+}
+
+// sets option o to value, of the type optionValue returns: the local
+// value and, unless local is set, the global one.
+func (e *Editor) setOptionValue(o *option, value any, local bool) error {
+	switch v := value.(type) {
+	case int:
+		if v < o.min {
+			return fmt.Errorf("invalid %s: %d", o.name, v)
+		}
+	case string:
+		if o.check != nil {
+			if err := o.check(v); err != nil {
+				return err
+			}
+		}
+	}
+	if o.set != nil {
+		return o.set(e, value)
+	}
+
+	vars := []any{e.optionVar(o, false)}
+	if o.isLocal() && !local {
+		vars = append(vars, e.optionVar(o, true))
+	}
+	for _, p := range vars {
+		switch p := p.(type) {
+		case *bool:
+			*p = value.(bool)
+		case *int:
+			*p = value.(int)
+		case *string:
+			*p = value.(string)
+		}
+	}
+	if o.apply != nil {
+		o.apply(e)
+	}
+	return nil
+}
+
+// --- Commands ---
+
+// handles :set with one or more arguments, e.g. ":set fileformat=dos nofixeol".
+// with no arguments it shows the file format options of the current buffer,
+// with "all" every option. :setlocal (local) leaves the global values of
+// local options alone.
+func (e *Editor) commandSet(args []string, local bool) error {
+	if len(args) == 0 {
+		e.setMessage(fmt.Sprintf("fileformat=%s %s %s %s",
+			e.buffer.Format(),
+			boolOption("endofline", e.buffer.EndOfLine()),
+			boolOption("bomb", e.buffer.BOM()),
+			boolOption("fixendofline", e.fixEOL)))
+		return nil
+	}
+	if len(args) == 1 && args[0] == "all" {
+		e.showAllOptions(local)
+		return nil
+	}
+
+	for _, arg := range args {
+		if err := e.setOption(arg, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applies a single :set argument: "name" (a boolean on, or show a value),
+// "noname" (off), "invname" or "name!" (toggle), "name?" (show) or
+// "name=value".
+func (e *Editor) setOption(arg string, local bool) error {
+	name, value, hasValue := strings.Cut(arg, "=")
+
+	// query: ":set ff?"
+	if name, ok := strings.CutSuffix(name, "?"); ok && !hasValue {
+		return e.showOption(name)
+	}
+
+	name, toggle := strings.CutSuffix(name, "!")
+	o := findOption(name)
+	negate := false
+	if o == nil && !hasValue && !toggle {
+		if rest, ok := strings.CutPrefix(name, "no"); ok {
+			o, negate = findOption(rest), true
+		} else if rest, ok := strings.CutPrefix(name, "inv"); ok {
+			o, toggle = findOption(rest), true
+		}
+	}
+	if o == nil {
+		return fmt.Errorf("unknown option: %s", arg)
+	}
+
+	switch current := e.optionValue(o).(type) {
+	case bool:
+		if hasValue {
+			return fmt.Errorf("invalid argument: %s", arg)
+		}
+		return e.setOptionValue(o, !negate && (!toggle || !current), local)
+	case int:
+		if negate || toggle {
+			return fmt.Errorf("invalid argument: %s", arg)
+		}
+		if !hasValue {
+			return e.showOption(name)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %s", o.name, value)
+		}
+		return e.setOptionValue(o, n, local)
+	default:
+		if negate || toggle {
+			return fmt.Errorf("invalid argument: %s", arg)
+		}
+		if !hasValue {
+			return e.showOption(name)
+		}
+		return e.setOptionValue(o, value, local)
+	}
+}
+
+// shows the current value of an option in the message bar.
+func (e *Editor) showOption(name string) error {
+	o := findOption(name)
+	if o == nil {
+		return fmt.Errorf("unknown option: %s", name)
+	}
+	e.setMessage(e.formatOption(o))
+	return nil
+}
+
+// shows every option with its value; with local only the buffer-local
+// and window-local ones.
+func (e *Editor) showAllOptions(local bool) {
+	var lines []string
+	for _, o := range optionList {
+		if !local || o.isLocal() {
+			lines = append(lines, "  "+e.formatOption(o))
+		}
+	}
+	sort.Strings(lines)
+	e.showOutput(append([]string{"--- Options ---"}, lines...))
+}
+
+// formats an option the way :set displays it ("eol", "noeol", "ts=8").
+func (e *Editor) formatOption(o *option) string {
+	switch v := e.optionValue(o).(type) {
+	case bool:
+		return boolOption(o.name, v)
+	default:
+		return fmt.Sprintf("%s=%v", o.name, v)
+	}
+}
+
+// formats a boolean option the way :set displays it ("eol" / "noeol").
+func boolOption(name string, on bool) string {
+	if on {
+		return name
+	}
+	return "no" + name
+}
@@ -0,0 +1,74 @@
+package editor
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// text pasted into the terminal arrives as one KeyPaste (bracketed paste)
+// instead of a key per character. It is inserted as it is, as one undo
+// step, without going through the insert mode keys, so nothing typed-key
+// specific (indenting, pairing) touches it.
+
+// handles a paste in any mode: inserted at the cursor in normal and insert
+// mode, added to the line being typed on the command and search lines.
+func (e *Editor) processPaste(key *terminal.Key) error {
+	text := key.Text
+	if text == "" {
+		return nil
+	}
+
+	switch e.mode {
+	case ModeInsert:
+		if e.block != nil {
+			// a blockwise insert repeats its text on ESC, undone as one
+			e.insertText(text)
+		} else {
+			e.undoMgr.EndGroup()
+			e.undoMgr.BeginGroup()
+			e.insertText(text)
+			e.undoMgr.EndGroup()
+			e.undoMgr.BeginGroup()
+		}
+		e.inserted = append(e.inserted, []rune(text)...)
+	case ModeNormal:
+		e.pending.Reset()
+		e.undoMgr.BeginGroup()
+		e.insertText(text)
+		e.undoMgr.EndGroup()
+		e.cursor.MoveLeft(e.buffer) // onto the last pasted character
+		e.clampToLastChar()
+	case ModeCommand:
+		e.commandBuf += firstLine(text)
+	case ModeSearch:
+		e.searchBuf += firstLine(text)
+		e.incrementalSearch()
+	}
+	return nil
+}
+
+// inserts text at the cursor and puts the cursor after it. The caller
+// groups the undo actions.
+func (e *Editor) insertText(text string) {
+	row := e.cursor.Row()
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+	col := clampCol(e.cursor.Col(), runes)
+
+	e.replaceLines(row, row, strings.Split(string(runes[:col])+text+string(runes[col:]), "\n"))
+
+	pasted := strings.Split(text, "\n")
+	endCol := utf8.RuneCountInString(pasted[len(pasted)-1])
+	if len(pasted) == 1 {
+		endCol += col
+	}
+	e.cursor.MoveTo(row+len(pasted)-1, endCol, e.buffer)
+}
+
+// returns the text up to the first line break.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
@@ -0,0 +1,59 @@
+package editor
+
+import "github.com/AdityaKrSingh26/Glime/internal/terminal"
+
+// passed to prompt answers when the user presses ESC.
+const keyEscape rune = 0x1b
+
+// a question shown in the message bar that waits for a single key.
+type prompt struct {
+	text   string
+	answer func(ch rune) bool // returns true once the question is settled
+}
+
+// shows question and routes the next keys to answer until it returns true.
+func (e *Editor) ask(question string, answer func(ch rune) bool) {
+	e.prompt = &prompt{text: question, answer: answer}
+	e.mode = ModePrompt
+}
+
+// handles keys while a prompt is open.
+func (e *Editor) processPromptMode(key *terminal.Key) error {
+	var ch rune
+	switch key.Type {
+	case terminal.KeyRune:
+		ch = key.Rune
+	case terminal.KeyEscape:
+		ch = keyEscape
+	case terminal.KeyEnter:
+		ch = '\r'
+	default:
+		return nil
+	}
+
+	p := e.prompt
+	if p.answer(ch) && e.prompt == p {
+		// keep whatever message the answer left behind
+		e.prompt = nil
+		if e.mode == ModePrompt {
+			e.mode = ModeNormal
+		}
+	}
+	return nil
+}
+
+// shows lines of command output (e.g. :ls) above the message bar until a
+// key is pressed; ':' goes straight on to the next command.
+func (e *Editor) showOutput(lines []string) {
+	e.output = lines
+	e.ask("Press ENTER or type command to continue", func(ch rune) bool {
+		e.output = nil
+		e.setMessage("")
+		if ch == ':' {
+			e.prevMode = ModeNormal
+			e.setMode(ModeCommand)
+			e.commandBuf = ":"
+		}
+		return true
+	})
+}
@@ -0,0 +1,342 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// regions are the stretches of text operators work on: characters between
+// two positions, whole lines or a rectangular block. Every change is made
+// by replacing a run of lines, which keeps undo recording in one place.
+
+type regionKind int
+
+const (
+	regionChar  regionKind = iota // from the start position up to (not including) the end position
+	regionLine                    // whole lines startRow..endRow
+	regionBlock                   // columns startCol..endCol-1 of rows startRow..endRow
+)
+
+type region struct {
+	kind               regionKind
+	startRow, startCol int
+	endRow, endCol     int
+}
+
+// returns the lines startRow..endRow of the buffer.
+func (e *Editor) lineRange(startRow, endRow int) []string {
+	lines := make([]string, 0, endRow-startRow+1)
+	for row := startRow; row <= endRow; row++ {
+		line, _ := e.buffer.GetLine(row)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// clamps a rune column to [0, len(runes)].
+func clampCol(col int, runes []rune) int {
+	return min(max(col, 0), len(runes))
+}
+
+// returns the text covered by r and the register type to store it as.
+func (e *Editor) regionText(r region) (string, RegisterType) {
+	lines := e.lineRange(r.startRow, r.endRow)
+
+	switch r.kind {
+	case regionLine:
+		return strings.Join(lines, "\n"), RegisterLine
+	case regionBlock:
+		parts := make([]string, len(lines))
+		for i, line := range lines {
+			runes := []rune(line)
+			parts[i] = string(runes[clampCol(r.startCol, runes):clampCol(r.endCol, runes)])
+		}
+		return strings.Join(parts, "\n"), RegisterBlock
+	}
+
+	first := []rune(lines[0])
+	if len(lines) == 1 {
+		return string(first[clampCol(r.startCol, first):clampCol(r.endCol, first)]), RegisterChar
+	}
+	last := []rune(lines[len(lines)-1])
+	text := string(first[clampCol(r.startCol, first):])
+	for _, line := range lines[1 : len(lines)-1] {
+		text += "\n" + line
+	}
+	return text + "\n" + string(last[:clampCol(r.endCol, last)]), RegisterChar
+}
+
+// copies the text of r into the registers as a yank.
+func (e *Editor) yankRegion(r region) {
+	text, typ := e.regionText(r)
+	e.storeRegister(text, typ, true)
+}
+
+// copies the text of r into the registers as a delete.
+func (e *Editor) saveDeleted(r region) {
+	text, typ := e.regionText(r)
+	e.storeRegister(text, typ, false)
+}
+
+// deletes the text of r (after saving it in the registers) and puts the
+// cursor where the text was. The caller groups the undo actions.
+func (e *Editor) deleteRegion(r region) {
+	e.saveDeleted(r)
+	lines := e.lineRange(r.startRow, r.endRow)
+
+	switch r.kind {
+	case regionLine:
+		e.replaceLines(r.startRow, r.endRow, nil)
+		e.cursor.MoveTo(r.startRow, 0, e.buffer)
+	case regionBlock:
+		for i, line := range lines {
+			runes := []rune(line)
+			lines[i] = string(runes[:clampCol(r.startCol, runes)]) + string(runes[clampCol(r.endCol, runes):])
+		}
+		e.replaceLines(r.startRow, r.endRow, lines)
+		e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+	default:
+		first := []rune(lines[0])
+		last := []rune(lines[len(lines)-1])
+		joined := string(first[:clampCol(r.startCol, first)]) + string(last[clampCol(r.endCol, last):])
+		e.replaceLines(r.startRow, r.endRow, []string{joined})
+		e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+	}
+}
+
+// applies fn to every stretch of text in r, line by line, and replaces the
+// lines with the result (case changes).
+func (e *Editor) mapRegion(r region, fn func(string) string) {
+	lines := e.lineRange(r.startRow, r.endRow)
+	for i, line := range lines {
+		runes := []rune(line)
+		start, end := 0, len(runes)
+		switch {
+		case r.kind == regionBlock:
+			start, end = clampCol(r.startCol, runes), clampCol(r.endCol, runes)
+		case r.kind == regionChar:
+			if i == 0 {
+				start = clampCol(r.startCol, runes)
+			}
+			if i == len(lines)-1 {
+				end = clampCol(r.endCol, runes)
+			}
+		}
+		if start < end {
+			lines[i] = string(runes[:start]) + fn(string(runes[start:end])) + string(runes[end:])
+		}
+	}
+	e.replaceLines(r.startRow, r.endRow, lines)
+}
+
+// switches the case of every letter in s (~).
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// shifts lines startRow..endRow right (levels > 0) or left by levels
+// shiftwidths (> and <). Empty lines are not indented.
+func (e *Editor) shiftLines(startRow, endRow, levels int) {
+	lines := e.lineRange(startRow, endRow)
+	for i, line := range lines {
+		if levels > 0 {
+			if line != "" {
+				lines[i] = strings.Repeat(e.indentUnit(), levels) + line
+			}
+			continue
+		}
+		for n := 0; n < -levels; n++ {
+			line = e.unindent(line)
+		}
+		lines[i] = line
+	}
+	e.replaceLines(startRow, endRow, lines)
+}
+
+// returns one level of indentation: a tab, or shiftwidth spaces with expandtab.
+func (e *Editor) indentUnit() string {
+	if e.bufOpts.expandTab {
+		return strings.Repeat(" ", e.bufOpts.shift())
+	}
+	return "\t"
+}
+
+// returns what Tab inserts at the cursor: a tab, or with expandtab the
+// spaces up to the next tab stop.
+func (e *Editor) tabText() string {
+	if !e.bufOpts.expandTab {
+		return "\t"
+	}
+	line, _ := e.buffer.GetLine(e.cursor.Row())
+	col := ui.DisplayColumn(line, e.cursor.Col(), e.bufOpts.tabStop)
+	return strings.Repeat(" ", e.bufOpts.tabStop-col%e.bufOpts.tabStop)
+}
+
+// removes one level of indentation from the start of line.
+func (e *Editor) unindent(line string) string {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:]
+	}
+	n := 0
+	for n < len(line) && n < e.bufOpts.shift() && line[n] == ' ' {
+		n++
+	}
+	if n < len(line) && n < e.bufOpts.shift() && line[n] == '\t' {
+		n++ // spaces followed by a tab make up one level too
+	}
+	return line[n:]
+}
+
+// re-indents lines startRow..endRow by bracket nesting (=): every bracket
+// left open adds a level, counted on from the line above the range.
+// Brackets inside quotes don't count; empty lines lose their white space.
+func (e *Editor) reindentLines(startRow, endRow int) {
+	depth := 0
+	for row := startRow - 1; row >= 0; row-- {
+		line, _ := e.buffer.GetLine(row)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		opened, closed, leading := bracketBalance(line)
+		depth = e.indentLevel(line) + leading + opened - closed
+		break
+	}
+
+	lines := e.lineRange(startRow, endRow)
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		if text == "" {
+			lines[i] = ""
+			continue
+		}
+		opened, closed, leading := bracketBalance(text)
+		lines[i] = strings.Repeat(e.indentUnit(), max(depth-leading, 0)) + text
+		depth = max(depth+opened-closed, 0)
+	}
+	e.replaceLines(startRow, endRow, lines)
+}
+
+// returns the indent levels at the start of line: tabs, or shiftwidth spaces.
+func (e *Editor) indentLevel(line string) int {
+	level, spaces := 0, 0
+	for _, r := range line {
+		switch r {
+		case '\t':
+			level++
+			spaces = 0
+		case ' ':
+			if spaces++; spaces == e.bufOpts.shift() {
+				level++
+				spaces = 0
+			}
+		default:
+			return level
+		}
+	}
+	return level
+}
+
+// counts the brackets line opens and closes, outside quotes, and how many
+// of the closing ones come before any other text.
+func bracketBalance(line string) (opened, closed, leading int) {
+	var quote rune
+	atStart := true
+	escaped := false
+	for _, r := range strings.TrimLeft(line, " \t") {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote != '`' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '(' || r == '[' || r == '{':
+			opened++
+		case r == ')' || r == ']' || r == '}':
+			closed++
+			if atStart {
+				leading++
+			}
+			continue
+		}
+		atStart = false
+	}
+	return opened, closed, leading
+}
+
+// joins lines startRow..endRow into one (J). Leading white space of the
+// joined lines is dropped and a single space put in between, except before
+// a ')' or after a line that already ends in white space.
+func (e *Editor) joinLineRange(startRow, endRow int) {
+	if endRow <= startRow {
+		endRow = startRow + 1
+	}
+	if endRow >= e.buffer.NumLines() {
+		return
+	}
+
+	lines := e.lineRange(startRow, endRow)
+	joined := lines[0]
+	col := 0
+	for _, line := range lines[1:] {
+		line = strings.TrimLeft(line, " \t")
+		col = utf8.RuneCountInString(joined)
+		if line != "" && joined != "" && !strings.HasSuffix(joined, " ") &&
+			!strings.HasSuffix(joined, "\t") && !strings.HasPrefix(line, ")") {
+			joined += " "
+		}
+		joined += line
+	}
+	e.replaceLines(startRow, endRow, []string{joined})
+	e.cursor.MoveTo(startRow, col, e.buffer)
+}
+
+// replaces lines first..last with lines, recording the undo actions in
+// the current group. lines may be shorter or longer than the range, or
+// empty to delete it; the buffer always keeps at least one line.
+func (e *Editor) replaceLines(first, last int, lines []string) {
+	old := e.lineRange(first, last)
+	cursorRow, cursorCol := e.cursor.Row(), e.cursor.Col()
+
+	if len(lines) == 0 && len(old) == e.buffer.NumLines() {
+		lines = []string{""} // deleting everything leaves one empty line
+	}
+
+	setLine := func(row int, text, prev string) {
+		if text == prev {
+			return
+		}
+		e.undoMgr.Record(Action{Type: ActionSetLine, Row: row, Text: text, PrevText: prev,
+			CursorRow: cursorRow, CursorCol: cursorCol})
+		e.buffer.SetLine(row, text)
+	}
+
+	common := min(len(old), len(lines))
+	for i := 0; i < common; i++ {
+		setLine(first+i, lines[i], old[i])
+	}
+
+	// extra new lines are inserted, surplus old lines deleted bottom-up
+	for i := common; i < len(lines); i++ {
+		e.undoMgr.Record(Action{Type: ActionInsertLine, Row: first + i, Text: lines[i],
+			CursorRow: cursorRow, CursorCol: cursorCol})
+		e.buffer.InsertLineWithContent(first+i, lines[i])
+	}
+	for i := len(old) - 1; i >= common; i-- {
+		e.undoMgr.Record(Action{Type: ActionDeleteLine, Row: first + i, Text: old[i],
+			CursorRow: cursorRow, CursorCol: cursorCol})
+		e.buffer.DeleteLine(first + i)
+	}
+}
@@ -1,17 +1,200 @@
 package editor
 
-// indicates whether the register holds lines or characters.
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// indicates whether the register holds lines, characters or a block.
 // 0 - characters
 // 1 - lines
+// 2 - a block (one piece per line, pasted as a column)
 type RegisterType int
 
 const (
 	RegisterChar RegisterType = iota
 	RegisterLine
+	RegisterBlock
 )
 
-// holds the content of the most recent yank/delete.
+// holds the content of a register.
 type Register struct {
 	Content string
 	Type    RegisterType
+	Keys    []terminal.Key // keys of a recorded macro, played back as they were typed
+}
+
+// registers follow Vim. "x before a command names the register it uses:
+//
+//	"      unnamed: the last yank or delete, whatever register it went to
+//	a-z    named; A-Z appends to the same register
+//	0      the last yank
+//	1-9    the last deletes of a line or more, newest in 1
+//	-      the last delete within a line
+//	_      black hole: writing to it keeps the other registers as they are
+//	+ *    the system clipboard and primary selection, see clipboard.go
+//	. : /  last inserted text, last command line, last search (read only)
+//	%      name of the current file (read only)
+
+// reports whether name is a register a command can be given.
+func isRegisterName(name rune) bool {
+	return name >= 'a' && name <= 'z' || name >= 'A' && name <= 'Z' ||
+		name >= '0' && name <= '9' || strings.ContainsRune(`"-_.:/%+*`, name)
+}
+
+// reports whether register name can only be read.
+func isReadOnlyRegister(name rune) bool {
+	return strings.ContainsRune(".:/%", name)
+}
+
+// names the register for the command that follows ("x).
+func (e *Editor) nameRegister(name rune) {
+	if !isRegisterName(name) {
+		e.commandFailed()
+		return
+	}
+	e.regName = name
+}
+
+// reports whether the command being typed is complete: the editor is back
+// in normal or visual mode and waits for no more keys.
+func (e *Editor) commandComplete() bool {
+	p := e.pending
+	return (e.mode == ModeNormal || e.mode.IsVisual()) && p.Operator == 0 && p.Prefix == 0 && !p.HasCount
+}
+
+// returns the content of register name; 0 reads the unnamed register.
+func (e *Editor) getRegister(name rune) Register {
+	switch name {
+	case 0:
+		name = '"'
+	case '/':
+		return Register{Content: e.search.Pattern}
+	case '%':
+		return Register{Content: e.buffer.FilePath()}
+	case '_':
+		return Register{}
+	case '+', '*':
+		return e.readClipboard(name)
+	}
+	return e.registers[unicode.ToLower(name)]
+}
+
+// puts reg into register name, or appends it for A-Z. Lines appended to
+// characters, or the other way round, make the register linewise.
+func (e *Editor) putRegister(name rune, reg Register) {
+	if e.registers == nil {
+		e.registers = make(map[rune]Register)
+	}
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+		if old, ok := e.registers[name]; ok {
+			sep := ""
+			if old.Type != RegisterChar || reg.Type != RegisterChar {
+				sep = "\n"
+				if old.Type == RegisterBlock && reg.Type == RegisterBlock {
+					reg.Type = RegisterBlock
+				} else {
+					reg.Type = RegisterLine
+				}
+			}
+			reg.Content = old.Content + sep + reg.Content
+		}
+	}
+	e.registers[name] = reg
+}
+
+// stores yanked or deleted text in the register named for the command
+// ("x), which the unnamed register then points to. Without a name a yank
+// goes to "0, a delete of a line or more to "1 (shifting the older ones
+// down to "9) and a smaller delete to "-; with :set clipboard it goes to
+// the system clipboard as well.
+func (e *Editor) storeRegister(text string, typ RegisterType, yank bool) {
+	name := e.regName
+	if name == '_' {
+		return
+	}
+	reg := Register{Content: text, Type: typ}
+
+	switch {
+	case isClipboardRegister(name):
+		e.writeClipboard(name, reg)
+	case name != 0 && name != '"' && !isReadOnlyRegister(name):
+		e.putRegister(name, reg)
+		reg = e.getRegister(name) // what was appended to, for the unnamed register
+	case yank:
+		e.putRegister('0', reg)
+	case typ != RegisterChar || strings.Contains(text, "\n"):
+		for n := '9'; n > '1'; n-- {
+			if prev, ok := e.registers[n-1]; ok {
+				e.registers[n] = prev
+			}
+		}
+		e.putRegister('1', reg)
+	default:
+		e.putRegister('-', reg)
+	}
+	e.putRegister('"', reg)
+
+	if clip := e.clipboardRegister(); name == 0 && clip != 0 {
+		e.writeClipboard(clip, reg)
+	}
+}
+
+// returns the register named for the command being run, or the unnamed
+// one (p, P), which is the system clipboard with :set clipboard.
+func (e *Editor) commandRegister() Register {
+	if clip := e.clipboardRegister(); e.regName == 0 && clip != 0 {
+		return e.getRegister(clip)
+	}
+	return e.getRegister(e.regName)
+}
+
+// lists the registers that have content (:registers); args limits the
+// listing to the registers named in it.
+func (e *Editor) commandRegisters(args string) error {
+	lines := []string{"Type Name Content"}
+	for _, name := range `"0123456789abcdefghijklmnopqrstuvwxyz-*+.:%/` {
+		if args != "" && !strings.ContainsRune(args, name) {
+			continue
+		}
+		reg := e.getRegister(name)
+		if reg.Content == "" {
+			continue
+		}
+		typ := "c"
+		switch reg.Type {
+		case RegisterLine:
+			typ = "l"
+		case RegisterBlock:
+			typ = "b"
+		}
+		text := reg.Content
+		if reg.Type == RegisterLine {
+			text += "\n"
+		}
+		lines = append(lines, fmt.Sprintf("  %s  \"%c   %s", typ, name, printableText(text)))
+	}
+	e.showOutput(lines)
+	return nil
+}
+
+// shows control characters in s the way Vim does, e.g. a line break as ^J.
+func printableText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 0x20:
+			b.WriteByte('^')
+			b.WriteRune(r + '@')
+		case r == 0x7f:
+			b.WriteString("^?")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"strconv"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+// dot repeat: the keys of every normal mode command are recorded as it is
+// typed, through the text of the insert session it may start, up to the
+// ESC ending it. A command that changed the buffer becomes the change .
+// repeats. Count keys are kept apart, so a count given to . replaces the
+// one the change was made with.
+
+// the keys of a change, without its count.
+type changeRecord struct {
+	count int // count the change was made with, 0 if none
+	keys  []terminal.Key
+
+	undo    *UndoManager // the buffer's undo history when the command started
+	changes int          // and how many actions it had recorded then
+}
+
+// records key as part of the command being typed. Recording starts with
+// a key typed in normal mode.
+func (e *Editor) recordKey(key *terminal.Key) {
+	if e.typing == nil {
+		if e.mode != ModeNormal {
+			return
+		}
+		e.typing = &changeRecord{undo: e.undoMgr, changes: e.undoMgr.Changes()}
+	}
+
+	if e.mode == ModeNormal {
+		if e.isCountKey(key) {
+			return
+		}
+		e.typing.count = e.pending.TotalCount()
+	}
+	e.typing.keys = append(e.typing.keys, *key)
+}
+
+// reports whether key adds a digit to the count of a normal mode command.
+func (e *Editor) isCountKey(key *terminal.Key) bool {
+	if key.Type != terminal.KeyRune || e.pending.Prefix != 0 || e.pending.Operator == ctrlW {
+		return false
+	}
+	return key.Rune >= '1' && key.Rune <= '9' || key.Rune == '0' && e.pending.HasCount
+}
+
+// checks after every key whether the command being recorded is complete:
+// back in normal mode with nothing pending. If it changed the buffer it
+// becomes the change to repeat.
+func (e *Editor) endRecordedKey() {
+	t := e.typing
+	if t == nil {
+		return
+	}
+
+	switch e.mode {
+	case ModeInsert, ModeSearch:
+		return // the command goes on
+	case ModeNormal:
+		p := e.pending
+		if p.Operator != 0 || p.Prefix != 0 || p.HasCount {
+			return
+		}
+		if t.undo.Changes() != t.changes {
+			e.dot = *t
+		}
+	}
+	e.typing = nil
+}
+
+// repeats the last change at the cursor (.). A count replaces the count
+// the change was made with.
+func (e *Editor) repeatChange(count int) error {
+	dot := e.dot
+	if len(dot.keys) == 0 {
+		return nil
+	}
+	if count == 0 {
+		count = dot.count
+	}
+
+	var keys []terminal.Key
+	if count > 0 {
+		for _, d := range strconv.Itoa(count) {
+			keys = append(keys, terminal.Key{Type: terminal.KeyRune, Rune: d})
+		}
+	}
+	keys = append(keys, dot.keys...)
+
+	// the replayed keys are recorded afresh, with the new count
+	e.pending.Reset()
+	e.typing = nil
+	for i := range keys {
+		if err := e.processKey(&keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
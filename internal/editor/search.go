@@ -1,10 +1,30 @@
 package editor
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
 )
 
+// Search patterns (/, ?, :s, :g and the /pat/ address) are Go regular
+// expressions. A few escapes work anywhere in them:
+//
+//	\v  what follows is a regular expression (the default, see magic)
+//	\V  what follows is literal text, up to a \v
+//	\c  ignore case, whatever ignorecase says
+//	\C  match case
+//	\< \>  the start and end of a word
+//
+// With ignorecase a pattern ignores case, unless smartcase is on too and
+// the pattern has an upper case letter. A search can end in an offset
+// that moves the cursor away from the match: /pat/+2 two lines below it,
+// /pat/e to its end and /pat/s-1 (or b-1) before its start.
+
 // indicates forward or backward search.
 // 0 - forward
 // 1 - backward
@@ -22,46 +42,157 @@ type SearchMatch struct {
 	ColEnd   int
 }
 
+// where a search puts the cursor relative to the match it finds.
+type searchOffset struct {
+	anchor rune // 0 for lines below the match, 'e' for its end, 's' for its start
+	n      int  // lines or characters to move from there
+}
+
 // holds the current search pattern and results.
 type SearchState struct {
 	Pattern      string
+	Offset       searchOffset
 	Direction    SearchDirection
 	Matches      []SearchMatch
 	CurrentIndex int
 	Active       bool
+
+	re   *regexp.Regexp // Pattern compiled
+	tick uint64         // change tick of the buffer when Matches were found
+}
+
+// how a pattern treats case.
+type patternCase int
+
+const (
+	caseOptions patternCase = iota // as ignorecase and smartcase say
+	caseIgnore
+	caseMatch
+)
+
+// compiles a search pattern (see the top of the file). cs decides on case
+// unless the pattern has a \c or \C.
+func (e *Editor) compilePattern(pattern string, cs patternCase) (*regexp.Regexp, error) {
+	var b strings.Builder
+	magic, upper := e.magic, false
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		if r == '\\' && i < len(pattern) {
+			next, nsize := utf8.DecodeRuneInString(pattern[i:])
+			i += nsize
+			switch {
+			case next == 'v' || next == 'V':
+				magic = next == 'v'
+			case next == 'c':
+				cs = caseIgnore
+			case next == 'C':
+				cs = caseMatch
+			case !magic:
+				b.WriteString(regexp.QuoteMeta(string(next)))
+			case next == '<' || next == '>':
+				b.WriteString(`\b`)
+			default:
+				b.WriteRune(r)
+				b.WriteRune(next)
+			}
+			continue
+		}
+
+		upper = upper || unicode.IsUpper(r)
+		if magic {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	expr := b.String()
+	if cs == caseIgnore || cs == caseOptions && e.ignoreCase && !(e.smartCase && upper) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
+
+// returns pattern, or for an empty one the last pattern of a search, :s
+// or :g.
+func (e *Editor) patternOrLast(pattern string) (string, error) {
+	if pattern != "" {
+		return pattern, nil
+	}
+	if e.lastPattern == "" {
+		return "", fmt.Errorf("no previous regular expression")
+	}
+	return e.lastPattern, nil
 }
 
-// finds all occurrences of the pattern in the given lines.
+// finds all matches of re in the given buffer.
 // Stores rune-based column offsets so cursor positioning works with multibyte text.
-func (s *SearchState) FindAll(lines []string) {
+func (s *SearchState) FindAll(buf *buffer.Buffer, re *regexp.Regexp) {
 	s.Matches = s.Matches[:0]
-
-	if s.Pattern == "" {
+	s.re, s.tick = re, buf.ChangeTick()
+	if re == nil {
 		return
 	}
 
-	for row, line := range lines {
-		offset := 0
-		for {
-			idx := strings.Index(line[offset:], s.Pattern)
-			if idx < 0 {
-				break
-			}
-			byteStart := offset + idx
-			byteEnd := byteStart + len(s.Pattern)
-			// Convert byte offsets to rune offsets
-			runeStart := utf8.RuneCountInString(line[:byteStart])
-			runeEnd := runeStart + utf8.RuneCountInString(s.Pattern)
+	for row := 0; row < buf.NumLines(); row++ {
+		line, _ := buf.GetLine(row)
+		prev, col := 0, 0 // rune column of byte offset prev
+		for _, m := range re.FindAllStringIndex(line, -1) {
+			col += utf8.RuneCountInString(line[prev:m[0]])
 			s.Matches = append(s.Matches, SearchMatch{
 				Row:      row,
-				ColStart: runeStart,
-				ColEnd:   runeEnd,
+				ColStart: col,
+				ColEnd:   col + utf8.RuneCountInString(line[m[0]:m[1]]),
 			})
-			offset = byteEnd
+			prev = m[0]
 		}
 	}
 }
 
+// finds the matches again when the buffer changed since they were found.
+func (s *SearchState) refresh(buf *buffer.Buffer) {
+	if s.re != nil && s.tick != buf.ChangeTick() {
+		s.FindAll(buf, s.re)
+	}
+}
+
+// returns the number of the match at or before (row, col), counting from
+// 1, or 0 before the first one; the status bar shows it as [3/17].
+func (s *SearchState) Counter(row, col int) int {
+	n := 0
+	for i, m := range s.Matches {
+		if m.Row > row || m.Row == row && m.ColStart > col {
+			break
+		}
+		n = i + 1
+	}
+	return n
+}
+
+// returns the first line after row (before it with backward) matching
+// re, wrapping around the end of the buffer; ok is false when no line
+// does (:/pat/ and :?pat? addresses).
+func (e *Editor) searchLine(re *regexp.Regexp, row int, backward bool) (int, bool) {
+	n := e.buffer.NumLines()
+	step := 1
+	if backward {
+		step = n - 1
+	}
+	for i, r := 0, row; i < n; i++ {
+		r = (r + step) % n
+		line, _ := e.buffer.GetLine(r)
+		if re.MatchString(line) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
 // finds the next match after (row, col) in the given direction, wraps around the file
 func (s *SearchState) NextMatch(row, col int) int {
 	if len(s.Matches) == 0 {
@@ -114,3 +245,279 @@ func (s *SearchState) PrevMatch(row, col int) int {
 	return 0
 }
 
+// --- Offsets ---
+
+// parses the offset after a search pattern: [+-]N for lines, e[+-N],
+// s[+-N] or b[+-N] for characters. A sign alone stands for 1.
+func parseSearchOffset(s string) (searchOffset, error) {
+	var o searchOffset
+	text := s
+	if s != "" {
+		switch s[0] {
+		case 'e':
+			o.anchor, s = 'e', s[1:]
+		case 's', 'b':
+			o.anchor, s = 's', s[1:]
+		}
+	}
+	if s == "" {
+		return o, nil
+	}
+
+	sign := 1
+	switch s[0] {
+	case '+':
+		s = s[1:]
+	case '-':
+		sign, s = -1, s[1:]
+	}
+	if s == "" {
+		o.n = sign
+		return o, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return o, fmt.Errorf("invalid search offset: %s", text)
+	}
+	o.n = sign * n
+	return o, nil
+}
+
+// returns the offset as typed after the pattern, "" for none.
+func (o searchOffset) String() string {
+	switch {
+	case o.anchor != 0 && o.n == 0:
+		return string(o.anchor)
+	case o.anchor != 0:
+		return fmt.Sprintf("%c%+d", o.anchor, o.n)
+	case o.n != 0:
+		return fmt.Sprintf("%+d", o.n)
+	}
+	return ""
+}
+
+// returns the kind of motion a search with the offset is: inclusive up
+// to the end of the match, linewise with a line offset.
+func (o searchOffset) kind() motionKind {
+	switch {
+	case o.anchor == 'e':
+		return motionInclusive
+	case o.anchor == 0 && o.n != 0:
+		return motionLinewise
+	}
+	return motionExclusive
+}
+
+// returns where the search offset puts the cursor for match m.
+func (e *Editor) offsetPosition(m SearchMatch) (row, col int) {
+	o := e.search.Offset
+	switch o.anchor {
+	case 'e':
+		return e.moveChars(m.Row, max(m.ColEnd-1, m.ColStart), o.n)
+	case 's':
+		return e.moveChars(m.Row, m.ColStart, o.n)
+	}
+	if o.n == 0 {
+		return m.Row, m.ColStart
+	}
+	return min(max(m.Row+o.n, 0), e.buffer.NumLines()-1), 0
+}
+
+// moves n characters on from row, col (back for a negative n), carrying
+// into the next or previous line as Vim does and stopping at either end
+// of the buffer.
+func (e *Editor) moveChars(row, col, n int) (int, int) {
+	for ; n > 0; n-- {
+		if length, _ := e.buffer.LineLength(row); col+1 < length {
+			col++
+		} else if row+1 < e.buffer.NumLines() {
+			row, col = row+1, 0
+		} else {
+			break
+		}
+	}
+	for ; n < 0; n++ {
+		if col > 0 {
+			col--
+		} else if row > 0 {
+			row--
+			length, _ := e.buffer.LineLength(row)
+			col = max(length-1, 0)
+		} else {
+			break
+		}
+	}
+	return row, col
+}
+
+// --- Searching ---
+
+// searches for input, typed after / or ?: a pattern, optionally followed
+// by the delimiter and an offset. An empty pattern is the last one.
+// Moves to the first match in the search direction.
+func (e *Editor) startSearch(input string) error {
+	delim := '/'
+	if e.search.Direction == SearchBackward {
+		delim = '?'
+	}
+	pattern, offsetText, _ := splitPattern(input, delim)
+	pattern, err := e.patternOrLast(pattern)
+	if err != nil {
+		return err
+	}
+	offset, err := parseSearchOffset(offsetText)
+	if err != nil {
+		return err
+	}
+	re, err := e.compilePattern(pattern, caseOptions)
+	if err != nil {
+		return err
+	}
+
+	e.search.Pattern, e.search.Offset, e.search.Active = pattern, offset, true
+	e.search.CurrentIndex = -1
+	e.lastPattern = pattern
+	e.search.FindAll(e.buffer, re)
+	if len(e.search.Matches) == 0 {
+		e.search.Active = false
+		return fmt.Errorf("pattern not found: %s", pattern)
+	}
+	e.searchAgain(false)
+	return nil
+}
+
+// highlights matches of the pattern typed so far, ignoring an offset and
+// a pattern that doesn't compile (yet). Large files only search on Enter.
+func (e *Editor) incrementalSearch() {
+	if e.isLargeFile() {
+		return
+	}
+	delim := '/'
+	if e.search.Direction == SearchBackward {
+		delim = '?'
+	}
+	pattern, _, _ := splitPattern(e.searchBuf, delim)
+	var re *regexp.Regexp
+	if pattern != "" {
+		re, _ = e.compilePattern(pattern, caseOptions)
+	}
+	e.search.FindAll(e.buffer, re)
+}
+
+// moves to the next match in the search direction (n), or with reverse
+// in the other one (N), and applies the search offset. Reports false when
+// there is nothing to go to.
+func (e *Editor) searchAgain(reverse bool) bool {
+	s := &e.search
+	if !s.Active || s.re == nil {
+		e.setMessage("No search pattern")
+		return false
+	}
+	s.refresh(e.buffer)
+	if len(s.Matches) == 0 {
+		e.setMessage(fmt.Sprintf("Pattern not found: %s", s.Pattern))
+		return false
+	}
+
+	// search from the match the cursor was put at by the offset, so the
+	// offset doesn't find the same match again
+	row, col := e.cursor.Row(), e.cursor.Col()
+	if i := s.CurrentIndex; i >= 0 && i < len(s.Matches) {
+		if r, c := e.offsetPosition(s.Matches[i]); r == row && c == col {
+			row, col = s.Matches[i].Row, s.Matches[i].ColStart
+		}
+	}
+
+	var idx int
+	if reverse {
+		idx = s.PrevMatch(row, col)
+	} else {
+		idx = s.NextMatch(row, col)
+	}
+	m := s.Matches[idx]
+	backward := s.Direction == SearchBackward != reverse
+	wrapped := backward && (m.Row > row || m.Row == row && m.ColStart >= col) ||
+		!backward && (m.Row < row || m.Row == row && m.ColStart <= col)
+
+	s.CurrentIndex = idx
+	r, c := e.offsetPosition(m)
+	e.cursor.MoveTo(r, c, e.buffer)
+
+	dir := "/"
+	if s.Direction == SearchBackward {
+		dir = "?"
+	}
+	msg := dir + s.Pattern
+	if o := s.Offset.String(); o != "" {
+		msg += dir + o
+	}
+	switch {
+	case wrapped && backward:
+		msg = "search hit TOP, continuing at BOTTOM"
+	case wrapped:
+		msg = "search hit BOTTOM, continuing at TOP"
+	}
+	e.setMessage(msg)
+	return true
+}
+
+// searches for the word under the cursor, or the next one on the line,
+// count times in direction dir: as a whole word (*, #) or also inside
+// other words (g*, g#). Case is ignored with ignorecase, whatever
+// smartcase says.
+func (e *Editor) searchWord(dir SearchDirection, whole bool, count int) bool {
+	row := e.cursor.Row()
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+	c := e.cursor.Col()
+	for c < len(runes) && !isWordChar(runes[c]) {
+		c++
+	}
+	if c >= len(runes) {
+		e.setMessage("Error: no identifier under cursor")
+		return false
+	}
+	start, end := c, c
+	for start > 0 && isWordChar(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isWordChar(runes[end]) {
+		end++
+	}
+
+	// \b only knows ASCII word characters
+	pattern := regexp.QuoteMeta(string(runes[start:end]))
+	if whole && runes[start] < utf8.RuneSelf {
+		pattern = `\<` + pattern
+	}
+	if whole && runes[end-1] < utf8.RuneSelf {
+		pattern += `\>`
+	}
+	if !e.magic {
+		pattern = `\v` + pattern
+	}
+	cs := caseMatch
+	if e.ignoreCase {
+		cs = caseIgnore
+	}
+	re, err := e.compilePattern(pattern, cs)
+	if err != nil {
+		e.setMessage(fmt.Sprintf("Error: %v", err))
+		return false
+	}
+
+	s := &e.search
+	s.Pattern, s.Offset, s.Direction, s.Active = pattern, searchOffset{}, dir, true
+	s.CurrentIndex = -1
+	e.lastPattern = pattern
+	s.FindAll(e.buffer, re)
+
+	// start from the word itself so the search skips it
+	e.cursor.MoveTo(row, start, e.buffer)
+	for i := 0; i < count; i++ {
+		if !e.searchAgain(false) {
+			return false
+		}
+	}
+	return true
+}
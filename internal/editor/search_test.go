@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+)
+
+func TestSearchErrorMessage(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(", "Error: invalid pattern"},
+		{"zzz", "Error: pattern not found: zzz"},
+		{"foo/x", "Error: invalid search offset"},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "foo bar", "baz")
+		typeKeys(t, e, runeKeys("/"+tt.input)...)
+		typeKeys(t, e, terminal.Key{Type: terminal.KeyEnter})
+		if e.mode != ModeNormal {
+			t.Errorf("/%s: mode %v, want Normal", tt.input, e.mode)
+		}
+		if !strings.HasPrefix(e.message, tt.want) {
+			t.Errorf("/%s: message %q, want %q...", tt.input, e.message, tt.want)
+		}
+	}
+}
+
+func TestSearchMessage(t *testing.T) {
+	e := newTestEditor(t, "foo bar", "baz bar")
+	typeKeys(t, e, runeKeys("/bar")...)
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyEnter})
+	if e.cursor.Row() != 0 || e.cursor.Col() != 4 {
+		t.Errorf("cursor at %d,%d, want 0,4", e.cursor.Row(), e.cursor.Col())
+	}
+	if e.message != "/bar" {
+		t.Errorf("message %q, want %q", e.message, "/bar")
+	}
+}
+
+func TestSearchOffset(t *testing.T) {
+	tests := []struct {
+		input    string
+		row, col int
+	}{
+		{"bar/e", 1, 2},
+		{"bar/e-1", 1, 1},
+		{"bar/s+2", 1, 2},
+		{"bar/+1", 2, 0},
+
+		// a character offset carries over the end of the line
+		{"bar/e+1", 2, 0},
+		{"bar/e+2", 3, 0},
+		{"bar/s-1", 0, 2},
+		{"az bar/e+1", 3, 6},
+		{"foo/s-1", 0, 0},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "foo", "bar", "", "baz bar")
+		typeKeys(t, e, runeKeys("/"+tt.input)...)
+		typeKeys(t, e, terminal.Key{Type: terminal.KeyEnter})
+		if e.cursor.Row() != tt.row || e.cursor.Col() != tt.col {
+			t.Errorf("/%s: cursor at %d,%d, want %d,%d", tt.input, e.cursor.Row(), e.cursor.Col(), tt.row, tt.col)
+		}
+	}
+
+	// the last character of the buffer is as far as it goes
+	e := newTestEditor(t, "bar")
+	typeKeys(t, e, runeKeys("/bar/e+1")...)
+	typeKeys(t, e, terminal.Key{Type: terminal.KeyEnter})
+	if e.cursor.Col() != 2 {
+		t.Errorf("/bar/e+1 on %q: cursor at col %d, want 2", "bar", e.cursor.Col())
+	}
+}
@@ -0,0 +1,384 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// :[range]s/pattern/replacement/[flags] [count] replaces the matches of a
+// Go regular expression on the lines of the range. Any character but a
+// letter, a digit, a blank, \, " and | can stand in for the /. In the
+// replacement:
+//
+//	&, \0        the whole match       \1 ... \9   a capture group
+//	$1, ${name}  a (named) group       $$          a $
+//	\u, \l       the next character in upper / lower case
+//	\U, \L       the characters up to \E (or \e) in upper / lower case
+//	\r, \n       a line break          \t          a tab
+//	\&, \\       a & or a \
+//
+// Flags: g replaces every match on a line instead of the first, c asks
+// before each one, i and I ignore case or don't (whatever ignorecase and
+// smartcase say), n only counts the matches and e gives no error when
+// there are none. A & before the flags keeps those of the last :s.
+// Without a pattern (":s", ":s g") the last substitution is repeated; an
+// empty pattern (":s//x/") is the last one searched for.
+
+// the pattern, replacement and flags of a :s.
+type substitute struct {
+	pattern    string // as typed
+	rep        string
+	global     bool        // g
+	confirm    bool        // c
+	ignoreCase patternCase // i: caseIgnore, I: caseMatch
+	countOnly  bool        // n
+	noError    bool        // e
+}
+
+// a substitution working through its range. With confirm it stops at
+// every match until the answer comes.
+type substitution struct {
+	substitute
+	re *regexp.Regexp
+
+	row, last int     // the line being worked on and the last line of the range
+	loaded    bool    // the matches of row are loaded
+	orig      string  // the text of row before the substitution
+	matches   [][]int // matches in orig still to go
+	done      string  // orig up to prev, with its replacements made
+	prev      int     // offset in orig after the last match handled
+	span      int     // lines row takes up now, line breaks put in included
+	hit       bool    // row got a substitution
+
+	seen         int // matches come across
+	count, lines int // substitutions made and the lines they are on
+	lastRow      int // the last line a substitution was made on
+}
+
+// replaces the matches of a pattern on the lines
+// (:[range]s/pat/rep/[flags] [count]).
+func (e *Editor) commandSubstitute(c *exCommand) error {
+	sub, rest, err := e.parseSubstitute(c.args)
+	if err != nil {
+		return err
+	}
+	count, err := parseCount(rest)
+	if err != nil {
+		return err
+	}
+	if sub.confirm && e.global != nil {
+		return fmt.Errorf("cannot ask for confirmation under :global")
+	}
+	e.applyCount(c, count)
+
+	re, err := e.compilePattern(sub.pattern, sub.ignoreCase)
+	if err != nil {
+		return err
+	}
+	e.lastSub, e.lastPattern = &sub, sub.pattern
+
+	s := &substitution{substitute: sub, re: re, row: c.first, last: c.last, lastRow: -1}
+	e.undoMgr.BeginGroup()
+	if s.confirm && !s.countOnly && e.nextSubstituteMatch(s) {
+		e.confirmSubstitute(s)
+		return nil
+	}
+	for e.nextSubstituteMatch(s) {
+		e.substituteMatch(s, true)
+	}
+	return e.finishSubstitute(s)
+}
+
+// parses the arguments of :s into the substitution and what follows its
+// flags.
+func (e *Editor) parseSubstitute(args string) (sub substitute, rest string, err error) {
+	delim, size := utf8.DecodeRuneInString(args)
+	if size == 0 || unicode.IsLetter(delim) || unicode.IsDigit(delim) || delim == '&' {
+		// no pattern: repeat the last substitution with the flags given
+		if e.lastSub == nil {
+			return sub, "", fmt.Errorf("no previous substitute")
+		}
+		sub = *e.lastSub
+		sub.global, sub.confirm, sub.ignoreCase, sub.countOnly, sub.noError = false, false, caseOptions, false, false
+		rest = args
+	} else {
+		if !isPatternDelimiter(delim) {
+			return sub, "", fmt.Errorf("invalid delimiter: %c", delim)
+		}
+		var closed bool
+		sub.pattern, rest, closed = splitPattern(args[size:], delim)
+		if closed {
+			sub.rep, rest, _ = splitPattern(rest, delim)
+		}
+		if sub.pattern, err = e.patternOrLast(sub.pattern); err != nil {
+			return sub, "", err
+		}
+	}
+
+	if r, ok := strings.CutPrefix(rest, "&"); ok {
+		if e.lastSub == nil {
+			return sub, "", fmt.Errorf("no previous substitute")
+		}
+		last := e.lastSub
+		sub.global, sub.confirm, sub.ignoreCase, sub.countOnly, sub.noError =
+			last.global, last.confirm, last.ignoreCase, last.countOnly, last.noError
+		rest = r
+	}
+	for rest != "" {
+		switch rest[0] {
+		case 'g':
+			sub.global = true
+		case 'c':
+			sub.confirm = true
+		case 'i':
+			sub.ignoreCase = caseIgnore
+		case 'I':
+			sub.ignoreCase = caseMatch
+		case 'n':
+			sub.countOnly = true
+		case 'e':
+			sub.noError = true
+		default:
+			return sub, strings.TrimLeft(rest, " \t"), nil
+		}
+		rest = rest[1:]
+	}
+	return sub, "", nil
+}
+
+// moves on to the next match of s, loading the following lines of the
+// range as the current one runs out. Returns false at the end of the
+// range.
+func (e *Editor) nextSubstituteMatch(s *substitution) bool {
+	for len(s.matches) == 0 {
+		if s.loaded {
+			s.row += s.span
+		}
+		if s.row > s.last || s.row >= e.buffer.NumLines() {
+			return false
+		}
+		n := 1
+		if s.global {
+			n = -1
+		}
+		s.orig, _ = e.buffer.GetLine(s.row)
+		s.matches = s.re.FindAllStringSubmatchIndex(s.orig, n)
+		s.done, s.prev, s.span, s.loaded, s.hit = "", 0, 1, true, false
+	}
+	return true
+}
+
+// replaces the current match of s, or with replace false leaves it as it
+// is, and puts the line as it now stands into the buffer.
+func (e *Editor) substituteMatch(s *substitution, replace bool) {
+	m := s.matches[0]
+	s.matches = s.matches[1:]
+	s.seen++
+	text := s.orig[m[0]:m[1]]
+	if replace {
+		text = expandReplacement(s.rep, s.re, s.orig, m)
+		s.count++
+		if !s.hit {
+			s.hit = true
+			s.lines++
+		}
+	}
+	s.done += s.orig[s.prev:m[0]] + text
+	s.prev = m[1]
+	if !replace || s.countOnly {
+		return
+	}
+
+	lines := strings.Split(s.done+s.orig[s.prev:], "\n")
+	e.replaceLines(s.row, s.row+s.span-1, lines)
+	s.last += len(lines) - s.span
+	s.span = len(lines)
+	s.lastRow = s.row + s.span - 1
+}
+
+// asks about the next match of s, highlighting it, until the range or
+// the patience of the user runs out.
+func (e *Editor) confirmSubstitute(s *substitution) {
+	if !e.nextSubstituteMatch(s) {
+		e.reportSubstitute(s)
+		return
+	}
+
+	// the match is in the line as replaced so far, past any line breaks
+	m := s.matches[0]
+	before := s.done + s.orig[s.prev:m[0]]
+	row := s.row + strings.Count(before, "\n")
+	col := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:])
+	end := col + max(utf8.RuneCountInString(s.orig[m[0]:m[1]]), 1)
+	e.subMatch = &SearchMatch{Row: row, ColStart: col, ColEnd: end}
+	e.cursor.MoveTo(row, col, e.buffer)
+
+	e.ask(fmt.Sprintf("replace with %s (y/n/a/q/l)?", s.rep), func(ch rune) bool {
+		switch ch {
+		case 'y':
+			e.substituteMatch(s, true)
+		case 'n':
+			e.substituteMatch(s, false)
+		case 'a':
+			for e.nextSubstituteMatch(s) {
+				e.substituteMatch(s, true)
+			}
+		case 'l':
+			e.substituteMatch(s, true)
+			s.matches, s.last = nil, s.row
+		case 'q', keyEscape:
+			s.matches, s.last = nil, s.row
+		default:
+			return false
+		}
+		e.confirmSubstitute(s)
+		return true
+	})
+}
+
+// reports how a confirmed substitution ended.
+func (e *Editor) reportSubstitute(s *substitution) {
+	if err := e.finishSubstitute(s); err != nil {
+		e.setMessage(fmt.Sprintf("Error: %v", err))
+	}
+}
+
+// closes the undo group of s, puts the cursor on the last line changed
+// and tells how many substitutions were made.
+func (e *Editor) finishSubstitute(s *substitution) error {
+	e.subMatch = nil
+	e.undoMgr.EndGroup()
+	if g := e.global; g != nil {
+		// :g reports the total once it is done
+		g.subs += s.count
+		g.subLines += s.lines
+		if s.count > 0 && !s.countOnly {
+			e.cursor.MoveTo(s.lastRow, 0, e.buffer)
+			e.moveToFirstNonBlank()
+		}
+		return nil
+	}
+	if s.count == 0 {
+		if s.noError || s.seen > 0 {
+			return nil
+		}
+		return fmt.Errorf("pattern not found: %s", s.pattern)
+	}
+
+	what := "substitution"
+	if s.countOnly {
+		what = "match"
+	} else {
+		e.cursor.MoveTo(s.lastRow, 0, e.buffer)
+		e.moveToFirstNonBlank()
+	}
+	e.setMessage(fmt.Sprintf("%s on %s", plural(s.count, what), plural(s.lines, "line")))
+	return nil
+}
+
+// returns "1 word" or "n words".
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	if strings.HasSuffix(word, "ch") {
+		return fmt.Sprintf("%d %ses", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// returns the replacement rep for match m of re in line (see the top of
+// the file for what it can contain).
+func expandReplacement(rep string, re *regexp.Regexp, line string, m []int) string {
+	var b strings.Builder
+	var once, mode rune // \u or \l for the next character, \U or \L until \E
+	put := func(s string) {
+		for _, r := range s {
+			switch {
+			case once == 'u', once == 0 && mode == 'U':
+				r = unicode.ToUpper(r)
+			case once == 'l', once == 0 && mode == 'L':
+				r = unicode.ToLower(r)
+			}
+			once = 0
+			b.WriteRune(r)
+		}
+	}
+	group := func(i int) string {
+		if i < 0 || 2*i >= len(m) || m[2*i] < 0 {
+			return ""
+		}
+		return line[m[2*i]:m[2*i+1]]
+	}
+
+	for i := 0; i < len(rep); {
+		r, size := utf8.DecodeRuneInString(rep[i:])
+		i += size
+		switch {
+		case r == '&':
+			put(group(0))
+		case r == '$' && i < len(rep):
+			switch {
+			case rep[i] == '$':
+				put("$")
+				i++
+			case isDigit(rep[i]):
+				put(group(int(rep[i] - '0')))
+				i++
+			case rep[i] == '{':
+				name, after, ok := strings.Cut(rep[i+1:], "}")
+				if !ok {
+					put("$")
+					break
+				}
+				if n, isNum := parseGroupNumber(name); isNum {
+					put(group(n))
+				} else {
+					put(group(re.SubexpIndex(name)))
+				}
+				i = len(rep) - len(after)
+			default:
+				put("$")
+			}
+		case r == '\\' && i < len(rep):
+			next, size := utf8.DecodeRuneInString(rep[i:])
+			i += size
+			switch next {
+			case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				put(group(int(next - '0')))
+			case 'u', 'l':
+				once = next
+			case 'U', 'L':
+				mode = next
+			case 'E', 'e':
+				mode = 0
+			case 'r', 'n':
+				b.WriteByte('\n')
+			case 't':
+				put("\t")
+			default:
+				put(string(next))
+			}
+		default:
+			put(string(r))
+		}
+	}
+	return b.String()
+}
+
+// parses the number of a group written ${1}; isNum is false for a name.
+func parseGroupNumber(s string) (n int, isNum bool) {
+	if s == "" {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
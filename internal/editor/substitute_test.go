@@ -0,0 +1,127 @@
+package editor
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestExpandReplacement(t *testing.T) {
+	tests := []struct {
+		pattern, line, rep string
+		want               string
+	}{
+		{"b+", "abbc", "x", "x"},
+		{"b+", "abbc", "<&>", "<bb>"},
+		{"b+", "abbc", `<\0>`, "<bb>"},
+		{`(\w+) (\w+)`, "one two", `\2 \1`, "two one"},
+		{`(\w+) (\w+)`, "one two", "$2 $1", "two one"},
+		{`(\w+) (\w+)`, "one two", "${2}x", "twox"},
+		{`(?P<first>\w+) (\w+)`, "one two", "${first}!", "one!"},
+		{`(\w+)`, "one", "${nope}", ""},
+		{`(\w+)`, "one", "${1", "${1"},
+		{`(\w+)`, "one", `\5`, ""},
+		{`(a)|(b)`, "b", `[\1][\2]`, "[][b]"},
+		{"x", "x", "$$5", "$5"},
+		{"x", "x", "$", "$"},
+		{"x", "x", "$a", "$a"},
+		{"x", "x", `\&`, "&"},
+		{"x", "x", `\\`, `\`},
+		{"x", "x", `a\tb`, "a\tb"},
+		{"x", "x", `a\rb`, "a\nb"},
+		{"x", "x", `a\nb`, "a\nb"},
+		{"x", "x", `\q`, "q"},
+		{"x", "x", `a\`, `a\`},
+
+		// case changes
+		{`(\w+)`, "hello", `\u\1`, "Hello"},
+		{`(\w+)`, "HELLO", `\l\1`, "hELLO"},
+		{`(\w+)`, "hello", `\U\1\E!`, "HELLO!"},
+		{`(\w+) (\w+)`, "HELLO World", `\L\1\e \2`, "hello World"},
+		{`(\w+)`, "hello", `\U&-x`, "HELLO-X"},
+		{`(\w+)`, "HELLO", `\L\u\1`, "Hello"},
+		{`(\w+)`, "hello", `\U\l\1`, "hELLO"},
+		{`(\pL+)`, "émile", `\u\1`, "Émile"},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile(tt.pattern)
+		m := re.FindStringSubmatchIndex(tt.line)
+		if m == nil {
+			t.Fatalf("%q doesn't match %q", tt.pattern, tt.line)
+		}
+		if got := expandReplacement(tt.rep, re, tt.line, m); got != tt.want {
+			t.Errorf("s/%s/%s/ on %q: %q, want %q", tt.pattern, tt.rep, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubstitute(t *testing.T) {
+	tests := []struct {
+		args string
+		want substitute
+		rest string
+		err  bool
+	}{
+		{"/a/b/", substitute{pattern: "a", rep: "b"}, "", false},
+		{"/a/b", substitute{pattern: "a", rep: "b"}, "", false},
+		{"/a", substitute{pattern: "a"}, "", false},
+		{`#a/b#c\#d#g`, substitute{pattern: "a/b", rep: "c#d", global: true}, "", false},
+		{"/a/b/gcne", substitute{pattern: "a", rep: "b", global: true, confirm: true, countOnly: true, noError: true}, "", false},
+		{"/a/b/i", substitute{pattern: "a", rep: "b", ignoreCase: caseIgnore}, "", false},
+		{"/a/b/I", substitute{pattern: "a", rep: "b", ignoreCase: caseMatch}, "", false},
+		{"/a/b/g 3", substitute{pattern: "a", rep: "b", global: true}, "3", false},
+		{"/a/b/ 3", substitute{pattern: "a", rep: "b"}, "3", false},
+		{`\a\b\`, substitute{}, "", true},
+		{"|a|b|", substitute{}, "", true},
+		{"g", substitute{}, "", true}, // no previous substitute
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "")
+		sub, rest, err := e.parseSubstitute(tt.args)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v, want error %v", tt.args, err, tt.err)
+			continue
+		}
+		if !tt.err && (sub != tt.want || rest != tt.rest) {
+			t.Errorf("%q: %+v, rest %q, want %+v, %q", tt.args, sub, rest, tt.want, tt.rest)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		cmds []string
+		want []string
+	}{
+		{[]string{"s/a/x/"}, []string{"xa a", "a b"}},
+		{[]string{"s/a/x/g"}, []string{"xx x", "a b"}},
+		{[]string{"%s/a/x/"}, []string{"xa a", "x b"}},
+		{[]string{"%s/a/x/g"}, []string{"xx x", "x b"}},
+		{[]string{"%s/A/x/gi"}, []string{"xx x", "x b"}},
+		{[]string{"%s/a/x/gn"}, []string{"aa a", "a b"}},
+		{[]string{"s/ /\\r/g"}, []string{"aa", "a", "a b"}},
+		{[]string{"s/(a+)/[$1]/"}, []string{"[aa] a", "a b"}},
+		{[]string{"s/a/x/", "2s"}, []string{"xa a", "x b"}},
+		{[]string{"s/a/x/g", "2s&"}, []string{"xx x", "x b"}},
+		{[]string{"s/a/x/", "%s g"}, []string{"xx x", "x b"}},
+		{[]string{"%s/zzz/x/e"}, []string{"aa a", "a b"}},
+		{[]string{"s/a/x/ 2"}, []string{"xa a", "x b"}},
+		{[]string{"s/a /x/", "2s//y/"}, []string{"axa", "yb"}},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "aa a", "a b")
+		for _, cmd := range tt.cmds {
+			if err := e.executeCommand(cmd); err != nil {
+				t.Errorf("%q: :%s: %v", tt.cmds, cmd, err)
+			}
+		}
+		if got := e.buffer.GetLines(); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: lines %q, want %q", tt.cmds, got, tt.want)
+		}
+	}
+
+	e := newTestEditor(t, "aa a", "a b")
+	if err := e.executeCommand("%s/zzz/x/"); err == nil {
+		t.Error("a pattern without a match gave no error")
+	}
+}
@@ -0,0 +1,327 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// swap file handling: the current buffer is mirrored to a swap file after
+// updateCount keystrokes or updateTime of idling, so unsaved edits can be
+// recovered after a crash. Writes happen on a background goroutine: the
+// editor only takes a snapshot of the piece list and queues it, so typing
+// never waits for the disk.
+
+// tracks the swap file of a buffer.
+type swapState struct {
+	path string // our swap file, "" when the buffer has none
+	tick uint64 // buffer change tick at the last write
+	keys int    // keystrokes since the last write
+}
+
+// a snapshot to write (or a swap to delete) on the writer goroutine.
+type swapJob struct {
+	swapPath string
+	filePath string
+	modified bool
+	text     *buffer.Snapshot
+	remove   bool
+}
+
+// writes swap files on a background goroutine. Jobs wait in pending, one
+// per swap file: a newer job for the same file replaces the one still
+// waiting, since only the last state of a swap matters.
+type swapWriter struct {
+	mu      sync.Mutex
+	pending []swapJob
+	closed  bool
+
+	wake chan struct{} // holds a signal while pending has jobs to run
+	done chan struct{}
+}
+
+func newSwapWriter() *swapWriter {
+	w := &swapWriter{wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// runs the pending jobs each time it is woken, until closed.
+func (w *swapWriter) run() {
+	defer close(w.done)
+	for range w.wake {
+		w.mu.Lock()
+		jobs, closed := w.pending, w.closed
+		w.pending = nil
+		w.mu.Unlock()
+
+		for _, job := range jobs {
+			if job.remove {
+				buffer.RemoveSwap(job.swapPath)
+				continue
+			}
+			// a failed swap write is retried on the next trigger
+			buffer.WriteSwap(job.swapPath, job.filePath, job.modified, job.text)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// queues a job without waiting, replacing any job for the same swap file
+// that has not run yet.
+func (w *swapWriter) queue(job swapJob) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.pending = slices.DeleteFunc(w.pending, func(j swapJob) bool { return j.swapPath == job.swapPath })
+	w.pending = append(w.pending, job)
+	w.mu.Unlock()
+	w.signal()
+}
+
+// wakes the goroutine, unless a signal is already waiting for it.
+func (w *swapWriter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// waits for queued jobs to finish and stops the goroutine.
+func (w *swapWriter) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.signal()
+	<-w.done
+}
+
+// drops the queued jobs, waits for the one running to finish and stops
+// the goroutine, so nothing it does can come after a write made now.
+func (w *swapWriter) Stop() {
+	w.mu.Lock()
+	w.closed = true
+	w.pending = nil
+	w.mu.Unlock()
+	w.signal()
+	<-w.done
+}
+
+// checks for existing swap files when filePath is opened. With none (or
+// only a stale, empty one) the buffer gets its own swap right away;
+// otherwise the user is asked what to do with the one found.
+func (e *Editor) openSwap(filePath string) {
+	if !e.swapFile {
+		return
+	}
+
+	for _, swapPath := range buffer.FindSwaps(filePath) {
+		info, err := buffer.ReadSwapInfo(swapPath)
+		if err != nil {
+			continue
+		}
+		if !info.Modified && !info.ProcessRunning() {
+			// left behind by a glime that had no unsaved changes
+			buffer.RemoveSwap(swapPath)
+			continue
+		}
+		e.askSwap(info)
+		return
+	}
+
+	e.startSwap(buffer.FreeSwapPath(filePath))
+}
+
+// makes swapPath the current buffer's swap file and writes it.
+func (e *Editor) startSwap(swapPath string) {
+	e.swap = swapState{path: swapPath}
+	e.writeSwap()
+}
+
+// asks whether to recover, compare or delete a swap file found on open.
+func (e *Editor) askSwap(info *buffer.SwapInfo) {
+	running := info.ProcessRunning()
+
+	question := fmt.Sprintf("Swap %s found: [r]ecover [v]iew diff [d]elete [e]dit anyway [q]uit",
+		filepath.Base(info.Path))
+	if running {
+		question = fmt.Sprintf("File in use by glime PID %d! [r]ecover [v]iew diff [e]dit anyway [q]uit",
+			info.PID)
+	}
+
+	original := e.buffer
+	e.ask(question, func(ch rune) bool {
+		switch ch {
+		case 'r':
+			e.buffer = original
+			if err := e.recoverSwap(info.Path); err != nil {
+				e.setMessage(fmt.Sprintf("Recovery failed: %v", err))
+				return false
+			}
+			if running {
+				e.startSwap(buffer.FreeSwapPath(original.FilePath()))
+			} else {
+				e.startSwap(info.Path)
+			}
+			e.setMessage("Recovered from swap file; check the text and :w to keep it")
+		case 'v':
+			if err := e.showSwapDiff(original, info.Path); err != nil {
+				e.setMessage(fmt.Sprintf("Diff failed: %v", err))
+			}
+			return false
+		case 'd':
+			if running {
+				e.setMessage("Swap file is in use by a running glime; not deleted")
+				return false
+			}
+			e.buffer = original
+			buffer.RemoveSwap(info.Path)
+			e.startSwap(buffer.FreeSwapPath(original.FilePath()))
+			e.setMessage("Swap file deleted")
+		case 'e', keyEscape:
+			e.buffer = original
+			e.startSwap(buffer.FreeSwapPath(original.FilePath()))
+			e.setMessage("")
+		case 'q':
+			e.shouldQuit = true
+		default:
+			return false
+		}
+		e.highlighter = e.renderer.Highlighter(e.buffer.FilePath())
+		return true
+	})
+}
+
+// replaces the current buffer's text with the contents of a swap file.
+func (e *Editor) recoverSwap(swapPath string) error {
+	info, text, err := buffer.ReadSwap(swapPath)
+	if err != nil {
+		return err
+	}
+	if !info.Modified {
+		return fmt.Errorf("swap file has no unsaved changes")
+	}
+
+	e.buffer.SetText(text)
+	e.undoMgr = NewUndoManager(e.undoLevels)
+	e.cursor.MoveTo(e.cursor.Row(), e.cursor.Col(), e.buffer)
+	return nil
+}
+
+// shows the difference between the file on disk and a swap file in a
+// scratch buffer, while the swap prompt stays open.
+func (e *Editor) showSwapDiff(original *buffer.Buffer, swapPath string) error {
+	info, text, err := buffer.ReadSwap(swapPath)
+	if err != nil {
+		return err
+	}
+
+	var diff []string
+	if !info.Modified {
+		diff = []string{"swap file has no unsaved changes"}
+	} else {
+		diff = unifiedDiff(original.GetLines(), strings.Split(string(text), "\n"),
+			original.FilePath(), swapPath)
+	}
+
+	e.buffer = buffer.NewFromLines(diff, "")
+	e.highlighter = nil
+	e.cursor.MoveTo(0, 0, e.buffer)
+	return nil
+}
+
+// writes the current buffer to its swap file in the background.
+func (e *Editor) writeSwap() {
+	if e.swap.path == "" {
+		return
+	}
+
+	job := swapJob{swapPath: e.swap.path, filePath: e.buffer.FilePath(), modified: e.buffer.IsModified()}
+	if job.modified {
+		job.text = e.buffer.Snapshot()
+	}
+	e.swapWriter.queue(job)
+
+	e.swap.tick = e.buffer.ChangeTick()
+	e.swap.keys = 0
+}
+
+// reports whether the buffer changed since the swap was last written.
+func (e *Editor) swapPending() bool {
+	return e.swap.path != "" && e.buffer.ChangeTick() != e.swap.tick
+}
+
+// counts a keystroke and writes the swap once updateCount is reached.
+func (e *Editor) swapAfterKey() {
+	if !e.swapPending() {
+		return
+	}
+	e.swap.keys++
+	if e.swap.keys >= e.updateCount {
+		e.writeSwap()
+	}
+}
+
+// deletes the swap file of b, e.g. when the buffer is closed.
+func (e *Editor) releaseSwap(b *bufferEntry) {
+	if b.swap.path == "" {
+		return
+	}
+	e.swapWriter.queue(swapJob{swapPath: b.swap.path, remove: true})
+	b.swap = swapState{}
+}
+
+// writes the swaps of all modified buffers synchronously; used when glime
+// is about to die. The writer is stopped first: an older snapshot still
+// queued must not replace these.
+func (e *Editor) emergencySwap() {
+	e.swapWriter.Stop()
+	for _, b := range e.buffers {
+		if b.swap.path == "" || !b.buffer.IsModified() {
+			continue
+		}
+		buffer.WriteSwap(b.swap.path, b.buffer.FilePath(), true, b.buffer.Snapshot())
+	}
+}
+
+// produces a single-hunk unified diff of a and b: the common leading and
+// trailing lines are trimmed and everything in between is shown as
+// removed/added. Good enough to judge what a swap file would restore.
+func unifiedDiff(a, b []string, nameA, nameB string) []string {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	removed := a[prefix : len(a)-suffix]
+	added := b[prefix : len(b)-suffix]
+	if len(removed) == 0 && len(added) == 0 {
+		return []string{"swap file matches the file on disk"}
+	}
+
+	diff := []string{
+		"--- " + nameA,
+		"+++ " + nameB,
+		fmt.Sprintf("@@ -%d,%d +%d,%d @@", prefix+1, len(removed), prefix+1, len(added)),
+	}
+	for _, l := range removed {
+		diff = append(diff, "-"+l)
+	}
+	for _, l := range added {
+		diff = append(diff, "+"+l)
+	}
+	return diff
+}
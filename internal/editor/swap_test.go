@@ -0,0 +1,65 @@
+package editor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+)
+
+// jobs queued while the writer is busy are merged, one per swap file, and
+// the last one for a file is what gets written.
+func TestSwapWriterMergesJobs(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, ".a.swp"), filepath.Join(dir, ".b.swp")
+	buf := buffer.NewFromLines([]string{""}, "")
+
+	// not started: every job stays pending
+	w := &swapWriter{wake: make(chan struct{}, 1), done: make(chan struct{})}
+	for _, c := range "abcdef" {
+		buf.InsertChar(0, 0, c)
+		w.queue(swapJob{swapPath: a, filePath: "a", modified: true, text: buf.Snapshot()})
+		w.queue(swapJob{swapPath: b, filePath: "b", modified: true, text: buf.Snapshot()})
+	}
+	w.queue(swapJob{swapPath: b, remove: true})
+	if len(w.pending) != 2 {
+		t.Fatalf("%d jobs pending, want 2", len(w.pending))
+	}
+
+	go w.run()
+	w.Close()
+
+	_, text, err := buffer.ReadSwap(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "fedcba" {
+		t.Errorf("swap holds %q, want %q", text, "fedcba")
+	}
+	if buffer.Exists(b) {
+		t.Errorf("swap %s was removed last but exists", b)
+	}
+}
+
+// the emergency swap is the last write: jobs still queued don't replace
+// or delete it.
+func TestEmergencySwapAfterQueuedJobs(t *testing.T) {
+	e := newTestEditor(t, "old")
+	e.swapWriter = newSwapWriter()
+	e.swap.path = filepath.Join(t.TempDir(), ".f.swp")
+	e.buffer.InsertChar(0, 0, 'x')
+	for range 50 {
+		e.writeSwap()
+		e.swapWriter.queue(swapJob{swapPath: e.swap.path, remove: true})
+	}
+	e.buffer.SetLine(0, "new")
+
+	e.emergencySwap()
+	_, text, err := buffer.ReadSwap(e.swap.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "new" {
+		t.Errorf("swap holds %q, want %q", text, "new")
+	}
+}
@@ -0,0 +1,203 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// tab pages: every tab holds a window layout of its own, e.g. one tab per
+// task. Editor embeds the current tab page, which embeds its current
+// window, so e.window, e.layout and e.cursor always belong to it. Buffers
+// are shared by all tabs.
+
+type tabPage struct {
+	*window                // the current window of the tab
+	layout     *layoutNode // window layout tree
+	prevWindow *window     // window used before the current one (Ctrl-w p)
+}
+
+// creates a tab page with a single window showing b, with the
+// window-local options opts.
+func newTabPage(b *bufferEntry, opts windowOptions) *tabPage {
+	w := newWindow(b, opts)
+	return &tabPage{window: w, layout: w.node}
+}
+
+// returns the windows of all tab pages.
+func (e *Editor) allWindows() []*window {
+	var list []*window
+	for _, t := range e.tabs {
+		list = append(list, t.windows()...)
+	}
+	return list
+}
+
+// returns the position of t in the tab line, or -1.
+func (e *Editor) tabIndex(t *tabPage) int {
+	for i, tab := range e.tabs {
+		if tab == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns the number of screen rows taken by the tab line, which is only
+// shown when there is more than one tab page.
+func (e *Editor) tabLineRows() int {
+	if len(e.tabs) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// makes t the current tab page.
+func (e *Editor) enterTab(t *tabPage) {
+	if t == e.tabPage {
+		return
+	}
+	if t.bufferEntry != e.bufferEntry && e.swapPending() {
+		e.writeSwap()
+	}
+	e.pending.Reset()
+	e.tabPage = t
+	e.checkDisk()
+}
+
+// moves count tabs forward (or backward when count is negative), wrapping
+// around; with a count given to gt it goes to tab count instead.
+func (e *Editor) tabNext(count int, hasCount bool) {
+	n := len(e.tabs)
+	if hasCount {
+		e.enterTab(e.tabs[min(max(count, 1), n)-1])
+		return
+	}
+	i := e.tabIndex(e.tabPage)
+	e.enterTab(e.tabs[((i+count)%n+n)%n])
+}
+
+// removes t from the tab line; the buffers it showed stay open. When t is
+// the current tab, the tab to its right (or the new last one) takes over.
+func (e *Editor) closeTab(t *tabPage) error {
+	if len(e.tabs) == 1 {
+		return fmt.Errorf("cannot close last tab page")
+	}
+
+	for _, w := range t.windows() {
+		w.bufferEntry.lastCursor = *w.cursor
+	}
+
+	i := e.tabIndex(t)
+	e.tabs = append(e.tabs[:i], e.tabs[i+1:]...)
+	if t == e.tabPage {
+		e.enterTab(e.tabs[min(i, len(e.tabs)-1)])
+	}
+	return nil
+}
+
+// returns the tab line labels, or nil when the tab line is hidden.
+func (e *Editor) tabLabels() []ui.TabLabel {
+	if e.tabLineRows() == 0 {
+		return nil
+	}
+	labels := make([]ui.TabLabel, len(e.tabs))
+	for i, t := range e.tabs {
+		labels[i] = ui.TabLabel{
+			FileName:   t.buffer.FilePath(),
+			IsModified: t.buffer.IsModified(),
+			Windows:    len(t.windows()),
+			Active:     t == e.tabPage,
+		}
+	}
+	return labels
+}
+
+// --- Tab commands ---
+
+// opens a new tab page after the current one, editing filePath or a new
+// empty buffer (:tabnew, :tabedit).
+func (e *Editor) commandTabNew(filePath string) error {
+	prev := e.tabPage
+
+	var t *tabPage
+	if filePath == "" {
+		b := e.newBufferEntry(buffer.New())
+		e.buffers = append(e.buffers, b)
+		t = newTabPage(b, e.winOpts)
+	} else {
+		t = newTabPage(e.bufferEntry, e.winOpts)
+	}
+
+	i := e.tabIndex(prev) + 1
+	e.tabs = append(e.tabs[:i], append([]*tabPage{t}, e.tabs[i:]...)...)
+	e.enterTab(t)
+
+	if filePath != "" {
+		if err := e.editFile(filePath); err != nil {
+			e.closeTab(t)
+			e.enterTab(prev)
+			return err
+		}
+	}
+	return nil
+}
+
+// closes the current tab page, or tab N (:tabclose [N]).
+func (e *Editor) commandTabClose(arg string) error {
+	t := e.tabPage
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(e.tabs) {
+			return fmt.Errorf("invalid tab page: %s", arg)
+		}
+		t = e.tabs[n-1]
+	}
+	if err := e.closeTab(t); err != nil {
+		e.setMessage(fmt.Sprintf("Close failed: %v", err))
+	}
+	return nil
+}
+
+// closes all tab pages but the current one (:tabonly).
+func (e *Editor) commandTabOnly() error {
+	for _, t := range append([]*tabPage{}, e.tabs...) {
+		if t != e.tabPage {
+			e.closeTab(t)
+		}
+	}
+	return nil
+}
+
+// moves the current tab page (:tabmove). "N" puts it after tab N (0 makes
+// it the first), "+N" and "-N" move it relative to where it is and no
+// argument (or "$") makes it the last.
+func (e *Editor) commandTabMove(arg string) error {
+	i := e.tabIndex(e.tabPage)
+	last := len(e.tabs) - 1
+
+	to := last
+	if arg != "" && arg != "$" {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid argument: %s", arg)
+		}
+		if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+			to = i + n
+		} else if n <= i {
+			to = n
+		} else {
+			to = n - 1
+		}
+	}
+	if to < 0 || to > last {
+		return fmt.Errorf("invalid argument: %s", arg)
+	}
+
+	e.tabs = append(e.tabs[:i], e.tabs[i+1:]...)
+	e.tabs = append(e.tabs[:to], append([]*tabPage{e.tabPage}, e.tabs[to:]...)...)
+	return nil
+}
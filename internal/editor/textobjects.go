@@ -0,0 +1,472 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// text objects select a piece of text around the cursor after an operator
+// (diw, ci", da() or in visual mode (vi{). "i" objects take the inside of
+// it, "a" objects include the white space, quotes, brackets or tags around.
+
+// returns the text object obj (w, W, s, p, a quote, a bracket or t) around
+// the cursor, count levels or pieces of it. Returns false when there is none.
+func (e *Editor) textObject(obj rune, inner bool, count int) (region, bool) {
+	switch obj {
+	case 'w', 'W':
+		return e.wordObject(inner, obj == 'W', count)
+	case 's':
+		return e.sentenceObject(inner, count)
+	case 'p':
+		return e.paragraphObject(inner, count)
+	case '"', '\'', '`':
+		return e.quoteObject(obj, inner, count)
+	case '(', ')', 'b':
+		return e.bracketObject('(', ')', inner, count)
+	case '[', ']':
+		return e.bracketObject('[', ']', inner, count)
+	case '{', '}', 'B':
+		return e.bracketObject('{', '}', inner, count)
+	case '<', '>':
+		return e.bracketObject('<', '>', inner, count)
+	case 't':
+		return e.tagObject(inner, count)
+	}
+	return region{}, false
+}
+
+// applies the pending operator to text object obj (diw, ci", ya(, ...).
+func (e *Editor) operatorTextObject(inner bool, obj rune) {
+	op, count := e.pending.Operator, e.pending.TotalCount()
+	e.pending.Reset()
+	r, ok := e.textObject(obj, inner, max(count, 1))
+	if !ok {
+		e.commandFailed()
+		return
+	}
+	if e.emptyRegion(r) && op != 'c' {
+		return
+	}
+	e.applyOperator(op, r)
+}
+
+// selects text object obj in visual mode, switching between v and V to
+// suit the object.
+func (e *Editor) visualTextObject(inner bool, obj rune, count int) {
+	r, ok := e.textObject(obj, inner, max(count, 1))
+	if !ok || e.emptyRegion(r) {
+		return
+	}
+
+	if r.kind == regionLine {
+		if e.mode != ModeVisualLine {
+			e.setMode(ModeVisualLine)
+		}
+		e.visual.startRow, e.visual.startCol = r.startRow, 0
+		e.cursor.MoveTo(r.endRow, 0, e.buffer)
+		return
+	}
+
+	// the selection includes the character under the cursor: step back
+	// from the exclusive end
+	endRow, endCol := r.endRow, r.endCol-1
+	if endCol < 0 {
+		endRow--
+		endCol, _ = e.buffer.LineLength(endRow)
+	}
+	if e.mode != ModeVisual {
+		e.setMode(ModeVisual)
+	}
+	e.visual.startRow, e.visual.startCol = r.startRow, r.startCol
+	e.visual.toEOL = false
+	e.cursor.MoveTo(endRow, endCol, e.buffer)
+}
+
+// reports whether the characterwise region r covers no text.
+func (e *Editor) emptyRegion(r region) bool {
+	if r.kind != regionChar || r.startRow != r.endRow {
+		return false
+	}
+	lineLen, _ := e.buffer.LineLength(r.startRow)
+	return min(r.endCol, lineLen) <= r.startCol
+}
+
+// selects count runs of the positions 0..n-1 around at. A run is a stretch
+// of positions of the same class; class 0 is white space. Inner objects
+// count the white space runs as well (iw); the others take the white
+// space after each run, or before the first one when there is none after
+// the last (aw). Returns the first and last position of the selection.
+func selectRuns(n, at, count int, inner bool, class func(int) int) (int, int) {
+	runEnd := func(i int) int {
+		c := class(i)
+		for i+1 < n && class(i+1) == c {
+			i++
+		}
+		return i
+	}
+
+	start := at
+	for start > 0 && class(start-1) == class(at) {
+		start--
+	}
+
+	if inner {
+		end := runEnd(at)
+		for i := 1; i < count && end+1 < n; i++ {
+			end = runEnd(end + 1)
+		}
+		return start, end
+	}
+
+	onSpace := class(at) == 0
+	end := start - 1
+	trailing := false
+	for i := 0; i < count && end+1 < n; i++ {
+		end = runEnd(end + 1)
+		if onSpace {
+			// white space and the run after it
+			if end+1 < n {
+				end = runEnd(end + 1)
+			}
+			continue
+		}
+		// the run and the white space after it
+		trailing = end+1 < n && class(end+1) == 0
+		if trailing {
+			end = runEnd(end + 1)
+		}
+	}
+	if !onSpace && !trailing {
+		for start > 0 && class(start-1) == 0 {
+			start--
+		}
+	}
+	return start, end
+}
+
+// returns the word (or WORD) object around the cursor on its line (iw, aw).
+func (e *Editor) wordObject(inner, bigWord bool, count int) (region, bool) {
+	row := e.cursor.Row()
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+	if len(runes) == 0 {
+		return region{}, false
+	}
+
+	col := min(e.cursor.Col(), len(runes)-1)
+	start, end := selectRuns(len(runes), col, count, inner, func(i int) int {
+		return charClass(runes[i], bigWord)
+	})
+	return region{startRow: row, startCol: start, endRow: row, endCol: end + 1}, true
+}
+
+// returns the paragraph object around the cursor line (ip, ap): lines
+// between blank ones, linewise.
+func (e *Editor) paragraphObject(inner bool, count int) (region, bool) {
+	start, end := selectRuns(e.buffer.NumLines(), e.cursor.Row(), count, inner, func(row int) int {
+		if e.isBlankLine(row) {
+			return 0
+		}
+		return 1
+	})
+	return region{kind: regionLine, startRow: start, endRow: end}, true
+}
+
+// reports whether line row is empty or white space only.
+func (e *Editor) isBlankLine(row int) bool {
+	line, _ := e.buffer.GetLine(row)
+	return strings.TrimSpace(line) == ""
+}
+
+// returns the sentence object around the cursor (is, as). A sentence ends
+// at a '.', '!' or '?' followed by white space or the line end (closing
+// brackets and quotes may come in between) and never crosses a blank line.
+func (e *Editor) sentenceObject(inner bool, count int) (region, bool) {
+	row := e.cursor.Row()
+	if e.isBlankLine(row) {
+		return region{}, false
+	}
+	first, last := row, row
+	for first > 0 && !e.isBlankLine(first-1) {
+		first--
+	}
+	for last < e.buffer.NumLines()-1 && !e.isBlankLine(last+1) {
+		last++
+	}
+
+	f := e.flatten(first, last)
+	classes := sentenceClasses(f.text)
+	// the line break after the paragraph is not part of it
+	start, end := selectRuns(len(f.text)-1, f.index(row, e.cursor.Col()), count, inner, func(i int) int {
+		return classes[i]
+	})
+	return f.region(start, end), true
+}
+
+// numbers the sentences of text: every position gets the number of its
+// sentence, or 0 for the white space between sentences.
+func sentenceClasses(text []rune) []int {
+	classes := make([]int, len(text))
+	id := 0
+	for i := 0; i < len(text); {
+		if unicode.IsSpace(text[i]) {
+			i++
+			continue
+		}
+		id++
+		j := i
+		for ; j < len(text); j++ {
+			if !strings.ContainsRune(".!?", text[j]) {
+				continue
+			}
+			k := j + 1
+			for k < len(text) && strings.ContainsRune(`)]"'`, text[k]) {
+				k++
+			}
+			if k == len(text) || unicode.IsSpace(text[k]) {
+				j = k
+				break
+			}
+		}
+		for ; i < j && i < len(text); i++ {
+			classes[i] = id
+		}
+	}
+	return classes
+}
+
+// returns the quoted string object on the cursor line (i", a', ...): the
+// string the cursor is in or on, or else the next one on the line. a"
+// takes the white space after the string (or before it); a count of 2 on
+// i" takes the quotes but no white space.
+func (e *Editor) quoteObject(q rune, inner bool, count int) (region, bool) {
+	row, col := e.cursor.Row(), e.cursor.Col()
+	line, _ := e.buffer.GetLine(row)
+	runes := []rune(line)
+
+	var quotes []int
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && q != '`' {
+			i++ // an escaped quote is part of the string
+			continue
+		}
+		if runes[i] == q {
+			quotes = append(quotes, i)
+		}
+	}
+
+	k := 0
+	for k < len(quotes) && quotes[k] < col {
+		k++
+	}
+	var open int
+	switch {
+	case k < len(quotes) && quotes[k] == col:
+		open = k - k%2 // on a quote: quotes pair up from the line start
+	case k%2 == 1:
+		open = k - 1 // inside a string
+	default:
+		open = k // before a string
+	}
+	if open+1 >= len(quotes) {
+		return region{}, false
+	}
+
+	start, end := quotes[open], quotes[open+1]+1
+	if inner {
+		if count < 2 {
+			start, end = start+1, end-1
+		}
+	} else if end < len(runes) && unicode.IsSpace(runes[end]) {
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+	} else {
+		for start > 0 && unicode.IsSpace(runes[start-1]) {
+			start--
+		}
+	}
+	return region{startRow: row, startCol: start, endRow: row, endCol: end}, true
+}
+
+// returns the bracket block around the cursor, count levels out (i(, a{,
+// 2i[, ...). i( of a block whose brackets end and start their lines
+// takes the lines in between.
+func (e *Editor) bracketObject(open, close rune, inner bool, count int) (region, bool) {
+	row, col := e.cursor.Row(), e.cursor.Col()
+
+	var openRow, openCol int
+	ok := true
+	switch e.iterAt(row, col).char() {
+	case open:
+		openRow, openCol = row, col
+	case close:
+		m := scanBackward(e.buffer, row, col, close, open)
+		if m == nil {
+			return region{}, false
+		}
+		openRow, openCol = m.Row, m.Col
+	default:
+		openRow, openCol, ok = e.enclosingOpen(row, col, open, close)
+	}
+	for i := 1; i < count && ok; i++ {
+		openRow, openCol, ok = e.enclosingOpen(openRow, openCol, open, close)
+	}
+	if !ok {
+		return region{}, false
+	}
+
+	m := scanForward(e.buffer, openRow, openCol, open, close)
+	if m == nil {
+		return region{}, false
+	}
+
+	if !inner {
+		return region{startRow: openRow, startCol: openCol, endRow: m.Row, endCol: m.Col + 1}, true
+	}
+	openLen, _ := e.buffer.LineLength(openRow)
+	if openCol == openLen-1 && m.Row > openRow+1 && e.firstNonBlank(m.Row) == m.Col {
+		return region{kind: regionLine, startRow: openRow + 1, endRow: m.Row - 1}, true
+	}
+	return region{startRow: openRow, startCol: openCol + 1, endRow: m.Row, endCol: m.Col}, true
+}
+
+// finds the unmatched open bracket before (row, col).
+func (e *Editor) enclosingOpen(row, col int, open, close rune) (int, int, bool) {
+	depth := 0
+	for it := e.iterAt(row, col); it.prev(); {
+		switch it.char() {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				return it.row, it.col, true
+			}
+			depth--
+		}
+	}
+	return 0, 0, false
+}
+
+// an HTML/XML tag found by tagObject, as positions in the flattened buffer.
+type tagPair struct {
+	openStart, openEnd   int // the start tag: from '<' to after '>'
+	closeStart, closeEnd int // the end tag
+}
+
+// returns the tag block around the cursor, count levels out (it, at).
+// Self-closing tags and unmatched end tags are skipped.
+func (e *Editor) tagObject(inner bool, count int) (region, bool) {
+	f := e.flatten(0, e.buffer.NumLines()-1)
+	at := f.index(e.cursor.Row(), e.cursor.Col())
+
+	type openTag struct {
+		name       string
+		start, end int
+	}
+	var stack []openTag
+	var around []tagPair
+	text := f.text
+	for i := 0; i < len(text); i++ {
+		if text[i] != '<' {
+			continue
+		}
+		j := i + 1
+		closing := j < len(text) && text[j] == '/'
+		if closing {
+			j++
+		}
+		nameStart := j
+		for j < len(text) && (unicode.IsLetter(text[j]) || unicode.IsDigit(text[j]) || strings.ContainsRune(":_.-", text[j])) {
+			j++
+		}
+		if j == nameStart || !unicode.IsLetter(text[nameStart]) {
+			continue
+		}
+		name := string(text[nameStart:j])
+		for j < len(text) && text[j] != '>' && text[j] != '<' {
+			j++
+		}
+		if j == len(text) || text[j] != '>' {
+			continue
+		}
+		end := j + 1
+
+		switch {
+		case text[j-1] == '/':
+			// self-closing
+		case !closing:
+			stack = append(stack, openTag{name: name, start: i, end: end})
+		default:
+			for k := len(stack) - 1; k >= 0; k-- {
+				if stack[k].name != name {
+					continue
+				}
+				p := tagPair{openStart: stack[k].start, openEnd: stack[k].end, closeStart: i, closeEnd: end}
+				if p.openStart <= at && at < p.closeEnd {
+					around = append(around, p)
+				}
+				stack = stack[:k]
+				break
+			}
+		}
+		i = j
+	}
+
+	// innermost first
+	sort.Slice(around, func(a, b int) bool { return around[a].openStart > around[b].openStart })
+	if count > len(around) {
+		return region{}, false
+	}
+	p := around[count-1]
+	if inner {
+		return f.region(p.openEnd, p.closeStart-1), true
+	}
+	return f.region(p.openStart, p.closeEnd-1), true
+}
+
+// lines of the buffer as one run of characters, with a '\n' after every
+// line, for the objects that span lines.
+type flatText struct {
+	text   []rune
+	starts []int // index of the first character of every line
+	first  int   // buffer row of the first line
+}
+
+// flattens lines first..last.
+func (e *Editor) flatten(first, last int) *flatText {
+	f := &flatText{first: first}
+	for row := first; row <= last; row++ {
+		line, _ := e.buffer.GetLine(row)
+		f.starts = append(f.starts, len(f.text))
+		f.text = append(f.text, []rune(line)...)
+		f.text = append(f.text, '\n')
+	}
+	return f
+}
+
+// returns the index of buffer position (row, col).
+func (f *flatText) index(row, col int) int {
+	i := f.starts[row-f.first] + col
+	if row-f.first+1 < len(f.starts) {
+		i = min(i, f.starts[row-f.first+1]-1)
+	}
+	return min(i, len(f.text)-1)
+}
+
+// returns the buffer position of index i; past the end it is the end of
+// the last line.
+func (f *flatText) pos(i int) (int, int) {
+	i = min(i, len(f.text)-1)
+	k := sort.Search(len(f.starts), func(k int) bool { return f.starts[k] > i }) - 1
+	return f.first + k, i - f.starts[k]
+}
+
+// returns the characterwise region of indexes start..end.
+func (f *flatText) region(start, end int) region {
+	r := region{}
+	r.startRow, r.startCol = f.pos(start)
+	r.endRow, r.endCol = f.pos(end + 1)
+	return r
+}
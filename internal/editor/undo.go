@@ -35,6 +35,8 @@ type UndoManager struct {
 	redoStack []ActionGroup
 	current   *ActionGroup
 	maxSize   int
+	changes   int // actions recorded so far
+	batch     int // open BeginBatch calls
 }
 
 func NewUndoManager(maxSize int) *UndoManager {
@@ -46,8 +48,19 @@ func NewUndoManager(maxSize int) *UndoManager {
 	}
 }
 
+// changes how many groups are kept, dropping the oldest ones beyond it.
+func (u *UndoManager) SetMaxSize(maxSize int) {
+	u.maxSize = maxSize
+	if len(u.undoStack) > maxSize {
+		u.undoStack = u.undoStack[len(u.undoStack)-maxSize:]
+	}
+}
+
 // starts a new action group for batching.
 func (u *UndoManager) BeginGroup() {
+	if u.batch > 0 {
+		return
+	}
 	u.current = &ActionGroup{
 		Actions: make([]Action, 0),
 	}
@@ -55,6 +68,9 @@ func (u *UndoManager) BeginGroup() {
 
 // finalise the current group and push it onto the undo stack
 func (u *UndoManager) EndGroup() {
+	if u.batch > 0 {
+		return
+	}
 	if u.current == nil || len(u.current.Actions) == 0 {
 		u.current = nil
 		return
@@ -67,6 +83,25 @@ func (u *UndoManager) EndGroup() {
 	u.current = nil
 }
 
+// starts a batch: until the matching EndBatch every action goes into one
+// group, whatever groups the commands run in between begin and end, so a
+// command running other commands (:normal, :g) is undone in one step.
+// Batches nest.
+func (u *UndoManager) BeginBatch() {
+	if u.batch == 0 {
+		u.EndGroup()
+		u.BeginGroup()
+	}
+	u.batch++
+}
+
+// ends a batch started with BeginBatch.
+func (u *UndoManager) EndBatch() {
+	if u.batch--; u.batch == 0 {
+		u.EndGroup()
+	}
+}
+
 // pops the top group from the undo stack.
 // returns nil if nothing to undo.
 func (u *UndoManager) Undo() *ActionGroup {
@@ -107,6 +142,7 @@ func (u *UndoManager) Record(action Action) {
 	// Any new edit destroys the redo history
 	// clear redo on new edit
 	u.redoStack = u.redoStack[:0]
+	u.changes++
 
 	if u.current != nil {
 		u.current.Actions = append(u.current.Actions, action)
@@ -121,3 +157,9 @@ func (u *UndoManager) Record(action Action) {
 		u.undoStack = u.undoStack[1:]
 	}
 }
+
+// returns how many actions have been recorded so far; comparing it before
+// and after a command tells whether the command changed the buffer.
+func (u *UndoManager) Changes() int {
+	return u.changes
+}
@@ -0,0 +1,473 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// visual mode: v selects characters, V whole lines and Ctrl-v a block,
+// from the anchor to the cursor. Motions move the cursor end and the
+// operators apply to the selection.
+
+// a visual selection: the mode it was made in, the anchor and the cursor end.
+type visualState struct {
+	mode               Mode
+	startRow, startCol int  // the anchor
+	endRow, endCol     int  // the cursor end, saved when the selection ends
+	toEOL              bool // $ in block mode: the block reaches every line's end
+}
+
+// a blockwise insert (I, A, c) waiting for ESC to copy the typed text to
+// the other lines of the block.
+type blockInsert struct {
+	row, col int    // where typing started
+	lastRow  int    // last line of the block
+	before   string // the line at row before typing
+	numLines int    // buffer lines before typing; a typed line break cancels the copy
+	pad      bool   // pad short lines with spaces (A) instead of skipping them (I)
+	toEOL    bool   // append at the end of every line (A after $)
+}
+
+// starts a visual mode with the anchor at the cursor.
+func (e *Editor) startVisual(mode Mode) {
+	e.visual = visualState{mode: mode, startRow: e.cursor.Row(), startCol: e.cursor.Col()}
+	e.setMode(mode)
+}
+
+// leaves visual mode, remembering the selection for gv and '<,'>.
+func (e *Editor) exitVisual() {
+	e.visual.mode = e.mode
+	e.visual.endRow, e.visual.endCol = e.cursor.Row(), e.cursor.Col()
+	e.lastVisual = e.visual
+	e.pending.Reset()
+	e.setMode(ModeNormal)
+}
+
+// selects the last visual selection of the buffer again (gv).
+func (e *Editor) reselectVisual() {
+	last := e.lastVisual
+	if !last.mode.IsVisual() {
+		e.setMessage("No previous visual selection")
+		return
+	}
+	e.cursor.MoveTo(last.startRow, last.startCol, e.buffer)
+	last.startRow, last.startCol = e.cursor.Row(), e.cursor.Col()
+	e.cursor.MoveTo(last.endRow, last.endCol, e.buffer)
+	e.visual = last
+	e.setMode(last.mode)
+}
+
+// returns the selected text as a region.
+func (e *Editor) visualRegion() region {
+	v := e.visual
+	r := region{startRow: v.startRow, startCol: v.startCol, endRow: e.cursor.Row(), endCol: e.cursor.Col()}
+	if r.endRow < r.startRow || (r.endRow == r.startRow && r.endCol < r.startCol) {
+		r.startRow, r.startCol, r.endRow, r.endCol = r.endRow, r.endCol, r.startRow, r.startCol
+	}
+
+	switch e.mode {
+	case ModeVisualLine:
+		r.kind = regionLine
+	case ModeVisualBlock:
+		r.kind = regionBlock
+		r.startCol, r.endCol = min(v.startCol, e.cursor.Col()), max(v.startCol, e.cursor.Col())+1
+		if v.toEOL {
+			r.endCol = math.MaxInt
+		}
+	default:
+		// the selection includes the character under the cursor end, and
+		// the line break when it is past the end of the line
+		r.kind = regionChar
+		r.endCol++
+		if lineLen, _ := e.buffer.LineLength(r.endRow); r.endCol > lineLen && r.endRow < e.buffer.NumLines()-1 {
+			r.endRow, r.endCol = r.endRow+1, 0
+		}
+	}
+	return r
+}
+
+// returns the selected columns of the visible rows of the current window
+// for the renderer.
+func (e *Editor) visualSelection(firstRow, lastRow int) map[int]ui.MatchRange {
+	r := e.visualRegion()
+	sel := make(map[int]ui.MatchRange)
+	for row := max(r.startRow, firstRow); row <= min(r.endRow, lastRow); row++ {
+		lineLen, _ := e.buffer.LineLength(row)
+		m := ui.MatchRange{ColStart: 0, ColEnd: lineLen + 1}
+		switch r.kind {
+		case regionBlock:
+			m = ui.MatchRange{ColStart: r.startCol, ColEnd: r.endCol}
+		case regionChar:
+			if row == r.startRow {
+				m.ColStart = r.startCol
+			}
+			if row == r.endRow {
+				m.ColEnd = r.endCol
+			}
+		}
+		if m.ColStart < m.ColEnd {
+			sel[row] = m
+		}
+	}
+	return sel
+}
+
+// handles keys in the visual modes.
+func (e *Editor) processVisualMode(key *terminal.Key) error {
+	if e.moveCursorKey(key) {
+		e.pending.Reset()
+		return nil
+	}
+
+	switch key.Type {
+	case terminal.KeyEscape:
+		e.exitVisual()
+		return nil
+	case terminal.KeyCtrl:
+		e.pending.Reset()
+		switch key.Rune {
+		case 'v':
+			e.switchVisual(ModeVisualBlock)
+		case 'c', '[':
+			e.exitVisual()
+		}
+		return nil
+	case terminal.KeyRune:
+	default:
+		return nil
+	}
+
+	ch := key.Rune
+
+	// f, t, F and T take the next key as the character to look for, '
+	// and ` as the mark to go to
+	if p := e.pending.Prefix; p == 'f' || p == 't' || p == 'F' || p == 'T' || p == '\'' || p == '`' {
+		count := e.pending.Count
+		e.pending.Reset()
+		if _, ok := e.motion(string(p), ch, count, false); ok {
+			e.visual.toEOL = false
+		}
+		return nil
+	}
+
+	// " names the register for the operator that follows
+	if e.pending.Prefix == '"' {
+		e.pending.Prefix = 0
+		e.nameRegister(ch)
+		return nil
+	}
+
+	// i and a take the next key as a text object
+	if p := e.pending.Prefix; p == 'i' || p == 'a' {
+		count := e.pending.Count
+		e.pending.Reset()
+		e.visualTextObject(p == 'i', ch, count)
+		return nil
+	}
+
+	if e.pending.Prefix == 'g' {
+		count := e.pending.Count
+		e.pending.Reset()
+		switch ch {
+		case 'g', '*', '#':
+			e.motion("g"+string(ch), 0, count, false)
+		case '~':
+			e.visualMapCase(toggleCase)
+		case 'u':
+			e.visualMapCase(strings.ToLower)
+		case 'U':
+			e.visualMapCase(strings.ToUpper)
+		}
+		return nil
+	}
+
+	// count prefix
+	if ch >= '1' && ch <= '9' || ch == '0' && e.pending.HasCount {
+		e.pending.AccumulateDigit(int(ch - '0'))
+		return nil
+	}
+
+	switch ch {
+	case 'g', 'f', 't', 'F', 'T', 'i', 'a', '"', '\'', '`':
+		e.pending.Prefix = ch
+		return nil
+	}
+	count := e.pending.EffectiveCount()
+	defer e.pending.Reset()
+
+	switch ch {
+	case 'v':
+		e.switchVisual(ModeVisual)
+	case 'V':
+		e.switchVisual(ModeVisualLine)
+	case 'o':
+		e.swapVisualEnds()
+	case 'O':
+		if e.mode == ModeVisualBlock {
+			// the other corner on the same line
+			col := e.cursor.Col()
+			e.cursor.MoveTo(e.cursor.Row(), e.visual.startCol, e.buffer)
+			e.visual.startCol = col
+		} else {
+			e.swapVisualEnds()
+		}
+	case 'd', 'x':
+		e.visualDelete()
+	case 'y':
+		e.visualYank()
+	case 'c', 's':
+		e.visualChange()
+	case '>':
+		e.visualShift(count)
+	case '<':
+		e.visualShift(-count)
+	case '~':
+		e.visualMapCase(toggleCase)
+	case '=':
+		e.visualReindent()
+	case 'u':
+		e.visualMapCase(strings.ToLower)
+	case 'U':
+		e.visualMapCase(strings.ToUpper)
+	case 'J':
+		r := e.visualRegion()
+		e.exitVisual()
+		e.undoMgr.BeginGroup()
+		e.joinLineRange(r.startRow, r.endRow)
+		e.undoMgr.EndGroup()
+	case 'I':
+		if e.mode == ModeVisualBlock {
+			e.visualBlockInsert(false)
+		}
+	case 'A':
+		if e.mode == ModeVisualBlock {
+			e.visualBlockInsert(true)
+		}
+	case ':':
+		e.exitVisual()
+		e.prevMode = ModeNormal
+		e.setMode(ModeCommand)
+		e.commandBuf = ":'<,'>"
+	case '$':
+		e.cursor.MoveToLineEndNormal(e.buffer)
+		e.visual.toEOL = e.mode == ModeVisualBlock
+	default:
+		if _, ok := e.motion(string(ch), 0, e.pending.Count, false); ok && ch != 'j' && ch != 'k' && ch != 'G' {
+			e.visual.toEOL = false
+		}
+	}
+	return nil
+}
+
+// changes to another visual mode, or leaves visual mode when already in it.
+func (e *Editor) switchVisual(mode Mode) {
+	if e.mode == mode {
+		e.exitVisual()
+		return
+	}
+	e.setMode(mode)
+}
+
+// moves the cursor to the other end of the selection (o).
+func (e *Editor) swapVisualEnds() {
+	row, col := e.cursor.Row(), e.cursor.Col()
+	e.cursor.MoveTo(e.visual.startRow, e.visual.startCol, e.buffer)
+	e.visual.startRow, e.visual.startCol = row, col
+}
+
+// --- Visual operators ---
+
+// deletes the selection (d).
+func (e *Editor) visualDelete() {
+	r := e.visualRegion()
+	e.exitVisual()
+	e.undoMgr.BeginGroup()
+	e.deleteRegion(r)
+	e.undoMgr.EndGroup()
+}
+
+// yanks the selection (y) and puts the cursor at its start.
+func (e *Editor) visualYank() {
+	r := e.visualRegion()
+	e.exitVisual()
+	e.yankRegion(r)
+	e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+	if lines := r.endRow - r.startRow + 1; r.kind != regionChar && lines > 1 {
+		e.setMessage(fmt.Sprintf("%d lines yanked", lines))
+	}
+}
+
+// replaces the selection with typed text (c). Lines are replaced by an
+// empty line; a block gets the text on every line when ESC is pressed.
+func (e *Editor) visualChange() {
+	r := e.visualRegion()
+	blockwise := e.mode == ModeVisualBlock
+	e.exitVisual()
+
+	e.changeRegion(r)
+	if blockwise {
+		e.startBlockInsert(r.startRow, r.startCol, r.endRow, false, false)
+	}
+}
+
+// shifts the selected lines by levels shiftwidths (> and <).
+func (e *Editor) visualShift(levels int) {
+	r := e.visualRegion()
+	e.exitVisual()
+	e.undoMgr.BeginGroup()
+	e.shiftLines(r.startRow, r.endRow, levels)
+	e.undoMgr.EndGroup()
+	e.cursor.MoveTo(r.startRow, 0, e.buffer)
+	e.moveToFirstNonBlank()
+}
+
+// re-indents the selected lines (=).
+func (e *Editor) visualReindent() {
+	r := e.visualRegion()
+	e.exitVisual()
+	e.undoMgr.BeginGroup()
+	e.reindentLines(r.startRow, r.endRow)
+	e.undoMgr.EndGroup()
+	e.cursor.MoveTo(r.startRow, 0, e.buffer)
+	e.moveToFirstNonBlank()
+}
+
+// changes the case of the selection (~, u, U).
+func (e *Editor) visualMapCase(fn func(string) string) {
+	r := e.visualRegion()
+	e.exitVisual()
+	e.undoMgr.BeginGroup()
+	e.mapRegion(r, fn)
+	e.undoMgr.EndGroup()
+	e.cursor.MoveTo(r.startRow, r.startCol, e.buffer)
+}
+
+// inserts before (I) or appends after (A) the block on every line.
+func (e *Editor) visualBlockInsert(appendText bool) {
+	r := e.visualRegion()
+	toEOL := e.visual.toEOL
+	e.exitVisual()
+
+	col := r.startCol
+	if appendText {
+		col = r.endCol
+	}
+	if toEOL && appendText {
+		col, _ = e.buffer.LineLength(r.startRow)
+	}
+
+	e.undoMgr.BeginGroup()
+	if appendText {
+		// the first line may be too short to reach the column
+		if line, _ := e.buffer.GetLine(r.startRow); utf8.RuneCountInString(line) < col {
+			e.replaceLines(r.startRow, r.startRow, []string{padRight(line, col)})
+		}
+	}
+	e.cursor.MoveTo(r.startRow, col, e.buffer)
+	e.startBlockInsert(r.startRow, col, r.endRow, appendText, toEOL && appendText)
+	e.setMode(ModeInsert)
+}
+
+// remembers what is needed to repeat the text typed at row, col on the
+// following lines of a block once insert mode ends.
+func (e *Editor) startBlockInsert(row, col, lastRow int, pad, toEOL bool) {
+	if lastRow <= row {
+		return
+	}
+	before, _ := e.buffer.GetLine(row)
+	e.block = &blockInsert{
+		row: row, col: col, lastRow: lastRow,
+		before: before, numLines: e.buffer.NumLines(),
+		pad: pad, toEOL: toEOL,
+	}
+}
+
+// copies the text typed during a blockwise insert to the other lines of
+// the block. Called when insert mode ends; typing a line break (or moving
+// the text around) cancels the copy.
+func (e *Editor) finishBlockInsert() {
+	b := e.block
+	e.block = nil
+	if b == nil || e.buffer.NumLines() != b.numLines {
+		return
+	}
+
+	now, _ := e.buffer.GetLine(b.row)
+	before, after := []rune(b.before), []rune(now)
+	n := len(after) - len(before)
+	if n <= 0 || b.col > len(before) ||
+		string(after[:b.col]) != string(before[:b.col]) || string(after[b.col+n:]) != string(before[b.col:]) {
+		return
+	}
+	text := string(after[b.col : b.col+n])
+
+	lines := e.lineRange(b.row+1, b.lastRow)
+	for i, line := range lines {
+		runes := []rune(line)
+		col := b.col
+		if b.toEOL {
+			col = len(runes)
+		}
+		if len(runes) < col {
+			if !b.pad {
+				continue // short lines are not part of the block
+			}
+			line, runes = padRight(line, col), []rune(padRight(line, col))
+		}
+		lines[i] = string(runes[:col]) + text + string(runes[col:])
+	}
+	e.replaceLines(b.row+1, b.lastRow, lines)
+	e.cursor.MoveTo(b.row, b.col, e.buffer)
+}
+
+// pastes a block register as a column at the cursor: one piece per line,
+// on the cursor line and the ones below it.
+func (e *Editor) pasteBlock(content string, afterCursor bool) {
+	row, col := e.cursor.Row(), e.cursor.Col()
+	if afterCursor {
+		if lineLen, _ := e.buffer.LineLength(row); lineLen > 0 {
+			col++
+		}
+	}
+
+	pieces := strings.Split(content, "\n")
+	width := 0
+	for _, p := range pieces {
+		width = max(width, utf8.RuneCountInString(p))
+	}
+
+	// lines past the end of the buffer are added
+	lastRow := min(row+len(pieces), e.buffer.NumLines()) - 1
+	lines := e.lineRange(row, lastRow)
+	for len(lines) < len(pieces) {
+		lines = append(lines, "")
+	}
+
+	for i, p := range pieces {
+		runes := []rune(lines[i])
+		if len(runes) < col {
+			lines[i] = padRight(lines[i], col)
+			runes = []rune(lines[i])
+		}
+		piece := p
+		if col < len(runes) {
+			piece = padRight(p, width) // keep the rest of the line aligned
+		}
+		lines[i] = string(runes[:col]) + piece + string(runes[col:])
+	}
+	e.replaceLines(row, lastRow, lines)
+	e.cursor.MoveTo(row, col, e.buffer)
+}
+
+// pads s with spaces to width runes.
+func padRight(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
@@ -0,0 +1,700 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AdityaKrSingh26/Glime/internal/buffer"
+	"github.com/AdityaKrSingh26/Glime/internal/cursor"
+	"github.com/AdityaKrSingh26/Glime/internal/terminal"
+	"github.com/AdityaKrSingh26/Glime/internal/ui"
+)
+
+// split windows: the screen above the message bar is divided by a layout
+// tree. Leaves are windows; inner nodes stack their children top to bottom
+// (:split) or put them side by side (:vsplit). Every window shows a buffer
+// with its own cursor and scroll offsets and has a status line below its
+// text. Editor embeds the current window, which embeds its buffer.
+
+const (
+	windowMinHeight = 2  // one text row plus the status line
+	windowMinWidth  = 12 // room for the gutter and a few columns of text
+)
+
+type window struct {
+	*bufferEntry                // the buffer shown in the window
+	cursor       *cursor.Cursor // cursor and scroll offsets of this window
+	winOpts      windowOptions  // window-local options (:setlocal)
+	node         *layoutNode
+
+	// screen rectangle of the text area (0-based), set by arrange
+	top, left, width, height int
+}
+
+// a node of the layout tree: either a window or a split holding children.
+type layoutNode struct {
+	win      *window // set for leaves
+	vertical bool    // children side by side (:vsplit) instead of stacked
+	children []*layoutNode
+	parent   *layoutNode
+	size     int // rows (stacked parent) or columns (side by side parent) taken
+}
+
+// creates a window showing b at the cursor position b was last left at,
+// with the window-local options opts.
+func newWindow(b *bufferEntry, opts windowOptions) *window {
+	c := b.lastCursor
+	w := &window{bufferEntry: b, cursor: &c, winOpts: opts}
+	w.node = &layoutNode{win: w}
+	return w
+}
+
+// returns the windows of the current tab page in left-to-right,
+// top-to-bottom order.
+func (e *Editor) windows() []*window {
+	return e.tabPage.windows()
+}
+
+// returns the windows of the tab page's layout in left-to-right,
+// top-to-bottom order.
+func (t *tabPage) windows() []*window {
+	var list []*window
+	var walk func(n *layoutNode)
+	walk = func(n *layoutNode) {
+		if n.win != nil {
+			list = append(list, n.win)
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(t.layout)
+	return list
+}
+
+// returns how many windows, in all tab pages, show b.
+func (e *Editor) windowsShowing(b *bufferEntry) int {
+	n := 0
+	for _, w := range e.allWindows() {
+		if w.bufferEntry == b {
+			n++
+		}
+	}
+	return n
+}
+
+// makes w the current window.
+func (e *Editor) enterWindow(w *window) {
+	if w == e.window {
+		return
+	}
+	if w.bufferEntry != e.bufferEntry && e.swapPending() {
+		e.writeSwap()
+	}
+	e.pending.Reset()
+	e.prevWindow = e.window
+	e.window = w
+}
+
+// --- Layout ---
+
+// assigns screen rectangles to the windows below n, which gets the given
+// area (status lines included).
+func (n *layoutNode) arrange(top, left, height, width int) {
+	if n.win != nil {
+		n.win.top, n.win.left = top, left
+		n.win.height, n.win.width = height-1, width
+		return
+	}
+
+	total := height
+	if n.vertical {
+		total = width - (len(n.children) - 1) // separators
+	}
+	distribute(n.children, total, n.vertical)
+
+	pos := top
+	if n.vertical {
+		pos = left
+	}
+	for _, c := range n.children {
+		if n.vertical {
+			c.arrange(top, pos, height, c.size)
+			pos += c.size + 1
+		} else {
+			c.arrange(pos, left, c.size, width)
+			pos += c.size
+		}
+	}
+}
+
+// fits the sizes of children to total, keeping their proportions. Children
+// without a size yet (or all of them after :wincmd =) share equally.
+func distribute(children []*layoutNode, total int, vertical bool) {
+	sum := 0
+	equal := false
+	for _, c := range children {
+		if c.size <= 0 {
+			equal = true
+		}
+		sum += c.size
+	}
+
+	left := total
+	for i, c := range children {
+		switch {
+		case i == len(children)-1:
+			c.size = left
+		case equal:
+			c.size = total / len(children)
+		default:
+			c.size = c.size * total / sum
+		}
+		left -= c.size
+	}
+
+	// give windows that got too small their minimum back from the biggest
+	for _, c := range children {
+		for need := c.minSize(vertical) - c.size; need > 0; {
+			big := children[0]
+			for _, o := range children {
+				if o.size-o.minSize(vertical) > big.size-big.minSize(vertical) {
+					big = o
+				}
+			}
+			spare := big.size - big.minSize(vertical)
+			if spare <= 0 || big == c {
+				break
+			}
+			take := min(need, spare)
+			big.size -= take
+			c.size += take
+			need -= take
+		}
+	}
+}
+
+// returns the smallest size n can take along a parent of the given
+// orientation.
+func (n *layoutNode) minSize(vertical bool) int {
+	if n.win != nil {
+		if vertical {
+			return windowMinWidth
+		}
+		return windowMinHeight
+	}
+
+	size := 0
+	for _, c := range n.children {
+		m := c.minSize(vertical)
+		if n.vertical == vertical {
+			size += m
+		} else {
+			size = max(size, m)
+		}
+	}
+	if n.vertical && vertical {
+		size += len(n.children) - 1 // separators
+	}
+	return size
+}
+
+// lays out all windows over the screen between the tab line and the
+// message bar and keeps every window's cursor visible.
+func (e *Editor) arrangeWindows() {
+	top := e.tabLineRows()
+	e.layout.arrange(top, 0, e.terminal.Height()-1-top, e.terminal.Width())
+
+	for _, w := range e.windows() {
+		if w != e.window {
+			// the buffer may have been edited through another window
+			w.cursor.Clamp(w.buffer)
+		}
+		w.scrollToCursor(e.scrollOff)
+	}
+}
+
+// --- Screen lines ---
+
+// returns the width of the line number gutter of w.
+func (w *window) gutterWidth() int {
+	if !w.winOpts.number && !w.winOpts.relativeNumber {
+		return 0
+	}
+	return ui.GutterWidth(w.buffer.NumLines())
+}
+
+// returns the columns of w left for text.
+func (w *window) textWidth() int {
+	return max(w.width-w.gutterWidth(), 1)
+}
+
+// returns the screen rows line row of the buffer takes in w.
+func (w *window) lineRows(row int) int {
+	if !w.winOpts.wrap {
+		return 1
+	}
+	line, _ := w.buffer.GetLine(row)
+	return ui.WrappedRows(line, w.textWidth(), w.bufOpts.tabStop)
+}
+
+// scrolls w to keep its cursor in view, scrollOff lines away from the top
+// and bottom where the buffer allows.
+func (w *window) scrollToCursor(scrollOff int) {
+	line, _ := w.buffer.GetLine(w.cursor.Row())
+	view := cursor.View{
+		Rows:       w.height,
+		Cols:       w.textWidth(),
+		ScrollOff:  scrollOff,
+		Lines:      w.buffer.NumLines(),
+		DisplayCol: ui.DisplayColumn(line, w.cursor.Col(), w.bufOpts.tabStop),
+	}
+	if w.winOpts.wrap {
+		view.LineRows = func(row int) int {
+			if row >= w.buffer.NumLines() {
+				return 0
+			}
+			return w.lineRows(row)
+		}
+	}
+	w.cursor.UpdateScroll(view)
+}
+
+// returns the screen position (0-based) of the cursor of w.
+func (w *window) screenPosition() (row, col int) {
+	line, _ := w.buffer.GetLine(w.cursor.Row())
+	dcol := ui.DisplayColumn(line, w.cursor.Col(), w.bufOpts.tabStop)
+	if !w.winOpts.wrap {
+		return w.top + w.cursor.Row() - w.cursor.RowOffset(), w.left + w.gutterWidth() + dcol - w.cursor.ColOffset()
+	}
+
+	row = w.top
+	for r := w.cursor.RowOffset(); r < w.cursor.Row(); r++ {
+		row += w.lineRows(r)
+	}
+	seg := min(dcol/w.textWidth(), w.lineRows(w.cursor.Row())-1)
+	return row + seg, w.left + w.gutterWidth() + dcol - seg*w.textWidth()
+}
+
+// returns the index of n among its parent's children.
+func (n *layoutNode) index() int {
+	for i, c := range n.parent.children {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// puts repl where n is in the tree.
+func (e *Editor) replaceNode(n, repl *layoutNode) {
+	repl.parent = n.parent
+	if n.parent == nil {
+		e.layout = repl
+		return
+	}
+	n.parent.children[n.index()] = repl
+}
+
+// --- Splitting and closing ---
+
+// splits the current window in two; the new window shows the same buffer,
+// goes above (or left of) the current one and gets the focus.
+func (e *Editor) splitWindow(vertical bool) error {
+	cur := e.window
+	n := cur.node
+
+	avail, need := cur.height+1, 2*windowMinHeight
+	if vertical {
+		avail, need = cur.width, 2*windowMinWidth+1
+	}
+	if avail < need {
+		return fmt.Errorf("not enough room")
+	}
+
+	w := newWindow(cur.bufferEntry, cur.winOpts)
+	*w.cursor = *cur.cursor
+	leaf := w.node
+
+	if n.parent == nil || n.parent.vertical != vertical {
+		// wrap the window in a new split of the requested orientation
+		split := &layoutNode{vertical: vertical, size: n.size}
+		e.replaceNode(n, split)
+		split.children = []*layoutNode{leaf, n}
+		leaf.parent, n.parent = split, split
+		n.size = avail
+	} else {
+		p := n.parent
+		i := n.index()
+		p.children = append(p.children[:i], append([]*layoutNode{leaf}, p.children[i:]...)...)
+		leaf.parent = p
+	}
+
+	if vertical {
+		leaf.size = (n.size - 1) / 2
+		n.size -= leaf.size + 1
+	} else {
+		leaf.size = n.size / 2
+		n.size -= leaf.size
+	}
+
+	e.enterWindow(w)
+	return nil
+}
+
+// closes w; its space goes to a neighbour. The last window can't be closed.
+func (e *Editor) closeWindow(w *window) error {
+	n := w.node
+	p := n.parent
+	if p == nil {
+		return fmt.Errorf("cannot close last window")
+	}
+
+	w.bufferEntry.lastCursor = *w.cursor
+
+	i := n.index()
+	neighbour := p.children[max(i-1, 0)]
+	if i == 0 {
+		neighbour = p.children[1]
+	}
+	neighbour.size += n.size
+	if p.vertical {
+		neighbour.size++ // the separator
+	}
+	p.children = append(p.children[:i], p.children[i+1:]...)
+
+	if len(p.children) == 1 {
+		// a split with one child left is replaced by that child
+		only := p.children[0]
+		only.size = p.size
+		e.replaceNode(p, only)
+
+		// and a split inside a split of the same orientation is merged into it
+		if gp := only.parent; only.win == nil && gp != nil && gp.vertical == only.vertical {
+			j := only.index()
+			for _, c := range only.children {
+				c.parent = gp
+			}
+			gp.children = append(gp.children[:j], append(only.children, gp.children[j+1:]...)...)
+		}
+	}
+
+	if w == e.window {
+		next := firstWindow(neighbour)
+		if e.prevWindow != nil && e.prevWindow != w && e.windowInTree(e.prevWindow, neighbour) {
+			next = e.prevWindow
+		}
+		e.enterWindow(next)
+	}
+	if e.prevWindow == w {
+		e.prevWindow = nil
+	}
+	return nil
+}
+
+// returns the first window below n.
+func firstWindow(n *layoutNode) *window {
+	for n.win == nil {
+		n = n.children[0]
+	}
+	return n.win
+}
+
+// reports whether w is below n in the layout tree.
+func (e *Editor) windowInTree(w *window, n *layoutNode) bool {
+	for m := w.node; m != nil; m = m.parent {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// closes every window but the current one (:only).
+func (e *Editor) onlyWindow() {
+	for _, w := range e.windows() {
+		if w != e.window {
+			w.bufferEntry.lastCursor = *w.cursor
+		}
+	}
+	e.window.node.parent = nil
+	e.window.node.size = 0
+	e.layout = e.window.node
+	e.prevWindow = nil
+}
+
+// --- Moving between windows ---
+
+// moves to the window next to the current one in direction dir (h, j, k
+// or l), count times. The neighbour is the one beside the cursor.
+func (e *Editor) windowMove(dir rune, count int) {
+	for ; count > 0; count-- {
+		cur := e.window
+		row, col := cur.screenPosition()
+
+		var best *window
+		for _, w := range e.windows() {
+			var adjacent, beside bool
+			switch dir {
+			case 'h':
+				adjacent = w.left+w.width+1 == cur.left
+				beside = w.top <= row && row <= w.top+w.height
+			case 'l':
+				adjacent = cur.left+cur.width+1 == w.left
+				beside = w.top <= row && row <= w.top+w.height
+			case 'k':
+				adjacent = w.top+w.height+1 == cur.top
+				beside = w.left <= col && col <= w.left+w.width
+			case 'j':
+				adjacent = cur.top+cur.height+1 == w.top
+				beside = w.left <= col && col <= w.left+w.width
+			}
+			if adjacent && (beside || best == nil) {
+				best = w
+				if beside {
+					break
+				}
+			}
+		}
+		if best == nil {
+			return
+		}
+		e.enterWindow(best)
+	}
+}
+
+// moves to the window count steps further in layout order, wrapping
+// around; negative counts go backwards (Ctrl-w w, Ctrl-w W).
+func (e *Editor) windowCycle(count int) {
+	list := e.windows()
+	n := len(list)
+	for i, w := range list {
+		if w == e.window {
+			e.enterWindow(list[((i+count)%n+n)%n])
+			return
+		}
+	}
+}
+
+// moves to window number nr (1-based, in layout order).
+func (e *Editor) windowGoto(nr int) {
+	list := e.windows()
+	e.enterWindow(list[min(max(nr, 1), len(list))-1])
+}
+
+// --- Resizing ---
+
+// finds the node to resize for the current window along the orientation
+// (the window itself or the split holding it), or nil if it fills the screen.
+func (e *Editor) resizeNode(vertical bool) *layoutNode {
+	n := e.window.node
+	for n.parent != nil && n.parent.vertical != vertical {
+		n = n.parent
+	}
+	if n.parent == nil {
+		return nil
+	}
+	return n
+}
+
+// sets the size of n, taking space from (or giving it to) its siblings,
+// the ones after it first.
+func setNodeSize(n *layoutNode, size int) {
+	p := n.parent
+	size = max(size, n.minSize(p.vertical))
+
+	i := n.index()
+	siblings := append([]*layoutNode{}, p.children[i+1:]...)
+	for j := i - 1; j >= 0; j-- {
+		siblings = append(siblings, p.children[j])
+	}
+
+	want := size - n.size
+	if want < 0 {
+		siblings[0].size -= want
+		n.size = size
+		return
+	}
+	for _, s := range siblings {
+		take := min(want, s.size-s.minSize(p.vertical))
+		if take > 0 {
+			s.size -= take
+			n.size += take
+			want -= take
+		}
+	}
+}
+
+// changes the height (or width) of the current window by delta.
+func (e *Editor) resizeWindow(vertical bool, delta int) {
+	if n := e.resizeNode(vertical); n != nil {
+		setNodeSize(n, n.size+delta)
+	}
+}
+
+// sets the text height (or width) of the current window.
+func (e *Editor) setWindowSize(vertical bool, size int) {
+	n := e.resizeNode(vertical)
+	if n == nil {
+		return
+	}
+	// n.size counts the status lines of all stacked windows below n;
+	// only the current window's own text rows are being set
+	current := e.window.width
+	if !vertical {
+		current = e.window.height
+	}
+	setNodeSize(n, n.size+size-current)
+}
+
+// gives every window the same size (Ctrl-w =).
+func (e *Editor) equalizeWindows() {
+	var walk func(n *layoutNode)
+	walk = func(n *layoutNode) {
+		n.size = 0
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(e.layout)
+}
+
+// --- Commands ---
+
+// handles the key after Ctrl-w.
+func (e *Editor) processWindowCommand(key *terminal.Key, count int, hasCount bool) error {
+	ch := key.Rune
+	switch key.Type {
+	case terminal.KeyRune, terminal.KeyCtrl:
+	case terminal.KeyArrowLeft:
+		ch = 'h'
+	case terminal.KeyArrowDown:
+		ch = 'j'
+	case terminal.KeyArrowUp:
+		ch = 'k'
+	case terminal.KeyArrowRight:
+		ch = 'l'
+	default:
+		return nil
+	}
+
+	switch ch {
+	case 'h', 'j', 'k', 'l':
+		e.windowMove(ch, count)
+	case 'w':
+		if hasCount {
+			e.windowGoto(count)
+		} else {
+			e.windowCycle(1)
+		}
+	case 'W':
+		if hasCount {
+			e.windowGoto(count)
+		} else {
+			e.windowCycle(-1)
+		}
+	case 'p':
+		if e.prevWindow != nil {
+			e.enterWindow(e.prevWindow)
+		}
+	case 't':
+		e.windowGoto(1)
+	case 'b':
+		e.windowGoto(len(e.windows()))
+	case 's', 'S':
+		return e.commandSplit(false, "")
+	case 'v':
+		return e.commandSplit(true, "")
+	case 'n':
+		return e.commandNew(false)
+	case 'c':
+		return e.commandClose()
+	case 'q':
+		return e.commandQuit(false)
+	case 'o':
+		e.onlyWindow()
+	case '+':
+		e.resizeWindow(false, count)
+	case '-':
+		e.resizeWindow(false, -count)
+	case '>':
+		e.resizeWindow(true, count)
+	case '<':
+		e.resizeWindow(true, -count)
+	case '_':
+		if !hasCount {
+			count = e.terminal.Height()
+		}
+		e.setWindowSize(false, count)
+	case '|':
+		if !hasCount {
+			count = e.terminal.Width()
+		}
+		e.setWindowSize(true, count)
+	case '=':
+		e.equalizeWindows()
+	case '^':
+		if err := e.commandSplit(false, ""); err != nil {
+			return err
+		}
+		e.switchAlternate(count, hasCount)
+	}
+	return nil
+}
+
+// splits the current window, optionally editing filePath in the new one
+// (:split, :vsplit).
+func (e *Editor) commandSplit(vertical bool, filePath string) error {
+	if err := e.splitWindow(vertical); err != nil {
+		e.setMessage(fmt.Sprintf("Split failed: %v", err))
+		return nil
+	}
+	if filePath != "" {
+		return e.editFile(filePath)
+	}
+	return nil
+}
+
+// splits the current window and shows a new empty buffer in it (:new, :vnew).
+func (e *Editor) commandNew(vertical bool) error {
+	if err := e.splitWindow(vertical); err != nil {
+		e.setMessage(fmt.Sprintf("Split failed: %v", err))
+		return nil
+	}
+	b := e.newBufferEntry(buffer.New())
+	e.buffers = append(e.buffers, b)
+	e.switchBuffer(b)
+	return nil
+}
+
+// closes the current window unless it is the last one (:close). Closing
+// the last window of a tab page closes the tab.
+func (e *Editor) commandClose() error {
+	if len(e.windows()) == 1 && len(e.tabs) > 1 {
+		return e.commandTabClose("")
+	}
+	if err := e.closeWindow(e.window); err != nil {
+		e.setMessage(fmt.Sprintf("Close failed: %v", err))
+	}
+	return nil
+}
+
+// handles :resize and :vertical resize with "N", "+N" or "-N".
+func (e *Editor) commandResize(vertical bool, arg string) error {
+	if arg == "" {
+		e.setWindowSize(vertical, e.terminal.Width()+e.terminal.Height()) // maximize
+		return nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid size: %s", arg)
+	}
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		e.resizeWindow(vertical, n)
+	} else {
+		e.setWindowSize(vertical, n)
+	}
+	return nil
+}
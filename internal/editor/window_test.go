@@ -0,0 +1,42 @@
+package editor
+
+import "testing"
+
+// the window commands take Vim's abbreviations.
+func TestWindowCommandAbbreviations(t *testing.T) {
+	tests := []struct {
+		cmds    []string
+		windows int
+	}{
+		{[]string{"sp"}, 2},
+		{[]string{"spl"}, 2},
+		{[]string{"split"}, 2},
+		{[]string{"vs"}, 2},
+		{[]string{"vsp"}, 2},
+		{[]string{"vspl"}, 2},
+		{[]string{"vne"}, 2},
+		{[]string{"vs", "clo"}, 1},
+		{[]string{"sp", "clos!"}, 1},
+		{[]string{"vs", "on"}, 1},
+		{[]string{"sp", "onl!"}, 1},
+		{[]string{"sp", "res 5"}, 2},
+	}
+	for _, tt := range tests {
+		e := newTestEditor(t, "one")
+		e.window.height, e.window.width = 40, 120
+		for _, cmd := range tt.cmds {
+			if err := e.executeCommand(cmd); err != nil {
+				t.Errorf("%v: :%s: %v", tt.cmds, cmd, err)
+			}
+		}
+		if n := len(e.windows()); n != tt.windows {
+			t.Errorf("%v: %d windows, want %d (message %q)", tt.cmds, n, tt.windows, e.message)
+		}
+	}
+
+	for _, cmd := range []string{"s", "v", "cl", "o", "re"} {
+		if windowCommandName(cmd) != cmd {
+			t.Errorf("%q is too short for a window command but was taken as %q", cmd, windowCommandName(cmd))
+		}
+	}
+}
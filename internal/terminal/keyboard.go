@@ -3,7 +3,10 @@ package terminal
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -28,15 +31,64 @@ const (
 	KeyPageDown
 	KeyHome
 	KeyEnd
-	KeyCtrl // For Ctrl+key combinations
+	KeyCtrl     // For Ctrl+key combinations
+	KeyFocusIn  // terminal window gained focus
+	KeyFocusOut // terminal window lost focus
+	KeyPaste    // text pasted into the terminal (bracketed paste), in Key.Text
+	KeyMouse    // mouse click, drag or wheel turn, in Key.Mouse
+	KeyInsert
+	KeyF1 // F1-F12 follow in order
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
 )
 
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone // motion without a button held
+	MouseWheelUp
+	MouseWheelDown
+)
+
+type MouseAction int
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseDrag
+)
+
+// a mouse event reported by the terminal.
+type MouseEvent struct {
+	Button   MouseButton
+	Action   MouseAction
+	Row, Col int // 0-based screen position
+}
+
+// ends the pasted text in bracketed paste mode, which starts with ESC[200~.
+const pasteEnd = "\x1b[201~"
+
 // Key represent a single key event
 type Key struct {
-	Type KeyType
-	Rune rune
-	Ctrl bool // whether ctrl was held
-	Alt  bool // whether alt was held
+	Type  KeyType
+	Rune  rune
+	Ctrl  bool       // whether ctrl was held
+	Alt   bool       // whether alt was held
+	Shift bool       // whether shift was held, for keys other than runes
+	Text  string     // the pasted text of a KeyPaste
+	Mouse MouseEvent // the mouse event of a KeyMouse
 }
 
 // inputReader reads bytes from an io.Reader via a background goroutine,
@@ -90,7 +142,25 @@ func (t *Terminal) ReadKey() (*Key, error) {
 	if err != nil {
 		return nil, err
 	}
+	return t.parseKey(b)
+}
+
+// ReadKeyTimeout is like ReadKey but gives up after timeout.
+// Returns a nil key (and nil error) when no key arrived in time.
+func (t *Terminal) ReadKeyTimeout(timeout time.Duration) (*Key, error) {
+	select {
+	case b, ok := <-t.input.ch:
+		if !ok {
+			return nil, io.EOF
+		}
+		return t.parseKey(b)
+	case <-time.After(timeout):
+		return nil, nil
+	}
+}
 
+// decodes the key that starts with byte b, reading any remaining bytes.
+func (t *Terminal) parseKey(b byte) (*Key, error) {
 	// handle escape sequences
 	if b == 0x1b { // ESC
 		return parseEscapeSequence(t.input)
@@ -120,57 +190,287 @@ func parseEscapeSequence(ir *inputReader) (*Key, error) {
 		return &Key{Type: KeyEscape}, nil
 	}
 
-	// CSI sequences: ESC [ ...
-	if b1 == '[' {
-		b2, err := ir.readByte()
-		if err != nil {
-			return &Key{Type: KeyEscape}, nil
-		}
-
-		switch b2 {
-		case 'A':
-			return &Key{Type: KeyArrowUp}, nil
-		case 'B':
-			return &Key{Type: KeyArrowDown}, nil
-		case 'C':
-			return &Key{Type: KeyArrowRight}, nil
-		case 'D':
-			return &Key{Type: KeyArrowLeft}, nil
-		case 'H':
-			return &Key{Type: KeyHome}, nil
-		case 'F':
-			return &Key{Type: KeyEnd}, nil
-		case '5':
-			// Page Up (ESC [ 5 ~)
-			if b3, err := ir.readByte(); err == nil && b3 == '~' {
-				return &Key{Type: KeyPageUp}, nil
-			}
-		case '6':
-			// Page Down (ESC [ 6 ~)
-			if b3, err := ir.readByte(); err == nil && b3 == '~' {
-				return &Key{Type: KeyPageDown}, nil
-			}
-		case '3':
-			// Delete (ESC [ 3 ~)
-			if b3, err := ir.readByte(); err == nil && b3 == '~' {
-				return &Key{Type: KeyDelete}, nil
-			}
+	switch b1 {
+	case '[', 'O':
+		// CSI (ESC [) and SS3 (ESC O) sequences, or Alt-[ and Alt-O when
+		// nothing follows
+		b2, ok := ir.readByteTimeout(escapeTimeout)
+		if !ok {
+			return &Key{Type: KeyRune, Rune: rune(b1), Alt: true}, nil
 		}
+		if b1 == 'O' {
+			return parseSS3(b2), nil
+		}
+		return parseCSI(ir, b2)
+	case 0x1b:
+		// Alt with a key that sends ESC itself: Escape, or an arrow in rxvt
+		key, err := parseEscapeSequence(ir)
+		if key != nil {
+			key.Alt = true
+		}
+		return key, err
 	}
 
-	// ALT + key sequences (ESC followed by a regular key)
-	if b1 >= 'a' && b1 <= 'z' {
-		return &Key{
-			Type: KeyRune,
-			Rune: rune(b1),
-			Alt:  true,
-		}, nil
+	// ALT + key sequences (ESC followed by the key)
+	var key *Key
+	var err error
+	switch {
+	case b1 < 0x20:
+		key, err = parseControlChar(b1)
+	case b1 == 0x7f:
+		key = &Key{Type: KeyBackspace}
+	default:
+		key, err = parseUTF8(ir, b1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key.Alt = true
+	return key, nil
+}
+
+// keys identified by the final byte of a CSI or SS3 sequence
+// (ESC [ 1 ; 5 C, ESC O P).
+var finalKeys = map[byte]KeyType{
+	'A': KeyArrowUp,
+	'B': KeyArrowDown,
+	'C': KeyArrowRight,
+	'D': KeyArrowLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// keys identified by the number of a CSI sequence ending in ~
+// (ESC [ 5 ~, ESC [ 1 5 ; 2 ~).
+var tildeKeys = map[int]KeyType{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPageUp,
+	6:  KeyPageDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// modifier bits of the xterm and kitty encodings, which send 1 + the bits
+// of the modifiers held.
+const (
+	modShift = 1
+	modAlt   = 2
+	modCtrl  = 4
+)
+
+// decodes an SS3 sequence, ESC O and one byte: the arrows, Home and End in
+// application cursor mode, and F1-F4.
+func parseSS3(b byte) *Key {
+	if t, ok := finalKeys[b]; ok {
+		return &Key{Type: t}
+	}
+	return &Key{Type: KeyEscape}
+}
+
+// decodes a CSI sequence, ESC [ followed by first and the rest read here.
+func parseCSI(ir *inputReader, first byte) (*Key, error) {
+	params := readParams(ir, first)
+	args, final := params[:len(params)-1], params[len(params)-1]
+
+	switch {
+	case first == '<':
+		// SGR mouse report (ESC [ < button ; col ; row M/m)
+		if key, ok := parseMouse(params); ok {
+			return key, nil
+		}
+	case params == "200~":
+		// start of a bracketed paste (ESC [ 2 0 0 ~)
+		return readPaste(ir)
+	case params == "I":
+		return &Key{Type: KeyFocusIn}, nil
+	case params == "O":
+		return &Key{Type: KeyFocusOut}, nil
+	case params == "Z":
+		// Shift-Tab (back tab)
+		return &Key{Type: KeyTab, Shift: true}, nil
+	case params == "[":
+		// F1-F5 on the Linux console (ESC [ [ A to ESC [ [ E)
+		if b, err := ir.readByte(); err == nil && b >= 'A' && b <= 'E' {
+			return &Key{Type: KeyF1 + KeyType(b-'A')}, nil
+		}
+	case final == 'u':
+		// kitty keyboard protocol (ESC [ code ; modifiers u)
+		if key, ok := parseKitty(args); ok {
+			return key, nil
+		}
+	case final == '~':
+		code, mods := splitParams(args)
+		if t, ok := tildeKeys[code]; ok {
+			return withModifiers(&Key{Type: t}, mods), nil
+		}
+	default:
+		if t, ok := finalKeys[final]; ok {
+			_, mods := splitParams(args)
+			return withModifiers(&Key{Type: t}, mods), nil
+		}
 	}
 
 	// unknown escape sequence, return ESC
 	return &Key{Type: KeyEscape}, nil
 }
 
+// decodes a key of the kitty keyboard protocol, "code;modifiers" with the
+// code a Unicode code point. Ctrl with a letter is told apart from the
+// control character it used to send: Ctrl-i is not Tab, Ctrl-m not Enter.
+// Ctrl-[ stays Escape, as in every other terminal.
+func parseKitty(args string) (*Key, bool) {
+	code, mods := splitParams(args)
+	var key *Key
+	switch code {
+	case 27:
+		key = &Key{Type: KeyEscape}
+	case 13:
+		key = &Key{Type: KeyEnter}
+	case 9:
+		key = &Key{Type: KeyTab}
+	case 127:
+		key = &Key{Type: KeyBackspace}
+	default:
+		// private use codes are keypad and modifier keys, not asked for
+		r := rune(code)
+		if r < 0x20 || r >= 0xe000 && r <= 0xf8ff || !utf8.ValidRune(r) {
+			return nil, false
+		}
+		bits := mods - 1
+		if bits&modShift != 0 {
+			r = unicode.ToUpper(r)
+		}
+		switch {
+		case bits&modCtrl != 0 && r == '[':
+			key = &Key{Type: KeyEscape}
+		case bits&modCtrl != 0:
+			key = &Key{Type: KeyCtrl, Rune: unicode.ToLower(r)}
+		default:
+			key = &Key{Type: KeyRune, Rune: r}
+		}
+	}
+	return withModifiers(key, mods), true
+}
+
+// splits the parameters of a CSI sequence into its number and modifiers,
+// "code;modifiers", either of which may be left out. Sub-parameters after
+// a colon are dropped.
+func splitParams(args string) (code, mods int) {
+	code, mods = 1, 1
+	fields := strings.Split(args, ";")
+	field := func(i int) (int, bool) {
+		if i >= len(fields) {
+			return 0, false
+		}
+		f, _, _ := strings.Cut(fields[i], ":")
+		n, err := strconv.Atoi(f)
+		return n, err == nil
+	}
+	if n, ok := field(0); ok {
+		code = n
+	}
+	if n, ok := field(1); ok && n > 0 {
+		mods = n
+	}
+	return code, mods
+}
+
+// sets the modifiers of key from the modifier parameter of its sequence.
+func withModifiers(key *Key, mods int) *Key {
+	bits := mods - 1
+	key.Shift = key.Shift || bits&modShift != 0
+	key.Alt = key.Alt || bits&modAlt != 0
+	key.Ctrl = key.Ctrl || bits&modCtrl != 0
+	return key
+}
+
+// reads the rest of a CSI sequence starting with first, up to and
+// including its final byte.
+func readParams(ir *inputReader, first byte) string {
+	params := []byte{first}
+	for b := first; b < 0x40 || b > 0x7e; {
+		var err error
+		if b, err = ir.readByte(); err != nil {
+			break
+		}
+		params = append(params, b)
+	}
+	return string(params)
+}
+
+// decodes an SGR mouse report: "<b;x;y" with M for a press or drag and m
+// for a release. b holds the button in its low bits, 32 for motion and 64
+// for the wheel; the modifier bits (4, 8, 16) are ignored.
+func parseMouse(params string) (*Key, bool) {
+	if len(params) < 2 {
+		return nil, false
+	}
+	final := params[len(params)-1]
+	fields := strings.Split(params[1:len(params)-1], ";")
+	if (final != 'M' && final != 'm') || len(fields) != 3 {
+		return nil, false
+	}
+	var n [3]int
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, false
+		}
+		n[i] = v
+	}
+
+	ev := MouseEvent{Button: MouseButton(n[0] & 3), Col: n[1] - 1, Row: n[2] - 1}
+	switch {
+	case n[0]&64 != 0:
+		ev.Button = MouseWheelUp + MouseButton(n[0]&1)
+	case n[0]&32 != 0:
+		ev.Action = MouseDrag
+	case final == 'm':
+		ev.Action = MouseRelease
+	}
+	return &Key{Type: KeyMouse, Mouse: ev}, true
+}
+
+// reads pasted text up to the end marker of a bracketed paste. Line
+// breaks, sent as carriage returns, become newlines.
+func readPaste(ir *inputReader) (*Key, error) {
+	var text []byte
+	for {
+		b, err := ir.readByte()
+		if err != nil {
+			break
+		}
+		text = append(text, b)
+		if len(text) >= len(pasteEnd) && string(text[len(text)-len(pasteEnd):]) == pasteEnd {
+			text = text[:len(text)-len(pasteEnd)]
+			break
+		}
+	}
+
+	s := strings.ReplaceAll(string(text), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return &Key{Type: KeyPaste, Text: s}, nil
+}
+
 func parseControlChar(b byte) (*Key, error) {
 	switch b {
 	case 0x0d: // Ctrl+M (Enter)
@@ -191,6 +491,13 @@ func parseControlChar(b byte) (*Key, error) {
 				Ctrl: true,
 			}, nil
 		}
+		if b >= 0x1c { // Ctrl+\ Ctrl+] Ctrl+^ Ctrl+_
+			return &Key{
+				Type: KeyCtrl,
+				Rune: rune('\\' + b - 0x1c),
+				Ctrl: true,
+			}, nil
+		}
 		return &Key{
 			Type: KeyRune,
 			Rune: rune(b),
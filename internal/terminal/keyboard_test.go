@@ -0,0 +1,165 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+// reads the keys the terminal sends as input.
+func readKeys(t *testing.T, input string) []Key {
+	t.Helper()
+	term := &Terminal{input: newInputReader(strings.NewReader(input))}
+	var keys []Key
+	for {
+		key, err := term.ReadKey()
+		if err != nil {
+			return keys
+		}
+		keys = append(keys, *key)
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Key
+	}{
+		// plain keys and control characters
+		{"rune", "a", Key{Type: KeyRune, Rune: 'a'}},
+		{"utf-8", "é", Key{Type: KeyRune, Rune: 'é'}},
+		{"enter", "\r", Key{Type: KeyEnter}},
+		{"tab", "\t", Key{Type: KeyTab}},
+		{"backspace", "\x7f", Key{Type: KeyBackspace}},
+		{"ctrl-h", "\x08", Key{Type: KeyBackspace}},
+		{"ctrl-a", "\x01", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true}},
+		{"ctrl-c", "\x03", Key{Type: KeyCtrl, Rune: 'c'}},
+		{"ctrl-]", "\x1d", Key{Type: KeyCtrl, Rune: ']', Ctrl: true}},
+		{"escape", "\x1b", Key{Type: KeyEscape}},
+
+		// CSI and SS3 keys
+		{"up", "\x1b[A", Key{Type: KeyArrowUp}},
+		{"end", "\x1b[F", Key{Type: KeyEnd}},
+		{"ss3 up", "\x1bOA", Key{Type: KeyArrowUp}},
+		{"ss3 down", "\x1bOB", Key{Type: KeyArrowDown}},
+		{"ss3 right", "\x1bOC", Key{Type: KeyArrowRight}},
+		{"ss3 left", "\x1bOD", Key{Type: KeyArrowLeft}},
+		{"ss3 home", "\x1bOH", Key{Type: KeyHome}},
+		{"ss3 end", "\x1bOF", Key{Type: KeyEnd}},
+		{"insert", "\x1b[2~", Key{Type: KeyInsert}},
+		{"delete", "\x1b[3~", Key{Type: KeyDelete}},
+		{"page up", "\x1b[5~", Key{Type: KeyPageUp}},
+		{"page down", "\x1b[6~", Key{Type: KeyPageDown}},
+		{"rxvt home", "\x1b[7~", Key{Type: KeyHome}},
+		{"shift-tab", "\x1b[Z", Key{Type: KeyTab, Shift: true}},
+		{"focus in", "\x1b[I", Key{Type: KeyFocusIn}},
+		{"focus out", "\x1b[O", Key{Type: KeyFocusOut}},
+		{"unknown", "\x1b[99~", Key{Type: KeyEscape}},
+
+		// function keys
+		{"f1", "\x1bOP", Key{Type: KeyF1}},
+		{"f2", "\x1bOQ", Key{Type: KeyF2}},
+		{"f3", "\x1bOR", Key{Type: KeyF3}},
+		{"f4", "\x1bOS", Key{Type: KeyF4}},
+		{"vt f1", "\x1b[11~", Key{Type: KeyF1}},
+		{"vt f4", "\x1b[14~", Key{Type: KeyF4}},
+		{"f5", "\x1b[15~", Key{Type: KeyF5}},
+		{"f6", "\x1b[17~", Key{Type: KeyF6}},
+		{"f7", "\x1b[18~", Key{Type: KeyF7}},
+		{"f8", "\x1b[19~", Key{Type: KeyF8}},
+		{"f9", "\x1b[20~", Key{Type: KeyF9}},
+		{"f10", "\x1b[21~", Key{Type: KeyF10}},
+		{"f11", "\x1b[23~", Key{Type: KeyF11}},
+		{"f12", "\x1b[24~", Key{Type: KeyF12}},
+		{"linux f1", "\x1b[[A", Key{Type: KeyF1}},
+		{"linux f5", "\x1b[[E", Key{Type: KeyF5}},
+
+		// xterm modifiers, 1 + shift 1, alt 2, ctrl 4
+		{"ctrl-right", "\x1b[1;5C", Key{Type: KeyArrowRight, Ctrl: true}},
+		{"shift-up", "\x1b[1;2A", Key{Type: KeyArrowUp, Shift: true}},
+		{"alt-left", "\x1b[1;3D", Key{Type: KeyArrowLeft, Alt: true}},
+		{"ctrl-shift-home", "\x1b[1;6H", Key{Type: KeyHome, Ctrl: true, Shift: true}},
+		{"ctrl-alt-end", "\x1b[1;7F", Key{Type: KeyEnd, Ctrl: true, Alt: true}},
+		{"ctrl-delete", "\x1b[3;5~", Key{Type: KeyDelete, Ctrl: true}},
+		{"shift-page-up", "\x1b[5;2~", Key{Type: KeyPageUp, Shift: true}},
+		{"ctrl-f1", "\x1b[1;5P", Key{Type: KeyF1, Ctrl: true}},
+		{"shift-f5", "\x1b[15;2~", Key{Type: KeyF5, Shift: true}},
+		{"alt-f12", "\x1b[24;3~", Key{Type: KeyF12, Alt: true}},
+
+		// Alt sends ESC before the key
+		{"alt-a", "\x1ba", Key{Type: KeyRune, Rune: 'a', Alt: true}},
+		{"alt-A", "\x1bA", Key{Type: KeyRune, Rune: 'A', Alt: true}},
+		{"alt-.", "\x1b.", Key{Type: KeyRune, Rune: '.', Alt: true}},
+		{"alt-;", "\x1b;", Key{Type: KeyRune, Rune: ';', Alt: true}},
+		{"alt-[", "\x1b[", Key{Type: KeyRune, Rune: '[', Alt: true}},
+		{"alt-O", "\x1bO", Key{Type: KeyRune, Rune: 'O', Alt: true}},
+		{"alt-é", "\x1bé", Key{Type: KeyRune, Rune: 'é', Alt: true}},
+		{"alt-ctrl-a", "\x1b\x01", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true, Alt: true}},
+		{"alt-enter", "\x1b\r", Key{Type: KeyEnter, Alt: true}},
+		{"alt-backspace", "\x1b\x7f", Key{Type: KeyBackspace, Alt: true}},
+		{"alt-escape", "\x1b\x1b", Key{Type: KeyEscape, Alt: true}},
+		{"rxvt alt-up", "\x1b\x1b[A", Key{Type: KeyArrowUp, Alt: true}},
+
+		// kitty keyboard protocol, ESC [ code ; modifiers u
+		{"kitty a", "\x1b[97u", Key{Type: KeyRune, Rune: 'a'}},
+		{"kitty ctrl-a", "\x1b[97;5u", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true}},
+		{"kitty alt-a", "\x1b[97;3u", Key{Type: KeyRune, Rune: 'a', Alt: true}},
+		{"kitty alt-shift-a", "\x1b[97;4u", Key{Type: KeyRune, Rune: 'A', Alt: true, Shift: true}},
+		{"kitty ctrl-alt-a", "\x1b[97;7u", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true, Alt: true}},
+		{"kitty ctrl-shift-a", "\x1b[97;6u", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true, Shift: true}},
+		{"kitty alt-.", "\x1b[46;3u", Key{Type: KeyRune, Rune: '.', Alt: true}},
+		{"kitty tab", "\x1b[9u", Key{Type: KeyTab}},
+		{"kitty ctrl-i", "\x1b[105;5u", Key{Type: KeyCtrl, Rune: 'i', Ctrl: true}},
+		{"kitty shift-tab", "\x1b[9;2u", Key{Type: KeyTab, Shift: true}},
+		{"kitty enter", "\x1b[13u", Key{Type: KeyEnter}},
+		{"kitty ctrl-m", "\x1b[109;5u", Key{Type: KeyCtrl, Rune: 'm', Ctrl: true}},
+		{"kitty ctrl-enter", "\x1b[13;5u", Key{Type: KeyEnter, Ctrl: true}},
+		{"kitty escape", "\x1b[27u", Key{Type: KeyEscape}},
+		{"kitty ctrl-[", "\x1b[91;5u", Key{Type: KeyEscape, Ctrl: true}},
+		{"kitty backspace", "\x1b[127u", Key{Type: KeyBackspace}},
+		{"kitty alt-backspace", "\x1b[127;3u", Key{Type: KeyBackspace, Alt: true}},
+		{"kitty alternate key", "\x1b[97:65;5u", Key{Type: KeyCtrl, Rune: 'a', Ctrl: true}},
+		{"kitty keypad", "\x1b[57399u", Key{Type: KeyEscape}},
+
+		// mouse and paste
+		{"mouse press", "\x1b[<0;10;5M", Key{Type: KeyMouse, Mouse: MouseEvent{Button: MouseLeft, Col: 9, Row: 4}}},
+		{"mouse release", "\x1b[<0;10;5m", Key{Type: KeyMouse, Mouse: MouseEvent{Button: MouseLeft, Action: MouseRelease, Col: 9, Row: 4}}},
+		{"mouse drag", "\x1b[<32;1;1M", Key{Type: KeyMouse, Mouse: MouseEvent{Button: MouseLeft, Action: MouseDrag}}},
+		{"wheel down", "\x1b[<65;3;2M", Key{Type: KeyMouse, Mouse: MouseEvent{Button: MouseWheelDown, Col: 2, Row: 1}}},
+		{"paste", "\x1b[200~one\r\ntwo\rthree\x1b[201~", Key{Type: KeyPaste, Text: "one\ntwo\nthree"}},
+	}
+	for _, tt := range tests {
+		keys := readKeys(t, tt.input)
+		if len(keys) != 1 || keys[0] != tt.want {
+			t.Errorf("%s (%q): got %+v, want %+v", tt.name, tt.input, keys, tt.want)
+		}
+	}
+}
+
+// Ctrl-i and Ctrl-m can only be told from Tab and Enter with the kitty
+// protocol; without it they are the same bytes.
+func TestReadKeyLegacyCtrlTabEnter(t *testing.T) {
+	keys := readKeys(t, "\x09\x0d")
+	want := []Key{{Type: KeyTab}, {Type: KeyEnter}}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] {
+		t.Errorf("got %+v, want %+v", keys, want)
+	}
+}
+
+func TestReadKeySequence(t *testing.T) {
+	keys := readKeys(t, "x\x1b[1;5Ay\x1bOP")
+	want := []Key{
+		{Type: KeyRune, Rune: 'x'},
+		{Type: KeyArrowUp, Ctrl: true},
+		{Type: KeyRune, Rune: 'y'},
+		{Type: KeyF1},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys %+v, want %+v", len(keys), keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d: got %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+}
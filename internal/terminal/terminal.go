@@ -92,6 +92,57 @@ func (t *Terminal) DisableAlternateBuffer() error {
 	return err
 }
 
+// asks the terminal to report when its window gains or loses focus.
+func (t *Terminal) EnableFocusReporting() error {
+	_, err := os.Stdout.Write([]byte(ansi.EnableFocusReporting))
+	return err
+}
+
+// stops focus reports.
+func (t *Terminal) DisableFocusReporting() error {
+	_, err := os.Stdout.Write([]byte(ansi.DisableFocusReporting))
+	return err
+}
+
+// asks the terminal to mark pasted text, so it arrives as one KeyPaste.
+func (t *Terminal) EnableBracketedPaste() error {
+	_, err := os.Stdout.Write([]byte(ansi.EnableBracketedPaste))
+	return err
+}
+
+// stops marking pasted text.
+func (t *Terminal) DisableBracketedPaste() error {
+	_, err := os.Stdout.Write([]byte(ansi.DisableBracketedPaste))
+	return err
+}
+
+// asks the terminal to report mouse clicks, drags and wheel turns as
+// KeyMouse events.
+func (t *Terminal) EnableMouse() error {
+	_, err := os.Stdout.Write([]byte(ansi.EnableMouse))
+	return err
+}
+
+// stops mouse reports, giving the mouse back to the terminal.
+func (t *Terminal) DisableMouse() error {
+	_, err := os.Stdout.Write([]byte(ansi.DisableMouse))
+	return err
+}
+
+// asks the terminal for the kitty keyboard protocol, which sends modified
+// keys as ESC [ code ; modifiers u, so Ctrl-i is not Tab. Terminals without
+// it ignore the request and keep sending the legacy bytes.
+func (t *Terminal) EnableKittyKeyboard() error {
+	_, err := os.Stdout.Write([]byte(ansi.EnableKittyKeyboard))
+	return err
+}
+
+// goes back to the keyboard mode the terminal had before.
+func (t *Terminal) DisableKittyKeyboard() error {
+	_, err := os.Stdout.Write([]byte(ansi.DisableKittyKeyboard))
+	return err
+}
+
 // writes a string to the terminal.
 func (t *Terminal) Write(s string) error {
 	_, err := os.Stdout.WriteString(s)
@@ -0,0 +1,80 @@
+package ui
+
+import "strings"
+
+// screen columns: a tab takes the columns up to the next multiple of the
+// tab stop, every other character one column. Buffer positions count
+// characters, so the renderer and the editor convert between the two.
+
+// returns the column after a character r drawn at column col.
+func nextColumn(col int, r rune, tabStop int) int {
+	if r == '\t' && tabStop > 0 {
+		return col + tabStop - col%tabStop
+	}
+	return col + 1
+}
+
+// returns the screen column the character at index col of line starts at.
+// Positions past the end of the line count one column each.
+func DisplayColumn(line string, col, tabStop int) int {
+	dcol, i := 0, 0
+	for _, r := range line {
+		if i == col {
+			return dcol
+		}
+		dcol = nextColumn(dcol, r, tabStop)
+		i++
+	}
+	return dcol + max(col-i, 0)
+}
+
+// returns the index of the character of line drawn over screen column
+// dcol, or the length of the line when dcol is past its end.
+func ColumnAt(line string, dcol, tabStop int) int {
+	col, pos := 0, 0
+	for _, r := range line {
+		pos = nextColumn(pos, r, tabStop)
+		if dcol < pos {
+			return col
+		}
+		col++
+	}
+	return col
+}
+
+// returns the screen columns the whole of line takes.
+func LineWidth(line string, tabStop int) int {
+	n := 0
+	for _, r := range line {
+		n = nextColumn(n, r, tabStop)
+	}
+	return n
+}
+
+// returns the screen rows line takes when it wraps at width columns.
+func WrappedRows(line string, width, tabStop int) int {
+	n := LineWidth(line, tabStop)
+	if width <= 0 || n == 0 {
+		return 1
+	}
+	return (n + width - 1) / width
+}
+
+// replaces the tabs of line with the spaces they take on the screen.
+func ExpandTabs(line string, tabStop int) string {
+	if !strings.ContainsRune(line, '\t') {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		next := nextColumn(col, r, tabStop)
+		if r == '\t' {
+			b.WriteString(strings.Repeat(" ", next-col))
+		} else {
+			b.WriteRune(r)
+		}
+		col = next
+	}
+	return b.String()
+}
@@ -12,9 +12,8 @@ import (
 
 // responsible for rendering the editor UI to the terminal.
 type Renderer struct {
-	terminal    *terminal.Terminal
-	theme       Theme
-	highlighter *syntax.Highlighter
+	terminal *terminal.Terminal
+	theme    Theme
 }
 
 func NewRenderer(term *terminal.Terminal) *Renderer {
@@ -24,24 +23,20 @@ func NewRenderer(term *terminal.Terminal) *Renderer {
 	}
 }
 
-// sets the language for syntax highlighting based on file path.
-func (r *Renderer) SetLanguage(filePath string) {
-	lang := syntax.DetectLanguage(filePath)
-	if lang != nil {
-		colorTheme := syntax.ColorTheme{
-			Keyword:  r.theme.Keyword,
-			String:   r.theme.String,
-			Comment:  r.theme.Comment,
-			Number:   r.theme.Number,
-			Function: r.theme.Function,
-			Type:     r.theme.Type,
-			Operator: r.theme.Operator,
-			Builtin:  r.theme.Builtin,
-		}
-		r.highlighter = syntax.NewHighlighter(lang, colorTheme)
-	} else {
-		r.highlighter = nil
+// returns a syntax highlighter for the language of filePath, or nil when
+// the language is not supported.
+func (r *Renderer) Highlighter(filePath string) *syntax.Highlighter {
+	colorTheme := syntax.ColorTheme{
+		Keyword:  r.theme.Keyword,
+		String:   r.theme.String,
+		Comment:  r.theme.Comment,
+		Number:   r.theme.Number,
+		Function: r.theme.Function,
+		Type:     r.theme.Type,
+		Operator: r.theme.Operator,
+		Builtin:  r.theme.Builtin,
 	}
+	return syntax.NewHighlighter(syntax.DetectLanguage(filePath), colorTheme)
 }
 
 // represents a highlighted range on a line.
@@ -65,31 +60,55 @@ type ExplorerView struct {
 	RowOffset int
 }
 
-// provides the data needed to render the editor.
+// gives the renderer read access to buffer lines without copying them.
+type LineSource interface {
+	NumLines() int
+	GetLine(row int) (string, error)
+}
+
+// provides the data needed to render one window: a view of a buffer in a
+// rectangle of the screen, with its own gutter and status line.
 type EditorView struct {
-	Lines      []string
+	Lines      LineSource
 	FileName   string
 	IsModified bool
 	CursorRow  int
 	CursorCol  int
 	RowOffset  int
-	ColOffset  int
+	ColOffset  int // first screen column shown, with tabs expanded
 	ModeName   string
-	Message    string
-	TermWidth  int
-	TermHeight int
+	Recording  string // macro recording note, e.g. "recording @a"
 	TotalLines int
+	FileFormat string // line ending label, e.g. "unix" or "dos"
+	PlainText  bool   // large file mode: skip syntax highlighting
+
+	Highlighter *syntax.Highlighter // nil for plain text
+
+	TabStop        int  // columns between tab stops
+	Number         bool // show line numbers in the gutter
+	RelativeNumber bool // number the lines by their distance from the cursor
+	Wrap           bool // wrap long lines instead of scrolling sideways
+
+	// Screen rectangle (0-based). Height counts text rows only; the status
+	// line is drawn on the row below them.
+	Top       int
+	Left      int
+	Width     int
+	Height    int
+	Active    bool // the window with the cursor
+	Separator bool // draw a vertical separator right of the window
 
 	// Search highlighting
 	SearchMatches map[int][]MatchRange // row -> list of match ranges
 	SearchActive  bool
+	SearchCount   string // match counter shown in the status bar, e.g. "[3/17]"
 
 	// Bracket matching
 	BracketMatch *BracketMatchView // nil if no match
 
-	// Explorer mode
-	IsExplorer bool
-	Explorer   ExplorerView
+	// Visual selection: row -> selected columns; a range reaching past the
+	// end of the line includes the line break
+	Selection map[int]MatchRange
 }
 
 // holds the position of a matching bracket for rendering.
@@ -98,31 +117,68 @@ type BracketMatchView struct {
 	Col int
 }
 
-// renders the entire editor screen using the provided view data.
-func (r *Renderer) Render(view EditorView) error {
+// describes one tab page in the tab line.
+type TabLabel struct {
+	FileName   string // file of the tab's current window
+	IsModified bool
+	Windows    int // number of windows in the tab
+	Active     bool
+}
+
+// provides everything drawn in one screen refresh: the tab line, the
+// windows of the layout, in left-to-right, top-to-bottom order, and the
+// message bar.
+type Frame struct {
+	Tabs       []TabLabel // tab line on the first row; empty for no tab line
+	Windows    []EditorView
+	Message    string
+	TermWidth  int
+	TermHeight int
+
+	// command output shown above the message bar (:ls, ...)
+	Output []string
+
+	// Explorer mode takes over the screen above the status line of Windows[0]
+	IsExplorer bool
+	Explorer   ExplorerView
+}
+
+// renders the entire editor screen using the provided frame data.
+func (r *Renderer) Render(frame Frame) error {
 
 	r.terminal.ClearBuffer()
 	r.terminal.PrepareScreen()
 
 	var screenRow, screenCol int
 
-	if view.IsExplorer {
-		r.renderExplorer(view)
+	if frame.IsExplorer {
+		r.renderExplorer(frame)
 		// Explorer cursor: 2 header rows + offset within visible entries
 		headerRows := 2
-		screenRow = view.Explorer.CursorRow - view.Explorer.RowOffset + headerRows + 1
+		screenRow = frame.Explorer.CursorRow - frame.Explorer.RowOffset + headerRows + 1
 		screenCol = 1
+		r.renderStatusBar(frame.Windows[0])
 	} else {
-		r.renderBuffer(view)
-		// Calculate cursor screen position (1-indexed for terminal)
-		// consider the gutter width
-		gutterWidth := GutterWidth(view.TotalLines)
-		screenRow = (view.CursorRow - view.RowOffset) + 1
-		screenCol = (view.CursorCol - view.ColOffset) + 1 + gutterWidth
+		if len(frame.Tabs) > 0 {
+			r.renderTabLine(frame)
+		}
+
+		// windows are drawn left to right, so anything spilling over a
+		// window's right edge is painted over by its neighbour
+		for _, view := range frame.Windows {
+			cursorRow, cursorCol := r.renderBuffer(view)
+			r.renderStatusBar(view)
+			if view.Separator {
+				r.renderSeparator(view)
+			}
+			if view.Active {
+				screenRow, screenCol = cursorRow, cursorCol
+			}
+		}
 	}
 
-	r.renderStatusBar(view)
-	r.renderMessageBar(view)
+	r.renderOutput(frame)
+	r.renderMessageBar(frame)
 
 	// Finalize screen (position cursor, show cursor)
 	r.terminal.FinalizeScreen(screenRow, screenCol)
@@ -145,6 +201,29 @@ func GutterWidth(totalLines int) int {
 	return 8
 }
 
+// returns the width of the view's gutter: none without line numbers.
+func (v EditorView) GutterWidth() int {
+	if !v.Number && !v.RelativeNumber {
+		return 0
+	}
+	return GutterWidth(v.TotalLines)
+}
+
+// returns the number shown in the gutter for fileRow: its line number, or
+// its distance from the cursor line with relativenumber, where the cursor
+// line shows its own number when number is set too.
+func (v EditorView) lineNumber(fileRow int) int {
+	switch {
+	case !v.RelativeNumber:
+		return fileRow + 1
+	case fileRow != v.CursorRow:
+		return max(fileRow-v.CursorRow, v.CursorRow-fileRow)
+	case v.Number:
+		return fileRow + 1
+	}
+	return 0
+}
+
 // renders a line number in the gutter with proper formatting.
 func (r *Renderer) renderLineNumber(lineNum, width int, isCurrent bool) {
 	// Set colors based on whether this is the current line
@@ -175,18 +254,18 @@ func (r *Renderer) renderEmptyLine(gutterWidth int) {
 }
 
 // renders the file explorer view (netrw-style).
-func (r *Renderer) renderExplorer(view EditorView) {
-	ev := view.Explorer
-	listingRows := view.TermHeight - 4 // 2 header + 1 status + 1 message
+func (r *Renderer) renderExplorer(frame Frame) {
+	ev := frame.Explorer
+	listingRows := frame.TermHeight - 4 // 2 header + 1 status + 1 message
 
 	// Row 1: header
-	r.renderExplorerHeader(ev.Dir, view.TermWidth)
+	r.renderExplorerHeader(ev.Dir, frame.TermWidth)
 
 	// Row 2: separator
 	r.terminal.MoveCursorTo(2, 1)
 	r.terminal.WriteStr(ansi.ClearLine)
 	r.terminal.WriteStr(ansi.SetFgColor(r.theme.Border))
-	r.terminal.WriteStr(strings.Repeat("─", view.TermWidth))
+	r.terminal.WriteStr(strings.Repeat("─", frame.TermWidth))
 	r.terminal.ResetFormat()
 
 	// Rows 3+: entries
@@ -200,7 +279,7 @@ func (r *Renderer) renderExplorer(view EditorView) {
 			continue
 		}
 
-		r.renderExplorerEntry(ev.Entries[idx], idx == ev.CursorRow, view.TermWidth)
+		r.renderExplorerEntry(ev.Entries[idx], idx == ev.CursorRow, frame.TermWidth)
 	}
 }
 
@@ -253,74 +332,132 @@ func (r *Renderer) renderExplorerEntry(entry ExplorerViewEntry, isSelected bool,
 	r.terminal.ClearToLineEnd()
 }
 
-// renders the visible portion of the text buffer with line numbers.
-func (r *Renderer) renderBuffer(view EditorView) {
-	// Calculate visible area (excluding status and message bars)
-	visibleRows := view.TermHeight - 2
-
-	// Calculate gutter width based on total lines
-	gutterWidth := GutterWidth(view.TotalLines)
+// renders the visible portion of the text buffer with line numbers,
+// inside the window's rectangle. Returns the screen position of the
+// cursor (1-indexed for the terminal).
+func (r *Renderer) renderBuffer(view EditorView) (cursorRow, cursorCol int) {
+	gutterWidth := view.GutterWidth()
 
 	// Calculate available width for text after gutter
-	textWidth := view.TermWidth - gutterWidth
-
-	for y := 0; y < visibleRows; y++ {
-		fileRow := y + view.RowOffset
-
-		// Move cursor to this row and clear it entirely.
-		// this prevents stale content from showing through tab gaps
-		r.terminal.MoveCursorTo(y+1, 1)
-		r.terminal.WriteStr(ansi.ClearLine)
+	textWidth := max(view.Width-gutterWidth, 1)
 
-		if fileRow >= len(view.Lines) {
+	y := 0
+	for fileRow := view.RowOffset; y < view.Height; fileRow++ {
+		if fileRow >= view.Lines.NumLines() {
 			// Past end of file - show empty gutter and tilde
+			r.startRow(view, y)
 			r.renderEmptyLine(gutterWidth)
-		} else {
-			// Render line number in gutter
-			lineNum := fileRow + 1
-			isCurrent := fileRow == view.CursorRow
-			r.renderLineNumber(lineNum, gutterWidth, isCurrent)
+			y++
+			continue
+		}
 
-			// Render line content with syntax highlighting and horizontal scrolling
-			line := view.Lines[fileRow]
+		line, _ := view.Lines.GetLine(fileRow)
+		expanded := ExpandTabs(line, view.TabStop)
+		width := len([]rune(expanded))
 
-			// Apply syntax highlighting to the full line, then extract visible portion
-			var displayLine string
-			if r.highlighter != nil {
-				highlighted := r.highlighter.Highlight(line)
-				displayLine = extractVisiblePortion(highlighted, view.ColOffset, textWidth)
-			} else {
-				runes := []rune(line)
-				if view.ColOffset < len(runes) {
-					visibleEnd := view.ColOffset + textWidth
-					if visibleEnd > len(runes) {
-						visibleEnd = len(runes)
-					}
-					displayLine = string(runes[view.ColOffset:visibleEnd])
-				}
+		// a long line is scrolled sideways, or wraps over several rows
+		rows, start := 1, view.ColOffset
+		if view.Wrap {
+			rows, start = WrappedRows(line, textWidth, view.TabStop), 0
+		}
+
+		if fileRow == view.CursorRow {
+			col := DisplayColumn(line, view.CursorCol, view.TabStop) - start
+			row := 0
+			if view.Wrap {
+				row = min(col/textWidth, rows-1)
+				col -= row * textWidth
 			}
+			cursorRow = view.Top + y + row + 1
+			cursorCol = view.Left + gutterWidth + col + 1
+		}
+
+		// Apply syntax highlighting to the full line, then extract visible portions
+		var highlighted string
+		if view.Highlighter != nil && !view.PlainText {
+			highlighted = view.Highlighter.Highlight(expanded)
+		}
 
-			// Apply search highlighting on top
-			if view.SearchActive {
-				if matches, ok := view.SearchMatches[fileRow]; ok && len(matches) > 0 {
-					displayLine = r.applySearchHighlight(displayLine, matches, view.ColOffset, textWidth)
+		for seg := 0; seg < rows && y < view.Height; seg++ {
+			segStart := start + seg*textWidth
+
+			r.startRow(view, y)
+			if gutterWidth > 0 {
+				if seg == 0 {
+					r.renderLineNumber(view.lineNumber(fileRow), gutterWidth, fileRow == view.CursorRow)
+				} else {
+					r.terminal.WriteStr(strings.Repeat(" ", gutterWidth))
 				}
 			}
 
-			// Apply bracket match highlighting
-			if view.BracketMatch != nil && view.BracketMatch.Row == fileRow {
-				matchCol := view.BracketMatch.Col - view.ColOffset
-				if matchCol >= 0 && matchCol < textWidth {
-					displayLine = r.applyBracketHighlight(displayLine, matchCol)
-				}
+			var displayLine string
+			if highlighted != "" {
+				displayLine = extractVisiblePortion(highlighted, segStart, textWidth)
+			} else if runes := []rune(expanded); segStart < len(runes) {
+				displayLine = string(runes[segStart:min(segStart+textWidth, len(runes))])
 			}
+			displayLine = r.applyMarks(view, fileRow, line, width, displayLine, segStart, textWidth)
 
 			r.terminal.WriteStr(displayLine)
+			y++
+		}
+	}
+	return cursorRow, cursorCol
+}
+
+// moves to row y of the window and blanks the window's part of it. This
+// prevents stale content from showing through tab gaps.
+func (r *Renderer) startRow(view EditorView, y int) {
+	r.terminal.MoveCursorTo(view.Top+y+1, view.Left+1)
+	r.terminal.WriteStr(ansi.EraseChars(view.Width))
+}
+
+// applies the visual selection, search matches and bracket match of
+// fileRow to the part of it shown from screen column segStart. width is
+// the screen width of the whole line.
+func (r *Renderer) applyMarks(view EditorView, fileRow int, line string, width int, displayLine string, segStart, textWidth int) string {
+	screen := func(m MatchRange) MatchRange {
+		return MatchRange{
+			ColStart: DisplayColumn(line, m.ColStart, view.TabStop),
+			ColEnd:   DisplayColumn(line, m.ColEnd, view.TabStop),
+		}
+	}
+
+	// Apply the visual selection, keeping the syntax colours
+	if sel, ok := view.Selection[fileRow]; ok {
+		displayLine = r.applySelectionHighlight(displayLine, screen(sel), width, segStart, textWidth)
+	}
+
+	// Apply search highlighting on top
+	if view.SearchActive {
+		if matches, ok := view.SearchMatches[fileRow]; ok && len(matches) > 0 {
+			cols := make([]MatchRange, len(matches))
+			for i, m := range matches {
+				cols[i] = screen(m)
+			}
+			displayLine = r.applySearchHighlight(displayLine, cols, segStart, textWidth)
 		}
+	}
 
-		// Clear to end of line (in case line got shorter)
-		r.terminal.ClearToLineEnd()
+	// Apply bracket match highlighting
+	if view.BracketMatch != nil && view.BracketMatch.Row == fileRow {
+		matchCol := DisplayColumn(line, view.BracketMatch.Col, view.TabStop) - segStart
+		if matchCol >= 0 && matchCol < textWidth {
+			displayLine = r.applyBracketHighlight(displayLine, matchCol)
+		}
+	}
+	return displayLine
+}
+
+// draws the column separating a window from its right-hand neighbour,
+// down to and including the status line row.
+func (r *Renderer) renderSeparator(view EditorView) {
+	r.terminal.WriteStr(ansi.SetFgColor(r.theme.Border))
+	for y := 0; y <= view.Height; y++ {
+		r.terminal.MoveCursorTo(view.Top+y+1, view.Left+view.Width+1)
+		r.terminal.WriteStr("│")
 	}
+	r.terminal.ResetFormat()
 }
 
 // applies yellow background to search matches within the visible portion.
@@ -397,6 +534,58 @@ func (r *Renderer) applySearchHighlight(
 	return result.String()
 }
 
+// gives the selected columns of a line the selection background. The
+// syntax colours stay; a selected line break shows as one extra cell.
+func (r *Renderer) applySelectionHighlight(displayLine string, sel MatchRange, lineLen, colOffset, textWidth int) string {
+	var result strings.Builder
+	visPos := 0
+	inEscape := false
+	on := false
+
+	for i := 0; i < len(displayLine); i++ {
+		ch := displayLine[i]
+
+		if ch == '\x1b' {
+			inEscape = true
+			on = false // the sequence may reset the background; set it again after
+		}
+		if inEscape {
+			result.WriteByte(ch)
+			if ch == 'm' {
+				inEscape = false
+			}
+			continue
+		}
+
+		if ch&0xC0 != 0x80 { // first byte of a rune
+			col := visPos + colOffset
+			inside := col >= sel.ColStart && col < sel.ColEnd
+			if inside && !on {
+				result.WriteString(ansi.SetBgColor(r.theme.Selection))
+				on = true
+			} else if !inside && on {
+				result.WriteString(ansi.BgDefault)
+				on = false
+			}
+			visPos++
+		}
+		result.WriteByte(ch)
+	}
+
+	// the line break
+	if eol := lineLen - colOffset; sel.ColEnd > lineLen && eol >= 0 && eol < textWidth && visPos == eol {
+		if !on {
+			result.WriteString(ansi.SetBgColor(r.theme.Selection))
+			on = true
+		}
+		result.WriteString(" ")
+	}
+	if on {
+		result.WriteString(ansi.BgDefault)
+	}
+	return result.String()
+}
+
 // highlights a single character at the given visible column.
 func (r *Renderer) applyBracketHighlight(displayLine string, visCol int) string {
 	var result strings.Builder
@@ -492,45 +681,77 @@ func extractVisiblePortion(highlighted string, colOffset, width int) string {
 	return result.String()
 }
 
-// renders the status bar at the bottom of the screen.
+// renders a window's status line on the row below its text.
 func (r *Renderer) renderStatusBar(view EditorView) {
-	// Position at status bar row
-	statusRow := view.TermHeight - 1
-	r.terminal.MoveCursorTo(statusRow, 1)
+	statusRow := view.Top + view.Height + 1
+	r.terminal.MoveCursorTo(statusRow, view.Left+1)
+	r.terminal.WriteStr(ansi.EraseChars(view.Width))
 
 	// Calculate percentage
-	percentage := calculatePercentage(view.CursorRow, len(view.Lines))
-
-	// Create enhanced status bar
-	statusLine := EnhancedStatusBar(
-		r.theme,
-		view.ModeName,
-		view.FileName,
-		view.IsModified,
-		view.CursorRow+1,
-		view.CursorCol+1,
-		percentage,
-		view.TermWidth,
-	)
-
-	// Write status bar
-	r.terminal.WriteStr(statusLine)
-
-	// Clear to end of line (in case terminal is wider)
-	r.terminal.ClearToLineEnd()
+	percentage := calculatePercentage(view.CursorRow, view.Lines.NumLines())
+
+	var statusLine string
+	if view.Active {
+		statusLine = EnhancedStatusBar(
+			r.theme,
+			view.ModeName,
+			view.Recording,
+			view.FileName,
+			view.IsModified,
+			view.FileFormat,
+			view.SearchCount,
+			view.CursorRow+1,
+			view.CursorCol+1,
+			percentage,
+			view.Width,
+		)
+	} else {
+		statusLine = InactiveStatusBar(
+			r.theme,
+			view.FileName,
+			view.IsModified,
+			view.CursorRow+1,
+			view.CursorCol+1,
+			percentage,
+			view.Width,
+		)
+	}
+
+	// never spill past the window (or wrap at the terminal edge)
+	r.terminal.WriteStr(truncateVisible(statusLine, view.Width))
+	r.terminal.ResetFormat()
+}
+
+// renders command output lines directly above the message bar, covering
+// the bottom of the screen. Only the last lines are shown if they don't fit.
+func (r *Renderer) renderOutput(frame Frame) {
+	lines := frame.Output
+	if len(lines) >= frame.TermHeight {
+		lines = lines[len(lines)-frame.TermHeight+1:]
+	}
+
+	firstRow := frame.TermHeight - len(lines)
+	for i, line := range lines {
+		r.terminal.MoveCursorTo(firstRow+i, 1)
+		r.terminal.WriteStr(ansi.ClearLine)
+		if runes := []rune(line); len(runes) > frame.TermWidth {
+			line = string(runes[:frame.TermWidth])
+		}
+		r.terminal.WriteStr(line)
+	}
 }
 
 // renders the message/command bar at the very bottom.
-func (r *Renderer) renderMessageBar(view EditorView) {
+func (r *Renderer) renderMessageBar(frame Frame) {
 	// Position at message bar row
-	messageRow := view.TermHeight
+	messageRow := frame.TermHeight
 	r.terminal.MoveCursorTo(messageRow, 1)
 
-	message := view.Message
+	message := frame.Message
 
 	// Truncate message if too long
-	if len(message) > view.TermWidth {
-		message = message[:view.TermWidth]
+	if len(message) > frame.TermWidth {
+		message = message[:frame.TermWidth]
 	}
 
 	r.terminal.WriteStr(message)
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/AdityaKrSingh26/Glime/internal/syntax"
 	"github.com/AdityaKrSingh26/Glime/pkg/ansi"
@@ -22,8 +23,11 @@ func visibleLen(s string) int {
 func EnhancedStatusBar(
 	theme Theme,
 	mode,
+	recording,
 	fileName string,
 	modified bool,
+	fileFormat,
+	searchCount string,
 	row,
 	col,
 	percentage,
@@ -40,6 +44,16 @@ func EnhancedStatusBar(
 	result.WriteString(modeText)
 	result.WriteString(ansi.ResetFormat)
 
+	// macro recording segment
+	recordingText := ""
+	if recording != "" {
+		recordingText = fmt.Sprintf(" %s ", recording)
+		result.WriteString(ansi.SetBgColor(theme.StatusLangBg))
+		result.WriteString(ansi.SetFgColor(theme.StatusFg))
+		result.WriteString(recordingText)
+		result.WriteString(ansi.ResetFormat)
+	}
+
 	// file segment
 	fileText := formatFileSegment(fileName, modified)
 	result.WriteString(ansi.SetBgColor(theme.StatusFileBg))
@@ -51,7 +65,7 @@ func EnhancedStatusBar(
 	posText := fmt.Sprintf(" %d,%d  %d%% ", row, col, percentage)
 
 	// calculate used visible width so far (strip ANSI codes before measuring)
-	usedWidth := visibleLen(modeText) + visibleLen(fileText)
+	usedWidth := visibleLen(modeText) + len(recordingText) + visibleLen(fileText)
 
 	// language segment if there's space
 	lang := syntax.LanguageName(fileName)
@@ -65,6 +79,20 @@ func EnhancedStatusBar(
 		usedWidth += len(langText)
 	}
 
+	// file format segment (line endings), shown next to the position if there's space
+	formatText := ""
+	if fileFormat != "" && usedWidth+len(fileFormat)+2 < width-len(posText)-5 {
+		formatText = fmt.Sprintf(" %s ", fileFormat)
+		usedWidth += len(formatText)
+	}
+
+	// search match counter, shown left of the file format if there's space
+	countText := ""
+	if searchCount != "" && usedWidth+len(searchCount)+2 < width-len(posText)-5 {
+		countText = fmt.Sprintf(" %s ", searchCount)
+		usedWidth += len(countText)
+	}
+
 	// padding between segments and position
 	padding := width - usedWidth - len(posText)
 	if padding > 0 {
@@ -73,6 +101,20 @@ func EnhancedStatusBar(
 		result.WriteString(ansi.ResetFormat)
 	}
 
+	if countText != "" {
+		result.WriteString(ansi.SetBgColor(theme.StatusFileBg))
+		result.WriteString(ansi.SetFgColor(theme.StatusFg))
+		result.WriteString(countText)
+		result.WriteString(ansi.ResetFormat)
+	}
+
+	if formatText != "" {
+		result.WriteString(ansi.SetBgColor(theme.StatusLangBg))
+		result.WriteString(ansi.SetFgColor(theme.StatusFg))
+		result.WriteString(formatText)
+		result.WriteString(ansi.ResetFormat)
+	}
+
 	// position segment
 	result.WriteString(ansi.SetBgColor(theme.StatusPosBg))
 	result.WriteString(ansi.SetFgColor(theme.StatusFg))
@@ -82,6 +124,50 @@ func EnhancedStatusBar(
 	return result.String()
 }
 
+// creates the plain status line of a window that doesn't have the cursor.
+func InactiveStatusBar(
+	theme Theme,
+	fileName string,
+	modified bool,
+	row,
+	col,
+	percentage,
+	width int,
+) string {
+
+	fileText := formatFileSegment(fileName, modified)
+	posText := fmt.Sprintf(" %d,%d  %d%% ", row, col, percentage)
+
+	padding := width - utf8.RuneCountInString(fileText) - len(posText)
+	if padding < 0 {
+		padding = 0
+	}
+
+	return ansi.SetBgColor(theme.StatusInactiveBg) + ansi.SetFgColor(theme.StatusInactiveFg) +
+		fileText + strings.Repeat(" ", padding) + posText + ansi.ResetFormat
+}
+
+// cuts an ANSI-colored string after width visible characters.
+func truncateVisible(s string, width int) string {
+	var result strings.Builder
+	visible := 0
+	for i := 0; i < len(s); {
+		if loc := ansiEscape.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			result.WriteString(s[i : i+loc[1]])
+			i += loc[1]
+			continue
+		}
+		if visible == width {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		result.WriteString(s[i : i+size])
+		visible++
+		i += size
+	}
+	return result.String()
+}
+
 // returns an icon for the given mode.
 func getModeIcon(mode string) string {
 	switch strings.ToLower(mode) {
@@ -95,6 +181,10 @@ func getModeIcon(mode string) string {
 		return "/"
 	case "explore", "expl":
 		return "E"
+	case "ask":
+		return "?"
+	case "vis", "v-line", "v-block":
+		return "V"
 	default:
 		return "◆"
 	}
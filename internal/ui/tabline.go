@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AdityaKrSingh26/Glime/pkg/ansi"
+)
+
+// renders the tab line on the first screen row: one label per tab page,
+// the current one highlighted.
+func (r *Renderer) renderTabLine(frame Frame) {
+	r.terminal.MoveCursorTo(1, 1)
+	r.terminal.WriteStr(ansi.EraseChars(frame.TermWidth))
+	r.terminal.WriteStr(TabLine(r.theme, frame.Tabs, frame.TermWidth))
+}
+
+// creates the tab line, e.g. " 1 main.go  2 2 api.go [+] ". Tabs with more
+// than one window show the window count before the name. Labels that don't
+// fit are dropped, keeping the current tab visible.
+func TabLine(theme Theme, tabs []TabLabel, width int) string {
+	labels := make([]string, len(tabs))
+	active := 0
+	for i, tab := range tabs {
+		name := "[No Name]"
+		if tab.FileName != "" {
+			name = filepath.Base(tab.FileName)
+		}
+		label := fmt.Sprintf(" %d ", i+1)
+		if tab.Windows > 1 {
+			label += fmt.Sprintf("%d ", tab.Windows)
+		}
+		label += name
+		if tab.IsModified {
+			label += " [+]"
+		}
+		labels[i] = label + " "
+		if tab.Active {
+			active = i
+		}
+	}
+
+	// scroll the labels so the current tab fits
+	first, used := 0, 0
+	for i := 0; i <= active; i++ {
+		used += utf8.RuneCountInString(labels[i])
+	}
+	for used > width && first < active {
+		used -= utf8.RuneCountInString(labels[first])
+		first++
+	}
+
+	var result strings.Builder
+	for i := first; i < len(labels); i++ {
+		if used = utf8.RuneCountInString(labels[i]); used > width {
+			break
+		}
+		width -= used
+
+		if tabs[i].Active {
+			result.WriteString(ansi.SetBgColor(theme.TabActiveBg))
+			result.WriteString(ansi.SetFgColor(theme.TabActiveFg))
+			result.WriteString(ansi.Bold)
+		} else {
+			result.WriteString(ansi.SetBgColor(theme.TabLineBg))
+			result.WriteString(ansi.SetFgColor(theme.TabLineFg))
+		}
+		result.WriteString(labels[i])
+		result.WriteString(ansi.ResetFormat)
+	}
+
+	// fill the rest of the row
+	result.WriteString(ansi.SetBgColor(theme.TabLineBg))
+	result.WriteString(strings.Repeat(" ", width))
+	result.WriteString(ansi.ResetFormat)
+	return result.String()
+}
@@ -26,9 +26,20 @@ type Theme struct {
 	StatusPosBg  int // Position segment background
 	StatusFg     int // Status bar foreground text
 
+	// Status line of windows without the cursor
+	StatusInactiveBg int
+	StatusInactiveFg int
+
+	// Tab line colors
+	TabLineBg   int // Background behind and between the tabs
+	TabLineFg   int // Label of tabs other than the current one
+	TabActiveBg int // Current tab background
+	TabActiveFg int // Current tab label
+
 	// Search and bracket matching
 	SearchHighlight int // Background color for search matches
 	BracketMatch    int // Background color for matching brackets
+	Selection       int // Background color for the visual selection
 }
 
 // returns the "Glime Modern Dark" theme with vibrant colors.
@@ -58,8 +69,18 @@ func DefaultTheme() Theme {
 		StatusPosBg:  240, // Medium gray
 		StatusFg:     255, // Bright white
 
+		StatusInactiveBg: 235, // Near black
+		StatusInactiveFg: 245, // Light gray
+
+		// Tab line colors
+		TabLineBg:   235, // Near black
+		TabLineFg:   245, // Light gray
+		TabActiveBg: 24,  // Deep blue
+		TabActiveFg: 255, // Bright white
+
 		// Search and bracket matching
 		SearchHighlight: 226, // Yellow background
 		BracketMatch:    240, // Medium gray background
+		Selection:       238, // Slate gray background
 	}
 }
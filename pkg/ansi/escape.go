@@ -1,7 +1,10 @@
 // provides ANSI escape sequence constants and utilities for terminal control operations.
 package ansi
 
-import "fmt"
+import (
+	"encoding/base64"
+	"fmt"
+)
 
 // FYI : An ANSI escape sequence looks like this:
 // " ESC [ parameters command "
@@ -20,6 +23,23 @@ const (
 	RestoreCursor          = "\x1b[u"      // Restore cursor position
 	EnableAlternateBuffer  = "\x1b[?1049h" // Switch to alternate screen buffer
 	DisableAlternateBuffer = "\x1b[?1049l" // Return to main screen buffer
+	EnableFocusReporting   = "\x1b[?1004h" // Report focus in/out as ESC[I / ESC[O
+	DisableFocusReporting  = "\x1b[?1004l" // Stop reporting focus changes
+	EnableBracketedPaste   = "\x1b[?2004h" // Wrap pasted text in ESC[200~ ... ESC[201~
+	DisableBracketedPaste  = "\x1b[?2004l" // Send pasted text like typed keys
+)
+
+// Mouse reporting: clicks, drags and the wheel (1002), SGR encoded (1006)
+const (
+	EnableMouse  = "\x1b[?1002h\x1b[?1006h"
+	DisableMouse = "\x1b[?1002l\x1b[?1006l"
+)
+
+// Kitty keyboard protocol: push the "disambiguate escape codes" flag, pop it
+// again. Every screen keeps its own stack, so this is done on the alternate one.
+const (
+	EnableKittyKeyboard  = "\x1b[>1u"
+	DisableKittyKeyboard = "\x1b[<u"
 )
 
 // Text formatting sequences (ANSI escape codes)
@@ -53,6 +73,7 @@ const (
 	BgMagenta = "\x1b[45m"
 	BgCyan    = "\x1b[46m"
 	BgWhite   = "\x1b[47m"
+	BgDefault = "\x1b[49m" // back to the terminal's background
 )
 
 // MoveCursorTo moves cursor to specific row and column (1-indexed).
@@ -61,6 +82,14 @@ func MoveCursorTo(row, col int) string {
 	return fmt.Sprintf("\x1b[%d;%dH", row, col)
 }
 
+// blanks n characters starting at the cursor without moving it.
+func EraseChars(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[%dX", n)
+}
+
 // moves cursor up by n lines.
 func MoveCursorUp(n int) string {
 	if n <= 0 {
@@ -113,6 +142,13 @@ func SetBgRGB(r, g, b int) string {
 	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm", r, g, b)
 }
 
+// copies text to the system clipboard through the terminal (OSC 52), which
+// works over SSH too. selection is 'c' for the clipboard, 'p' for the
+// primary selection.
+func SetClipboard(selection byte, text string) string {
+	return fmt.Sprintf("\x1b]52;%c;%s\a", selection, base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
 // requests cursor position (returns escape sequence that terminal responds to).
 func GetCursorPosition() string {
 	return "\x1b[6n"